@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/sourceplane/sourceplane/internal/parser"
+	"github.com/sourceplane/sourceplane/internal/providers"
 	"github.com/sourceplane/sourceplane/internal/validator"
 	"github.com/spf13/cobra"
 )
@@ -28,8 +30,13 @@ var componentListCmd = &cobra.Command{
 			return err
 		}
 
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
 		// Validate before proceeding
-		if err := validator.ValidateRepository(repo); err != nil {
+		if err := validator.ValidateRepository(repo, providers.NewResolver(cfg, filepath.Dir(repoPath))); err != nil {
 			return err
 		}
 
@@ -62,8 +69,13 @@ var componentTreeCmd = &cobra.Command{
 			return err
 		}
 
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
 		// Validate before proceeding
-		if err := validator.ValidateRepository(repo); err != nil {
+		if err := validator.ValidateRepository(repo, providers.NewResolver(cfg, filepath.Dir(repoPath))); err != nil {
 			return err
 		}
 
@@ -119,8 +131,13 @@ var componentDescribeCmd = &cobra.Command{
 			return err
 		}
 
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
 		// Validate before proceeding
-		if err := validator.ValidateRepository(repo); err != nil {
+		if err := validator.ValidateRepository(repo, providers.NewResolver(cfg, filepath.Dir(repoPath))); err != nil {
 			return err
 		}
 
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sourceplane/sourceplane/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// configFlags holds the persistent --cache-path, --providers-path and
+// --registry-url flags, by config key
+var configFlags = make(map[string]*string)
+
+var configSetProject bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change Sourceplane settings",
+	Long: `Settings are read, lowest precedence first, from defaults, the user config
+file (~/.sourceplane/config.yaml), the project config file (the nearest
+.sourceplane/config.yaml above the working directory), SOURCEPLANE_*
+environment variables and command-line flags.`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings and where they come from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range config.Keys() {
+			value, _ := cfg.Get(k.Name)
+			fmt.Printf("%s = %s (%s)\n", k.Name, value, configSourceLabel(cfg, k))
+		}
+
+		if cfg.UserFile != "" || cfg.ProjectFile != "" {
+			fmt.Println()
+		}
+		if cfg.UserFile != "" {
+			fmt.Printf("User config: %s\n", cfg.UserFile)
+		}
+		if cfg.ProjectFile != "" {
+			fmt.Printf("Project config: %s\n", cfg.ProjectFile)
+		}
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in the user or project config file",
+	Long: `Writes a setting to the user config file (~/.sourceplane/config.yaml), or
+with --project to the project config file (.sourceplane/config.yaml in the
+nearest directory that has one, otherwise the working directory). An empty
+value removes the setting.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := args[0], args[1]
+		if _, err := config.LookupKey(key); err != nil {
+			return err
+		}
+
+		path, err := config.UserConfigPath()
+		if err != nil {
+			return err
+		}
+		if configSetProject {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+			path = config.FindProjectConfig(cwd)
+			if path == "" {
+				path = filepath.Join(cwd, ".sourceplane", "config.yaml")
+			}
+		}
+
+		if err := config.SetInFile(path, key, value); err != nil {
+			return err
+		}
+		if value == "" {
+			fmt.Printf("Removed %s from %s\n", key, path)
+		} else {
+			fmt.Printf("Set %s = %s in %s\n", key, value, path)
+		}
+		return nil
+	},
+}
+
+// loadConfig loads the configuration and applies the config flags given on
+// the command line
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range config.Keys() {
+		if flag := cmd.Flags().Lookup(k.Flag); flag != nil && flag.Changed {
+			if err := cfg.SetFlag(k.Name, *configFlags[k.Name]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// configSourceLabel describes where a setting came from, e.g. "env
+// SOURCEPLANE_CACHE_PATH"
+func configSourceLabel(cfg *config.Config, k config.Key) string {
+	switch source := cfg.Source(k.Name); source {
+	case config.SourceEnv:
+		return "env " + k.Env
+	case config.SourceFlag:
+		return "flag --" + k.Flag
+	case config.SourceUser:
+		return "user " + cfg.UserFile
+	case config.SourceProject:
+		return "project " + cfg.ProjectFile
+	default:
+		return source
+	}
+}
+
+// addConfigFlags registers the persistent config flags on a root command
+func addConfigFlags(root *cobra.Command) {
+	for _, k := range config.Keys() {
+		value, ok := configFlags[k.Name]
+		if !ok {
+			value = new(string)
+			configFlags[k.Name] = value
+		}
+		root.PersistentFlags().StringVar(value, k.Flag, "", fmt.Sprintf("%s (config %s, env %s)", k.Description, k.Name, k.Env))
+	}
+}
+
+func init() {
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+
+	configSetCmd.Flags().BoolVar(&configSetProject, "project", false, "Write to the project config file instead of the user config file")
+
+	rootCmd.AddCommand(configCmd)
+}
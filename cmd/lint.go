@@ -2,105 +2,226 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/sourceplane/sourceplane/internal/diagnostics"
 	"github.com/sourceplane/sourceplane/internal/parser"
-	"github.com/sourceplane/sourceplane/internal/provider"
+	"github.com/sourceplane/sourceplane/internal/providers"
+	"github.com/sourceplane/sourceplane/internal/validator"
 	"github.com/spf13/cobra"
 )
 
+var (
+	lintFormat string
+	lintStrict bool
+)
+
+// lintRules describes the checks lint performs, for SARIF output
+var lintRules = []diagnostics.Rule{
+	{ID: "syntax", Description: "intent.yaml must be valid YAML matching the intent structure"},
+	{ID: "required-field", Description: "Required fields must be set"},
+	{ID: "kind", Description: "kind should be Repository or Intent"},
+	{ID: "no-components", Description: "An intent should define components"},
+	{ID: "duplicate-component", Description: "Component names must be unique"},
+	{ID: "component-type", Description: "Component types must name a provider and one of its kinds"},
+	{ID: "spec-schema", Description: "Component specs must match the provider's schema for their kind"},
+	{ID: "environment", Description: "Environment overlays must reference existing components"},
+	{ID: "path-pattern", Description: "Component paths and ignorePaths must be valid glob patterns"},
+	{ID: "unknown-field", Description: "Fields must be part of the intent format"},
+}
+
 var lintCmd = &cobra.Command{
 	Use:   "lint",
 	Short: "Validate the intent.yaml file",
-	Long:  "Check for errors and validate component definitions in intent.yaml",
+	Long: `Check for errors and validate component definitions in intent.yaml.
+
+Problems are reported with their position, e.g. intent.yaml:42:5. Use
+--format json or --format sarif for editors and code-scanning tools.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		textOutput := lintFormat == diagnostics.FormatText
+		if !textOutput {
+			if lintFormat != diagnostics.FormatJSON && lintFormat != diagnostics.FormatSARIF {
+				return fmt.Errorf("unsupported format: %s (expected text, json or sarif)", lintFormat)
+			}
+			// Keep stdout machine-readable
+			cmd.SilenceUsage = true
+		}
+
 		repoPath, err := parser.FindIntentYaml()
 		if err != nil {
 			return fmt.Errorf("error: %v", err)
 		}
+		displayPath := relativePath(repoPath)
 
-		fmt.Printf("Linting: %s\n\n", repoPath)
+		if textOutput {
+			fmt.Printf("Linting: %s\n\n", repoPath)
+		}
 
-		repo, err := parser.LoadRepository(repoPath)
+		repo, doc, err := parser.ParseRepository(repoPath)
 		if err != nil {
-			return fmt.Errorf("❌ Failed to parse: %v", err)
+			syntaxErr, ok := err.(*parser.SyntaxError)
+			if !ok {
+				return fmt.Errorf("❌ Failed to parse: %v", err)
+			}
+			diag := diagnostics.Diagnostic{
+				File:     displayPath,
+				Line:     syntaxErr.Line,
+				Severity: diagnostics.SeverityError,
+				Rule:     "syntax",
+				Message:  syntaxErr.Message,
+			}
+			if textOutput {
+				return fmt.Errorf("❌ Failed to parse: %s", diag)
+			}
+			if err := diagnostics.Write(os.Stdout, lintFormat, []diagnostics.Diagnostic{diag}, lintRules); err != nil {
+				return err
+			}
+			return fmt.Errorf("failed to parse %s", displayPath)
 		}
 
-		// Basic validation
-		errors := []string{}
-		warnings := []string{}
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		resolver := providers.NewResolver(cfg, filepath.Dir(repoPath))
+		diags := []diagnostics.Diagnostic{}
+		report := func(severity diagnostics.Severity, rule, path, message string) {
+			line, column := doc.Position(path)
+			diags = append(diags, diagnostics.Diagnostic{
+				File:     displayPath,
+				Line:     line,
+				Column:   column,
+				Severity: severity,
+				Rule:     rule,
+				Path:     path,
+				Message:  message,
+			})
+		}
+		reportError := func(rule, path, message string) {
+			report(diagnostics.SeverityError, rule, path, message)
+		}
+		reportWarning := func(rule, path, message string) {
+			report(diagnostics.SeverityWarning, rule, path, message)
+		}
 
 		// Check required fields
 		if repo.APIVersion == "" {
-			errors = append(errors, "apiVersion is required")
+			reportError("required-field", "apiVersion", "apiVersion is required")
 		}
 
 		if repo.Kind == "" {
-			errors = append(errors, "kind is required")
+			reportError("required-field", "kind", "kind is required")
 		} else if repo.Kind != "Repository" && repo.Kind != "Intent" {
-			warnings = append(warnings, fmt.Sprintf("kind should typically be 'Repository' or 'Intent', found '%s'", repo.Kind))
+			reportWarning("kind", "kind", fmt.Sprintf("kind should typically be 'Repository' or 'Intent', found '%s'", repo.Kind))
 		}
 
 		if repo.Metadata.Name == "" {
-			errors = append(errors, "metadata.name is required")
+			reportError("required-field", "metadata.name", "metadata.name is required")
 		}
 
 		// Check components
 		if len(repo.Components) == 0 {
-			warnings = append(warnings, "no components defined")
+			reportWarning("no-components", "components", "no components defined")
 		}
 
 		componentNames := make(map[string]bool)
 		for i, comp := range repo.Components {
+			componentPath := fmt.Sprintf("components[%d]", i)
 			if comp.Name == "" {
-				errors = append(errors, fmt.Sprintf("component[%d]: name is required", i))
+				reportError("required-field", componentPath, fmt.Sprintf("component[%d]: name is required", i))
 			} else {
 				if componentNames[comp.Name] {
-					errors = append(errors, fmt.Sprintf("duplicate component name: %s", comp.Name))
+					reportError("duplicate-component", componentPath+".name", fmt.Sprintf("duplicate component name: %s", comp.Name))
 				}
 				componentNames[comp.Name] = true
 			}
 
 			if comp.Type == "" {
-				errors = append(errors, fmt.Sprintf("component[%d] (%s): type is required", i, comp.Name))
+				reportError("required-field", componentPath, fmt.Sprintf("component[%d] (%s): type is required", i, comp.Name))
 				continue
 			}
 
 			// Validate provider for this component
-			providerName := provider.GetProviderNameFromType(comp.Type)
+			typePath := componentPath + ".type"
+			providerName := providers.GetProviderNameFromType(comp.Type)
 			if providerName == "" {
-				errors = append(errors, fmt.Sprintf("component '%s': invalid type format '%s' (expected: provider.kind)", comp.Name, comp.Type))
+				reportError("component-type", typePath, fmt.Sprintf("component '%s': invalid type format '%s' (expected: provider.kind)", comp.Name, comp.Type))
 				continue
 			}
 
-			// Load and validate against provider definition
-			providerMeta, err := provider.LoadProvider(providerName)
+			// Resolve the provider as declared in the intent: local, cached or remote
+			providerMeta, err := resolver.ForComponent(repo, comp.Type)
 			if err != nil {
-				errors = append(errors, fmt.Sprintf("component '%s': %v", comp.Name, err))
+				reportError("component-type", typePath, fmt.Sprintf("component '%s': %v", comp.Name, err))
 				continue
 			}
 
 			// Validate component type against provider's supported types
 			if err := providerMeta.ValidateComponentType(comp.Type); err != nil {
-				errors = append(errors, fmt.Sprintf("component '%s': %v", comp.Name, err))
+				reportError("component-type", typePath, fmt.Sprintf("component '%s': %v", comp.Name, err))
+				continue
+			}
+
+			// Validate the spec against the provider's schema for the kind
+			for _, problem := range validator.ValidateComponentSpec(i, comp.Type, validator.ComponentSpec(comp), providerMeta) {
+				reportError("spec-schema", problem.Path, problem.String())
+			}
+		}
+
+		// Check that environment overlays reference existing components
+		for _, problem := range validator.ValidateEnvironments(repo) {
+			reportError("environment", problem.Path, problem.String())
+		}
+
+		// Check the components' path globs
+		for _, problem := range validator.ValidatePathPatterns(repo) {
+			reportError("path-pattern", problem.Path, problem.String())
+		}
+
+		// Unknown fields are usually typos; they only fail the lint in strict mode
+		for _, field := range doc.UnknownFields() {
+			severity := diagnostics.SeverityWarning
+			if lintStrict {
+				severity = diagnostics.SeverityError
 			}
+			diags = append(diags, diagnostics.Diagnostic{
+				File:     displayPath,
+				Line:     field.Line,
+				Column:   field.Column,
+				Severity: severity,
+				Rule:     "unknown-field",
+				Path:     field.Path,
+				Message:  fmt.Sprintf("unknown field '%s'", field.Key),
+			})
 		}
 
-		// List available providers for helpful error messages
-		availableProviders, _ := provider.ListAvailableProviders()
-		if len(errors) > 0 && len(availableProviders) > 0 {
-			// This will be shown after errors
+		diagnostics.Sort(diags)
+		errorCount := diagnostics.Count(diags, diagnostics.SeverityError)
+
+		if !textOutput {
+			if err := diagnostics.Write(os.Stdout, lintFormat, diags, lintRules); err != nil {
+				return err
+			}
+			if errorCount > 0 {
+				return fmt.Errorf("validation failed with %d error(s)", errorCount)
+			}
+			return nil
 		}
 
 		// Display results
-		if len(errors) > 0 {
+		if errorCount > 0 {
 			fmt.Println("❌ Errors:")
-			for _, err := range errors {
-				fmt.Printf("  • %s\n", err)
+			for _, d := range diags {
+				if d.Severity == diagnostics.SeverityError {
+					fmt.Printf("  • %s\n", d)
+				}
 			}
 			fmt.Println()
 
 			// Show available providers if there were provider errors
-			availableProviders, err := provider.ListAvailableProviders()
+			availableProviders, err := resolver.LocalProviders()
 			if err == nil && len(availableProviders) > 0 {
 				fmt.Println("Available providers:")
 				for _, p := range availableProviders {
@@ -110,23 +231,28 @@ var lintCmd = &cobra.Command{
 			}
 		}
 
-		if len(warnings) > 0 {
+		if warningCount := diagnostics.Count(diags, diagnostics.SeverityWarning); warningCount > 0 {
 			fmt.Println("⚠️  Warnings:")
-			for _, warn := range warnings {
-				fmt.Printf("  • %s\n", warn)
+			for _, d := range diags {
+				if d.Severity == diagnostics.SeverityWarning {
+					fmt.Printf("  • %s\n", d)
+				}
 			}
 			fmt.Println()
 		}
 
-		if len(errors) == 0 && len(warnings) == 0 {
+		if len(diags) == 0 {
 			fmt.Println("✅ No issues found")
 			fmt.Printf("\nRepository: %s\n", repo.Metadata.Name)
 			fmt.Printf("Components: %d\n", len(repo.Components))
+			if len(repo.Environments) > 0 {
+				fmt.Printf("Environments: %d\n", len(repo.Environments))
+			}
 
 			// Show provider summary
 			providerTypes := make(map[string]int)
 			for _, comp := range repo.Components {
-				providerName := provider.GetProviderNameFromType(comp.Type)
+				providerName := providers.GetProviderNameFromType(comp.Type)
 				providerTypes[providerName]++
 			}
 
@@ -142,14 +268,30 @@ var lintCmd = &cobra.Command{
 			}
 		}
 
-		if len(errors) > 0 {
-			return fmt.Errorf("validation failed with %d error(s)", len(errors))
+		if errorCount > 0 {
+			return fmt.Errorf("validation failed with %d error(s)", errorCount)
 		}
 
 		return nil
 	},
 }
 
+// relativePath shortens path to be relative to the working directory when
+// it is inside it
+func relativePath(path string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
 func init() {
 	rootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().StringVar(&lintFormat, "format", diagnostics.FormatText, "Output format: text, json or sarif")
+	lintCmd.Flags().BoolVar(&lintStrict, "strict", false, "Treat unknown fields as errors")
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sourceplane/sourceplane/internal/models"
 	"github.com/sourceplane/sourceplane/internal/parser"
+	"github.com/sourceplane/sourceplane/internal/providers"
 	"github.com/sourceplane/sourceplane/internal/validator"
 	"github.com/spf13/cobra"
 )
@@ -38,6 +39,11 @@ var orgTreeCmd = &cobra.Command{
 			return err
 		}
 
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
 		if len(repos) == 0 {
 			fmt.Println("No repositories with sourceplane.yaml found")
 			return nil
@@ -53,7 +59,7 @@ var orgTreeCmd = &cobra.Command{
 			}
 
 			// Validate each repository
-			if err := validator.ValidateRepository(repo); err != nil {
+			if err := validator.ValidateRepository(repo, providers.NewResolver(cfg, filepath.Dir(repoPath))); err != nil {
 				fmt.Printf("⚠️  Validation failed for %s:\n%v\n", repo.Metadata.Name, err)
 				continue
 			}
@@ -105,6 +111,11 @@ var orgGraphCmd = &cobra.Command{
 			return err
 		}
 
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
 		if len(repos) == 0 {
 			fmt.Println("No repositories with sourceplane.yaml found")
 			return nil
@@ -122,7 +133,7 @@ var orgGraphCmd = &cobra.Command{
 			}
 
 			// Validate each repository
-			if err := validator.ValidateRepository(repo); err != nil {
+			if err := validator.ValidateRepository(repo, providers.NewResolver(cfg, filepath.Dir(repoPath))); err != nil {
 				fmt.Printf("⚠️  Skipping %s: validation failed\n", repo.Metadata.Name)
 				continue
 			}
@@ -181,37 +192,20 @@ var ciCmd = &cobra.Command{
 
 var ciRenderCmd = &cobra.Command{
 	Use:   "render",
-	Short: "Render CI/CD workflows from intent.yaml",
-	Long:  "Generate CI workflows based on repository components and provider",
+	Short: "Render CI/CD workflows from a thin-ci plan",
+	Long:  "Generate CI workflows from a plan produced by 'sp thin-ci plan'",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		repoPath, err := parser.FindIntentYaml()
-		if err != nil {
-			return fmt.Errorf("error: %v", err)
+		planFile, _ := cmd.Flags().GetString("plan")
+		outputFile, _ := cmd.Flags().GetString("output-file")
+
+		target := ""
+		if github, _ := cmd.Flags().GetBool("github"); github {
+			target = "github"
+		} else if gitlab, _ := cmd.Flags().GetBool("gitlab"); gitlab {
+			target = "gitlab"
 		}
 
-		repo, err := parser.LoadRepository(repoPath)
-		if err != nil {
-			return err
-		}
-
-		// Validate before proceeding
-		if err := validator.ValidateRepository(repo); err != nil {
-			return err
-		}
-
-		fmt.Printf("🔄 Rendering CI/CD for: %s\n\n", repo.Metadata.Name)
-
-		if repo.Provider == "" {
-			return fmt.Errorf("no provider specified in sourceplane.yaml")
-		}
-
-		fmt.Printf("Provider: %s\n", repo.Provider)
-		fmt.Printf("Components: %d\n\n", len(repo.Components))
-
-		fmt.Println("⚠️  Provider-based CI rendering not yet implemented")
-		fmt.Println("This will generate CI workflows based on component definitions")
-
-		return nil
+		return renderPlanFile(planFile, target, outputFile)
 	},
 }
 
@@ -252,6 +246,12 @@ func init() {
 	orgCmd.AddCommand(orgGraphCmd)
 	rootCmd.AddCommand(orgCmd)
 
+	ciRenderCmd.Flags().String("plan", "plan.json", "Path to plan file")
+	ciRenderCmd.Flags().Bool("github", false, "Render a GitHub Actions workflow (defaults to the plan target)")
+	ciRenderCmd.Flags().Bool("gitlab", false, "Render a GitLab CI pipeline (defaults to the plan target)")
+	ciRenderCmd.Flags().StringP("output-file", "f", "", "Write the rendered workflow to a file instead of stdout")
+	ciRenderCmd.MarkFlagsMutuallyExclusive("github", "gitlab")
+
 	ciCmd.AddCommand(ciRenderCmd)
 	rootCmd.AddCommand(ciCmd)
 }
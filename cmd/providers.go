@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/sourceplane/sourceplane/internal/providers"
+	"github.com/sourceplane/sourceplane/internal/semver"
 	"github.com/spf13/cobra"
 )
 
@@ -17,12 +19,21 @@ var providersInitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Download and cache providers from intent.yaml",
 	Long: `Downloads all providers specified in intent.yaml and caches them locally.
-Similar to 'terraform init', this ensures all required providers are available.`,
+Similar to 'terraform init', this ensures all required providers are available.
+
+The exact version, source and content hash of each remote provider are
+recorded in intent.lock next to intent.yaml. Providers that are already
+locked keep their locked version; plans use the locked versions and fail
+when a provider's content does not match its hash.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		intentFile, _ := cmd.Flags().GetString("intent")
 
 		fmt.Println("Initializing providers...")
-		if err := providers.InitProviders(intentFile); err != nil {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if err := providers.InitProviders(cfg, intentFile); err != nil {
 			return err
 		}
 
@@ -31,11 +42,135 @@ Similar to 'terraform init', this ensures all required providers are available.`
 	},
 }
 
+var providersUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Resolve providers afresh and update intent.lock",
+	Long: `Resolves every provider in intent.yaml again, ignoring the versions locked
+in intent.lock, and rewrites the lock file. Use it to move to newer provider
+releases or to accept a provider whose content changed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		intentFile, _ := cmd.Flags().GetString("intent")
+
+		fmt.Println("Upgrading providers...")
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if err := providers.UpgradeProviders(cfg, intentFile); err != nil {
+			return err
+		}
+
+		fmt.Println("\nProviders upgraded successfully!")
+		return nil
+	},
+}
+
+var providersSearchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search the provider registry",
+	Long: `Lists the providers in the registry whose name or description contains the
+query. Without a query, every provider is listed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := registryClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		query := ""
+		if len(args) > 0 {
+			query = args[0]
+		}
+		matches, err := client.Search(query)
+		if err != nil {
+			return err
+		}
+
+		if len(matches) == 0 {
+			fmt.Printf("No providers matching %q found in %s\n", query, client.URL())
+			return nil
+		}
+
+		fmt.Printf("Providers in %s:\n", client.URL())
+		for _, p := range matches {
+			fmt.Printf("  %s", p.Name)
+			if p.LatestVersion != "" {
+				fmt.Printf("@%s", p.LatestVersion)
+			}
+			fmt.Println()
+			if p.Description != "" {
+				fmt.Printf("    %s\n", p.Description)
+			}
+		}
+
+		return nil
+	},
+}
+
+var providersInfoCmd = &cobra.Command{
+	Use:   "info <namespace/name>",
+	Short: "Show a registry provider and its versions",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := registryClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		provider, err := client.Provider(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Provider: %s\n", provider.Name)
+		if provider.Description != "" {
+			fmt.Printf("Description: %s\n", provider.Description)
+		}
+		if provider.Repository != "" {
+			fmt.Printf("Repository: %s\n", provider.Repository)
+		}
+		fmt.Printf("Registry: %s\n", client.URL())
+
+		versions := make([]string, len(provider.Versions))
+		checksums := make(map[string]string, len(provider.Versions))
+		for i, v := range provider.Versions {
+			versions[i] = v.Version
+			checksums[v.Version] = v.SHA256
+		}
+		sorted := semver.Sort(versions)
+
+		fmt.Println("\nVersions:")
+		for i := len(sorted) - 1; i >= 0; i-- {
+			fmt.Printf("  %s  sha256:%s\n", sorted[i], strings.TrimPrefix(checksums[sorted[i]], "sha256:"))
+		}
+
+		fmt.Printf("\nUse it in intent.yaml:\n\n  providers:\n    <name>:\n      source: %s\n", provider.Name)
+		if len(sorted) > 0 {
+			fmt.Printf("      version: \"^%s\"\n", sorted[len(sorted)-1])
+		}
+
+		return nil
+	},
+}
+
+// registryClient creates a client for the configured registry
+func registryClient(cmd *cobra.Command) (*providers.RegistryClient, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return providers.NewRegistryClient(cfg.RegistryURL), nil
+}
+
 var providersListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all cached providers",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cache, err := providers.NewProviderCache()
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		cache, err := providers.NewProviderCache(cfg)
 		if err != nil {
 			return err
 		}
@@ -64,7 +199,11 @@ var providersClearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Clear the provider cache",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cache, err := providers.NewProviderCache()
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		cache, err := providers.NewProviderCache(cfg)
 		if err != nil {
 			return err
 		}
@@ -80,10 +219,14 @@ var providersClearCmd = &cobra.Command{
 
 func init() {
 	providersCmd.AddCommand(providersInitCmd)
+	providersCmd.AddCommand(providersUpgradeCmd)
+	providersCmd.AddCommand(providersSearchCmd)
+	providersCmd.AddCommand(providersInfoCmd)
 	providersCmd.AddCommand(providersListCmd)
 	providersCmd.AddCommand(providersClearCmd)
 
 	providersInitCmd.Flags().String("intent", "intent.yaml", "Path to intent.yaml file")
+	providersUpgradeCmd.Flags().String("intent", "intent.yaml", "Path to intent.yaml file")
 
 	rootCmd.AddCommand(providersCmd)
 }
@@ -39,10 +39,16 @@ func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	thinCIRootCmd.CompletionOptions.DisableDefaultCmd = true
 
+	// Settings such as the cache path can be given on any command
+	addConfigFlags(rootCmd)
+	addConfigFlags(thinCIRootCmd)
+
 	// Add thin-ci as subcommand to main CLI
 	rootCmd.AddCommand(thinCICmd)
 
-	// Add plan and run commands to standalone thin-ci CLI
+	// Add plan, run and render commands to standalone thin-ci CLI
 	thinCIRootCmd.AddCommand(thinCIPlanCmd)
 	thinCIRootCmd.AddCommand(thinCIRunCmd)
+	thinCIRootCmd.AddCommand(thinCIRenderCmd)
+	thinCIRootCmd.AddCommand(thinCIExplainCmd)
 }
@@ -1,17 +1,21 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
+	"github.com/sourceplane/sourceplane/internal/config"
 	"github.com/sourceplane/sourceplane/internal/models"
 	"github.com/sourceplane/sourceplane/internal/parser"
-	provider "github.com/sourceplane/sourceplane/internal/providers"
+	"github.com/sourceplane/sourceplane/internal/providers"
 	"github.com/sourceplane/sourceplane/internal/thinci"
 )
 
@@ -21,9 +25,16 @@ var (
 	thinCIBaseRef     string
 	thinCIHeadRef     string
 	thinCIChangedOnly bool
+	thinCIUncommitted bool
+	thinCIStrict      bool
+	thinCISet         []string
 	thinCIEnvironment string
 	thinCIOutput      string
 	intentPath        string
+
+	// Impact expansion through the relationship graph
+	thinCIIncludeDependents   bool
+	thinCIIncludeDependencies bool
 	
 	// Run command flags
 	runPlanFile   string
@@ -31,6 +42,14 @@ var (
 	runVerbose    bool
 	runDryRun     bool
 	runGitHub     bool
+	runAll        bool
+	runParallel   int
+	runKeepGoing  bool
+	runGrace      time.Duration
+	runStrict     bool
+	runStateDir   string
+	runReport     string
+	runReportFile string
 )
 
 var thinCICmd = &cobra.Command{
@@ -54,7 +73,10 @@ var thinCIRunCmd = &cobra.Command{
 	Short: "Execute a job from a plan locally",
 	Long: `Execute a specific job from a generated plan file.
 Runs pre-steps, main commands, and post-steps with verbose output.
-Useful for testing CI jobs locally before pushing to CI/CD platform.`,
+Useful for testing CI jobs locally before pushing to CI/CD platform.
+
+With --all every job in the plan is executed, scheduled by dependsOn with up to
+--parallelism jobs running at once, followed by a summary of statuses and durations.`,
 	RunE: runThinCIRun,
 }
 
@@ -66,6 +88,11 @@ func init() {
 	thinCIPlanCmd.Flags().StringVar(&thinCIBaseRef, "base", "main", "Base git ref for comparison")
 	thinCIPlanCmd.Flags().StringVar(&thinCIHeadRef, "head", "HEAD", "Head git ref for comparison")
 	thinCIPlanCmd.Flags().BoolVar(&thinCIChangedOnly, "changed-only", true, "Only include changed components")
+	thinCIPlanCmd.Flags().BoolVar(&thinCIIncludeDependents, "include-dependents", false, "Also plan components that depend on changed components, transitively")
+	thinCIPlanCmd.Flags().BoolVar(&thinCIIncludeDependencies, "include-dependencies", false, "Also plan components that changed components depend on, transitively")
+	thinCIPlanCmd.Flags().BoolVar(&thinCIUncommitted, "include-uncommitted", false, "Include staged, unstaged and untracked working tree changes")
+	thinCIPlanCmd.Flags().StringArrayVar(&thinCISet, "set", nil, "Override a job input: path=value, or component:path=value for one component (repeatable)")
+	thinCIPlanCmd.Flags().BoolVar(&thinCIStrict, "strict-templates", false, "Fail on templates referencing unknown inputs instead of leaving them unresolved")
 	thinCIPlanCmd.Flags().StringVarP(&thinCIEnvironment, "env", "e", "", "Target environment (prod, staging, etc.)")
 	thinCIPlanCmd.Flags().StringVarP(&thinCIOutput, "output", "o", "json", "Output format: json or yaml")
 	thinCIPlanCmd.Flags().StringVarP(&intentPath, "intent", "i", "", "Path to intent.yaml file (default: ./intent.yaml)")
@@ -75,13 +102,22 @@ func init() {
 
 	// Flags for run command
 	thinCIRunCmd.Flags().StringVarP(&runPlanFile, "plan", "p", "plan.json", "Path to plan file")
-	thinCIRunCmd.Flags().StringVar(&runJobID, "job-id", "", "Job ID to execute (required)")
+	thinCIRunCmd.Flags().StringVar(&runJobID, "job-id", "", "Job ID to execute")
+	thinCIRunCmd.Flags().BoolVar(&runAll, "all", false, "Execute every job in the plan in dependency order")
+	thinCIRunCmd.Flags().IntVar(&runParallel, "parallelism", 4, "Maximum number of jobs to run concurrently with --all")
+	thinCIRunCmd.Flags().BoolVar(&runKeepGoing, "keep-going", false, "With --all, keep running jobs unrelated to a failed job")
+	thinCIRunCmd.Flags().BoolVar(&runStrict, "strict-templates", false, "Fail on templates referencing missing keys instead of rendering them empty")
+	thinCIRunCmd.Flags().StringVar(&runStateDir, "state-dir", thinci.DefaultStateDir, "Directory where job outputs are kept between runs")
+	thinCIRunCmd.Flags().StringVar(&runReport, "report", "", "Write a run report: json or junit")
+	thinCIRunCmd.Flags().StringVar(&runReportFile, "report-file", "", "Report file path (default: thinci-report.json or thinci-report.xml)")
+	thinCIRunCmd.Flags().DurationVar(&runGrace, "grace-period", thinci.DefaultGracePeriod, "Time a cancelled or timed out command gets to exit before it is killed")
 	thinCIRunCmd.Flags().BoolVarP(&runVerbose, "verbose", "v", true, "Verbose output")
 	thinCIRunCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "Dry run mode (don't execute commands)")
 	thinCIRunCmd.Flags().BoolVar(&runGitHub, "github", false, "Running in GitHub Actions context")
 	
-	// Mark required flags
-	thinCIRunCmd.MarkFlagRequired("job-id")
+	// Either a single job or the whole plan must be selected
+	thinCIRunCmd.MarkFlagsOneRequired("job-id", "all")
+	thinCIRunCmd.MarkFlagsMutuallyExclusive("job-id", "all")
 
 	// Add plan command to thin-ci command (for use as subcommand of sp)
 	thinCICmd.AddCommand(thinCIPlanCmd)
@@ -101,10 +137,36 @@ func runThinCIPlan(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("target platform required: use --github or --gitlab")
 	}
 
+	planReq, intents, registry, err := preparePlanRequest(cmd)
+	if err != nil {
+		return err
+	}
+	planReq.Target = target
+	planReq.Mode = thinCIMode
+	planReq.ChangedOnly = thinCIChangedOnly
+	planReq.SetValues = thinCISet
+	planReq.StrictTemplates = thinCIStrict
+
+	// Generate plan
+	planner := thinci.NewPlanner(registry)
+	plan, err := planner.GeneratePlan(planReq, intents)
+	if err != nil {
+		return fmt.Errorf("failed to generate plan: %w", err)
+	}
+
+	// Output plan
+	return outputPlan(plan, thinCIOutput)
+}
+
+// preparePlanRequest loads the intent, the changed files and the providers,
+// and creates the part of a plan request that change detection needs
+func preparePlanRequest(cmd *cobra.Command) (thinci.PlanRequest, []*models.Repository, *thinci.ProviderRegistry, error) {
+	var planReq thinci.PlanRequest
+
 	// Get current working directory
 	cwd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
+		return planReq, nil, nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
 
 	// Determine intent file path
@@ -116,50 +178,65 @@ func runThinCIPlan(cmd *cobra.Command, args []string) error {
 
 	// Check if intent.yaml exists
 	if _, err := os.Stat(intentPath); os.IsNotExist(err) {
-		return fmt.Errorf("could not find intent.yaml at %s", intentPath)
+		return planReq, nil, nil, fmt.Errorf("could not find intent.yaml at %s", intentPath)
 	}
 
 	// Load the intent file
 	intentFiles := []string{intentPath}
 	intents, err := loadIntentFiles(intentFiles)
 	if err != nil {
-		return fmt.Errorf("failed to load intent files: %w", err)
+		return planReq, nil, nil, fmt.Errorf("failed to load intent files: %w", err)
 	}
 
 	// Get changed files from git
 	changedFiles, err := getChangedFiles(cwd, thinCIBaseRef, thinCIHeadRef)
 	if err != nil {
-		return fmt.Errorf("failed to get changed files: %w", err)
+		return planReq, nil, nil, fmt.Errorf("failed to get changed files: %w", err)
 	}
 
-	// Load provider registry (from intent file and local/remote sources)
-	registry, err := loadProviderRegistry(cwd, intents)
+	// Changed files are relative to the repository root, the paths in the
+	// intent to its directory
+	intentDir, err := thinci.RelativeToRepository(cwd, filepath.Dir(intentPath))
+	if err != nil {
+		return planReq, nil, nil, err
+	}
+
+	// Compare the intents with the base, so that an intent change only
+	// affects the components it touches
+	intentSources, err := thinci.LoadIntentSources(cwd, intentFiles, thinci.DiffOptions{
+		BaseRef: thinCIBaseRef,
+		HeadRef: thinCIHeadRef,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to load providers: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: %v; treating every component of a changed intent as affected\n", err)
+		intentSources = nil
 	}
 
-	// Create plan request
-	planReq := thinci.PlanRequest{
+	// Load provider registry (from intent file and local/remote sources,
+	// pinned by the intent.lock next to the intent file)
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return planReq, nil, nil, err
+	}
+	registry, err := loadProviderRegistry(cfg, filepath.Dir(intentPath), intents)
+	if err != nil {
+		return planReq, nil, nil, fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	planReq = thinci.PlanRequest{
 		BaseRef:        thinCIBaseRef,
 		HeadRef:        thinCIHeadRef,
 		ChangedFiles:   changedFiles,
 		RepositoryPath: cwd,
 		IntentFiles:    intentFiles,
-		Target:         target,
-		Mode:           thinCIMode,
-		ChangedOnly:    thinCIChangedOnly,
+		IntentDir:      intentDir,
+		IntentSources:  intentSources,
 		Environment:    thinCIEnvironment,
-	}
 
-	// Generate plan
-	planner := thinci.NewPlanner(registry)
-	plan, err := planner.GeneratePlan(planReq, intents)
-	if err != nil {
-		return fmt.Errorf("failed to generate plan: %w", err)
+		IncludeDependents:   thinCIIncludeDependents,
+		IncludeDependencies: thinCIIncludeDependencies,
 	}
-
-	// Output plan
-	return outputPlan(plan, thinCIOutput)
+	return planReq, intents, registry, nil
 }
 
 // findIntentFiles recursively finds all intent.yaml files
@@ -208,276 +285,36 @@ func loadIntentFiles(paths []string) ([]*models.Repository, error) {
 
 // getChangedFiles gets list of changed files from git
 func getChangedFiles(repoPath, baseRef, headRef string) ([]string, error) {
-	// This is a placeholder - in production, use git command or library
-	// For now, return a mock list for testing
-
-	// TODO: Implement actual git diff
-	// Example: git diff --name-only base..head
-
-	return []string{
-		"intent.yaml",
-		"terraform/vpc-network/main.tf",
-		"helm/api-service/values.yaml",
-	}, nil
+	return thinci.GetChangedFiles(repoPath, thinci.DiffOptions{
+		BaseRef:            baseRef,
+		HeadRef:            headRef,
+		IncludeUncommitted: thinCIUncommitted,
+	})
 }
 
-// loadProviderRegistry loads all providers and creates a registry
-// loadProviderRegistry loads all providers from intent files and local/remote sources
-func loadProviderRegistry(repoPath string, intents []*models.Repository) (*thinci.ProviderRegistry, error) {
-	registry := thinci.NewProviderRegistry()
-	fetcher, err := thinci.NewProviderFetcher()
+// loadProviderRegistry resolves the providers the intents use, local or
+// remote, and creates a registry
+func loadProviderRegistry(cfg *config.Config, repoPath string, intents []*models.Repository) (*thinci.ProviderRegistry, error) {
+	resolved, err := providers.NewResolver(cfg, repoPath).ResolveAll(intents)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create provider fetcher: %w", err)
-	}
-
-	// Collect all providers from all intent files
-	providerSources := make(map[string]models.Provider)
-	for _, intent := range intents {
-		for name, provider := range intent.Providers {
-			if _, exists := providerSources[name]; !exists {
-				providerSources[name] = provider
-			}
-		}
-	}
-
-	// Load each provider
-	for name, providerConfig := range providerSources {
-		var providerPath string
-		
-		// Check if source is remote or local
-		if providerConfig.Source != "" && thinci.IsRemoteSource(providerConfig.Source) {
-			// Fetch remote provider
-			path, err := fetcher.FetchProvider(providerConfig.Source, providerConfig.Version)
-			if err != nil {
-				return nil, fmt.Errorf("failed to fetch provider %s: %w", name, err)
-			}
-			providerPath = filepath.Join(path, "provider.yaml")
-		} else {
-			// Try local providers directory first
-			providerPath = filepath.Join(repoPath, "providers", name, "provider.yaml")
-			
-			// If not found locally, search up the directory tree
-			if _, err := os.Stat(providerPath); os.IsNotExist(err) {
-				searchPath := repoPath
-				found := false
-				for i := 0; i < 5; i++ {
-					testPath := filepath.Join(searchPath, "providers", name, "provider.yaml")
-					if _, err := os.Stat(testPath); err == nil {
-						providerPath = testPath
-						found = true
-						break
-					}
-					searchPath = filepath.Dir(searchPath)
-				}
-				
-				if !found {
-					return nil, fmt.Errorf("provider '%s' not found locally and no remote source specified", name)
-				}
-			}
-		}
-
-		// Verify provider.yaml exists
-		if _, err := os.Stat(providerPath); os.IsNotExist(err) {
-			return nil, fmt.Errorf("provider.yaml not found for provider %s at %s", name, providerPath)
-		}
-
-		fmt.Fprintf(os.Stderr, "Loading provider: %s from %s\n", name, providerPath)
-
-		// Load provider metadata
-		providerMeta, err := loadProviderMetadata(providerPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load provider %s: %w", name, err)
-		}
-
-		registry.RegisterProvider(providerMeta)
+		return nil, err
 	}
 
-	// If no providers were loaded from intents, fall back to loading from local providers directory
-	if len(providerSources) == 0 {
-		fmt.Fprintln(os.Stderr, "No providers defined in intent files, searching local providers directory...")
-		return loadLocalProviders(repoPath)
+	names := make([]string, 0, len(resolved))
+	for name := range resolved {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	return registry, nil
-}
-
-// loadLocalProviders loads providers from local providers directory (fallback/legacy)
-func loadLocalProviders(repoPath string) (*thinci.ProviderRegistry, error) {
 	registry := thinci.NewProviderRegistry()
-
-	// For thin-ci, always load providers from providers directory
-	// because we need the full thinCI configuration from provider.yaml
-	providersDir := filepath.Join(repoPath, "providers")
-	
-	// Check if we're in a subdirectory - walk up to find providers dir
-	searchPath := repoPath
-	for i := 0; i < 5; i++ { // Max 5 levels up
-		testPath := filepath.Join(searchPath, "providers")
-		if _, err := os.Stat(testPath); err == nil {
-			providersDir = testPath
-			break
-		}
-		searchPath = filepath.Dir(searchPath)
-	}
-
-	if _, err := os.Stat(providersDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("providers directory not found (searched from %s)", repoPath)
-	}
-
-	// Read all provider directories
-	entries, err := os.ReadDir(providersDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read providers directory: %w", err)
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		providerName := entry.Name()
-		providerPath := filepath.Join(providersDir, providerName, "provider.yaml")
-
-		// Check if provider.yaml exists
-		if _, err := os.Stat(providerPath); os.IsNotExist(err) {
-			continue
-		}
-
-		fmt.Fprintf(os.Stderr, "Loading provider: %s\n", providerName)
-
-		// Load provider metadata directly from provider.yaml
-		providerMeta, err := loadProviderMetadata(providerPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load provider %s: %w", providerName, err)
-		}
-
-		registry.RegisterProvider(providerMeta)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "Loading provider: %s from %s\n", name, resolved[name].Dir)
+		registry.RegisterProvider(resolved[name])
 	}
 
 	return registry, nil
 }
 
-// convertToProviderMetadata converts a provider.Provider to thinci.ProviderMetadata
-func convertToProviderMetadata(name string, prov *provider.Provider) (*thinci.ProviderMetadata, error) {
-	// Extract thin-ci configuration from provider
-	thinCIConfig, ok := prov.Extensions["thinCI"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("provider %s missing thinCI configuration", name)
-	}
-
-	// Convert actions
-	actionsRaw, ok := thinCIConfig["actions"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("provider %s missing thinCI actions", name)
-	}
-
-	var actions []thinci.ProviderAction
-	for _, actionRaw := range actionsRaw {
-		actionData, err := yaml.Marshal(actionRaw)
-		if err != nil {
-			return nil, err
-		}
-
-		var action thinci.ProviderAction
-		if err := yaml.Unmarshal(actionData, &action); err != nil {
-			return nil, err
-		}
-
-		actions = append(actions, action)
-	}
-
-	// Extract defaults
-	defaults := make(map[string]any)
-	if defaultsRaw, ok := thinCIConfig["defaults"].(map[string]interface{}); ok {
-		defaults = defaultsRaw
-	}
-
-	// Extract ordering
-	var ordering []string
-	if orderingRaw, ok := thinCIConfig["ordering"].([]interface{}); ok {
-		for _, o := range orderingRaw {
-			if s, ok := o.(string); ok {
-				ordering = append(ordering, s)
-			}
-		}
-	}
-
-	return &thinci.ProviderMetadata{
-		Name:    name,
-		Version: prov.Version,
-		ThinCI: thinci.ThinCIConfig{
-			Actions:  actions,
-			Defaults: defaults,
-			Ordering: ordering,
-		},
-	}, nil
-}
-
-// loadProviderMetadata loads provider metadata including thin-ci config
-func loadProviderMetadata(path string) (*thinci.ProviderMetadata, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var raw map[string]interface{}
-	if err := yaml.Unmarshal(data, &raw); err != nil {
-		return nil, err
-	}
-
-	name, _ := raw["name"].(string)
-	version, _ := raw["version"].(string)
-
-	// Parse thin-ci configuration
-	thinCIConfig := thinci.ThinCIConfig{
-		Actions:  []thinci.ProviderAction{},
-		Defaults: make(map[string]any),
-	}
-
-	if thinCIRaw, ok := raw["thinCI"].(map[string]interface{}); ok {
-		// Parse actions
-		if actionsRaw, ok := thinCIRaw["actions"].([]interface{}); ok {
-			for _, actionRaw := range actionsRaw {
-				// Marshal back to YAML and unmarshal into ProviderAction struct
-				// This properly handles all fields including jobTemplate
-				actionData, err := yaml.Marshal(actionRaw)
-				if err != nil {
-					continue
-				}
-
-				var action thinci.ProviderAction
-				if err := yaml.Unmarshal(actionData, &action); err != nil {
-					continue
-				}
-
-				thinCIConfig.Actions = append(thinCIConfig.Actions, action)
-			}
-		}
-
-		// Parse defaults
-		if defaultsRaw, ok := thinCIRaw["defaults"].(map[string]interface{}); ok {
-			thinCIConfig.Defaults = defaultsRaw
-		}
-
-		// Parse ordering
-		if orderingRaw, ok := thinCIRaw["ordering"].([]interface{}); ok {
-			ordering := make([]string, 0, len(orderingRaw))
-			for _, o := range orderingRaw {
-				if s, ok := o.(string); ok {
-					ordering = append(ordering, s)
-				}
-			}
-			thinCIConfig.Ordering = ordering
-		}
-	}
-
-	return &thinci.ProviderMetadata{
-		Name:    name,
-		Version: version,
-		ThinCI:  thinCIConfig,
-	}, nil
-}
-
 // outputPlan outputs the plan in the specified format
 func outputPlan(plan *thinci.Plan, format string) error {
 	var output []byte
@@ -521,15 +358,21 @@ func getInt(m map[string]interface{}, key string) int {
 // runThinCIRun executes a specific job from a plan file
 func runThinCIRun(cmd *cobra.Command, args []string) error {
 	// Load the plan file
-	planData, err := os.ReadFile(runPlanFile)
+	plan, err := loadPlanFile(runPlanFile)
 	if err != nil {
-		return fmt.Errorf("failed to read plan file: %w", err)
+		return err
 	}
-	
-	// Parse the plan
-	var plan thinci.Plan
-	if err := json.Unmarshal(planData, &plan); err != nil {
-		return fmt.Errorf("failed to parse plan file: %w", err)
+
+	if runReport != "" && runReport != thinci.ReportFormatJSON && runReport != thinci.ReportFormatJUnit {
+		return fmt.Errorf("unsupported report format: %s (expected json or junit)", runReport)
+	}
+
+	// Cancel running commands on Ctrl-C / SIGTERM
+	ctx, stop := thinci.NotifyInterrupt(context.Background())
+	defer stop()
+
+	if runAll {
+		return runThinCIPlanJobs(ctx, plan)
 	}
 	
 	// Find the job with the specified ID
@@ -547,6 +390,9 @@ func runThinCIRun(cmd *cobra.Command, args []string) error {
 	
 	// Create executor
 	executor := thinci.NewExecutor(runVerbose, runDryRun)
+	executor.SetGracePeriod(runGrace)
+	executor.SetStateDir(runStateDir)
+	executor.SetStrictTemplates(runStrict)
 	
 	// Execute the job
 	fmt.Printf("Sourceplane Thin-CI Job Executor\n")
@@ -556,9 +402,84 @@ func runThinCIRun(cmd *cobra.Command, args []string) error {
 		fmt.Println("\n⚠️  DRY RUN MODE - Commands will not be executed")
 	}
 	
-	if err := executor.ExecuteJob(*targetJob); err != nil {
-		return fmt.Errorf("job execution failed: %w", err)
+	result := executor.RunJob(ctx, *targetJob)
+	if err := writeRunReport(plan, []thinci.JobResult{result}); err != nil {
+		return err
 	}
 	
+	switch {
+	case result.Status == thinci.JobStatusSucceeded:
+	case result.AllowedFailure:
+		fmt.Printf("\n⚠️  Job failed but continueOnError is set: %s\n", result.Error)
+	default:
+		return fmt.Errorf("job execution %s: %s", result.Status, result.Error)
+	}
+	
+	return nil
+}
+
+// runThinCIPlanJobs executes every job of a plan and prints a summary
+func runThinCIPlanJobs(ctx context.Context, plan *thinci.Plan) error {
+	fmt.Printf("Sourceplane Thin-CI Plan Executor\n")
+	fmt.Printf("Plan: %s (%d jobs, parallelism %d)\n", runPlanFile, len(plan.Jobs), runParallel)
+
+	if runDryRun {
+		fmt.Println("\n⚠️  DRY RUN MODE - Commands will not be executed")
+	}
+
+	runner := thinci.NewPlanRunner(plan, thinci.PlanRunOptions{
+		Verbose:     runVerbose,
+		DryRun:      runDryRun,
+		Parallelism: runParallel,
+		KeepGoing:   runKeepGoing,
+		GracePeriod: runGrace,
+		StateDir:    runStateDir,
+
+		StrictTemplates: runStrict,
+	})
+
+	results, err := runner.Run(ctx)
+	thinci.PrintRunSummary(os.Stdout, results)
+	if reportErr := writeRunReport(plan, results); reportErr != nil {
+		return reportErr
+	}
+	if err != nil {
+		return fmt.Errorf("plan execution failed: %w", err)
+	}
+
+	return nil
+}
+
+// writeRunReport writes the --report file for a run, if one was requested
+func writeRunReport(plan *thinci.Plan, results []thinci.JobResult) error {
+	if runReport == "" {
+		return nil
+	}
+
+	path := runReportFile
+	if path == "" {
+		path = "thinci-report.json"
+		if runReport == thinci.ReportFormatJUnit {
+			path = "thinci-report.xml"
+		}
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer f.Close()
+
+	if err := thinci.WriteReport(f, runReport, plan, results); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	fmt.Printf("Report written to %s\n", path)
 	return nil
 }
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sourceplane/sourceplane/internal/thinci"
+)
+
+var (
+	// Explain command flags
+	explainComponent string
+	explainOutput    string
+)
+
+var thinCIExplainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Explain why a component is or is not in the plan",
+	Long: `Explain the change detection decision for one component: the rules that were
+evaluated for it, the changed files each rule matched, and the relationships
+that pulled it into the plan. Takes the same change detection flags as plan.`,
+	Args: cobra.NoArgs,
+	RunE: runThinCIExplain,
+}
+
+func init() {
+	thinCIExplainCmd.Flags().StringVarP(&explainComponent, "component", "c", "", "Component to explain")
+	thinCIExplainCmd.Flags().StringVar(&thinCIBaseRef, "base", "main", "Base git ref for comparison")
+	thinCIExplainCmd.Flags().StringVar(&thinCIHeadRef, "head", "HEAD", "Head git ref for comparison")
+	thinCIExplainCmd.Flags().BoolVar(&thinCIIncludeDependents, "include-dependents", false, "Also plan components that depend on changed components, transitively")
+	thinCIExplainCmd.Flags().BoolVar(&thinCIIncludeDependencies, "include-dependencies", false, "Also plan components that changed components depend on, transitively")
+	thinCIExplainCmd.Flags().BoolVar(&thinCIUncommitted, "include-uncommitted", false, "Include staged, unstaged and untracked working tree changes")
+	thinCIExplainCmd.Flags().StringVarP(&thinCIEnvironment, "env", "e", "", "Target environment (prod, staging, etc.)")
+	thinCIExplainCmd.Flags().StringVarP(&explainOutput, "output", "o", "text", "Output format: text or json")
+	thinCIExplainCmd.Flags().StringVarP(&intentPath, "intent", "i", "", "Path to intent.yaml file (default: ./intent.yaml)")
+	thinCIExplainCmd.MarkFlagRequired("component")
+
+	thinCICmd.AddCommand(thinCIExplainCmd)
+}
+
+func runThinCIExplain(cmd *cobra.Command, args []string) error {
+	planReq, intents, registry, err := preparePlanRequest(cmd)
+	if err != nil {
+		return err
+	}
+
+	planner := thinci.NewPlanner(registry)
+	explanation, err := planner.Explain(planReq, intents, explainComponent)
+	if err != nil {
+		return err
+	}
+
+	switch explainOutput {
+	case "json":
+		data, err := json.MarshalIndent(explanation, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal explanation: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	case "text":
+		printExplanation(explanation, planReq)
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s (use text or json)", explainOutput)
+	}
+}
+
+// printExplanation writes an explanation in a readable form
+func printExplanation(e *thinci.Explanation, req thinci.PlanRequest) {
+	fmt.Printf("Component: %s (%s)\n", e.Component, e.Type)
+	fmt.Printf("Changes:   %s...%s, %d changed file(s)\n", req.BaseRef, req.HeadRef, len(e.ChangedFiles))
+	fmt.Println()
+
+	if e.Planned {
+		fmt.Println("Planned: yes")
+		fmt.Printf("Reason:  %s\n", e.Reason)
+		if len(e.ImpactPath) > 0 {
+			fmt.Printf("Impact:  %s\n", strings.Join(e.ImpactPath, " -> "))
+		}
+		for _, path := range e.AffectedPaths {
+			fmt.Printf("  %s\n", path)
+		}
+	} else {
+		fmt.Println("Planned: no")
+		fmt.Println("Reason:  no change detection rule matched a changed file")
+	}
+	fmt.Println()
+
+	fmt.Println("Rules:")
+	for _, rule := range e.Rules {
+		fmt.Printf("  %s (%s)\n", rule.Rule, rule.Source)
+		if len(rule.Patterns) > 0 {
+			fmt.Printf("    patterns: %s\n", strings.Join(rule.Patterns, ", "))
+		}
+		if rule.Detail != "" {
+			fmt.Printf("    %s\n", rule.Detail)
+		}
+		if len(rule.Matched) == 0 {
+			fmt.Println("    no match")
+		}
+		for _, file := range rule.Matched {
+			fmt.Printf("    matched: %s\n", file)
+		}
+	}
+	fmt.Println()
+
+	fmt.Println("Relationships:")
+	fmt.Printf("  depends on:     %s\n", listOrNone(e.Dependencies))
+	fmt.Printf("  depended on by: %s\n", listOrNone(e.Dependents))
+	if !e.Planned && len(e.Dependencies)+len(e.Dependents) > 0 && !req.IncludeDependents && !req.IncludeDependencies {
+		fmt.Println("  (use --include-dependents or --include-dependencies to plan related components)")
+	}
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
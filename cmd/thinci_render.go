@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourceplane/sourceplane/internal/thinci"
+)
+
+var (
+	// Render command flags
+	renderOutputFile string
+)
+
+var thinCIRenderCmd = &cobra.Command{
+	Use:   "render [plan-file]",
+	Short: "Render a plan into a CI pipeline definition",
+	Long: `Render a generated plan file into a native CI pipeline definition.
+With --github a complete GitHub Actions workflow is produced: one job per plan job,
+with needs, environments, permissions, caches, artifacts and run steps.
+With --gitlab a .gitlab-ci.yml pipeline is produced with stages derived from action order.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runThinCIRender,
+}
+
+func init() {
+	thinCIRenderCmd.Flags().Bool("github", false, "Render a GitHub Actions workflow")
+	thinCIRenderCmd.Flags().Bool("gitlab", false, "Render a GitLab CI pipeline")
+	thinCIRenderCmd.Flags().StringVarP(&renderOutputFile, "output-file", "f", "", "Write the rendered pipeline to a file instead of stdout")
+	thinCIRenderCmd.MarkFlagsOneRequired("github", "gitlab")
+	thinCIRenderCmd.MarkFlagsMutuallyExclusive("github", "gitlab")
+
+	thinCICmd.AddCommand(thinCIRenderCmd)
+}
+
+func runThinCIRender(cmd *cobra.Command, args []string) error {
+	planFile := "plan.json"
+	if len(args) > 0 {
+		planFile = args[0]
+	}
+
+	target := ""
+	if github, _ := cmd.Flags().GetBool("github"); github {
+		target = "github"
+	} else if gitlab, _ := cmd.Flags().GetBool("gitlab"); gitlab {
+		target = "gitlab"
+	}
+
+	return renderPlanFile(planFile, target, renderOutputFile)
+}
+
+// renderPlanFile loads a plan and writes the rendered pipeline to outputFile (or stdout)
+func renderPlanFile(planFile, target, outputFile string) error {
+	plan, err := loadPlanFile(planFile)
+	if err != nil {
+		return err
+	}
+
+	if target == "" {
+		target = plan.Target
+	}
+
+	output, err := thinci.RenderPlan(plan, target)
+	if err != nil {
+		return fmt.Errorf("failed to render plan: %w", err)
+	}
+
+	if outputFile == "" {
+		fmt.Print(string(output))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(outputFile, output, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputFile, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Rendered %d job(s) to %s\n", len(plan.Jobs), outputFile)
+	return nil
+}
+
+// loadPlanFile reads a plan generated by `thinci plan` in JSON or YAML format
+func loadPlanFile(path string) (*thinci.Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	var plan thinci.Plan
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		err = yaml.Unmarshal(data, &plan)
+	} else {
+		err = json.Unmarshal(data, &plan)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %w", err)
+	}
+
+	return &plan, nil
+}
@@ -1,13 +1,48 @@
+// Package config loads the CLI configuration. Settings are read, lowest
+// precedence first, from built-in defaults, the user config file
+// (~/.sourceplane/config.yaml), the project config file (the nearest
+// .sourceplane/config.yaml above the working directory), SOURCEPLANE_*
+// environment variables and command-line flags:
+//
+//	cache:
+//	  path: /var/cache/sourceplane   # SOURCEPLANE_CACHE_PATH, --cache-path
+//	providers:
+//	  path: ./providers              # SOURCEPLANE_PROVIDERS_PATH, --providers-path
+//	registry:
+//	  url: https://registry.example.com  # SOURCEPLANE_REGISTRY_URL, --registry-url
+//
+// Relative paths in a config file are relative to the directory holding its
+// .sourceplane directory; relative paths from the environment or flags are
+// relative to the working directory.
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRegistryURL is the provider registry used when none is configured
+const DefaultRegistryURL = "https://registry.sourceplane.io"
+
+// Sources a setting can come from
+const (
+	SourceDefault = "default"
+	SourceUser    = "user"
+	SourceProject = "project"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
 )
 
 // Config holds the CLI configuration
 type Config struct {
-	// ProvidersPath is the path to the providers directory
+	// ProvidersPath is the path to the local providers directory. When empty,
+	// a providers/ directory is searched for next to the intent and in its
+	// parent directories.
 	ProvidersPath string
 
 	// CachePath is the path to the cache directory
@@ -15,6 +50,70 @@ type Config struct {
 
 	// WorkingDir is the current working directory
 	WorkingDir string
+
+	// RegistryURL is the provider registry for registry sources
+	RegistryURL string
+
+	// UserFile and ProjectFile are the config files that were found
+	UserFile    string
+	ProjectFile string
+
+	sources map[string]string
+}
+
+// Key describes a configuration setting
+type Key struct {
+	Name        string
+	Env         string
+	Flag        string
+	Description string
+	path        bool
+	field       func(*Config) *string
+}
+
+var keys = []Key{
+	{
+		Name:        "cache.path",
+		Env:         "SOURCEPLANE_CACHE_PATH",
+		Flag:        "cache-path",
+		Description: "Directory for downloaded providers and other cached data",
+		path:        true,
+		field:       func(c *Config) *string { return &c.CachePath },
+	},
+	{
+		Name:        "providers.path",
+		Env:         "SOURCEPLANE_PROVIDERS_PATH",
+		Flag:        "providers-path",
+		Description: "Local providers directory (default: providers/ next to the intent or above it)",
+		path:        true,
+		field:       func(c *Config) *string { return &c.ProvidersPath },
+	},
+	{
+		Name:        "registry.url",
+		Env:         "SOURCEPLANE_REGISTRY_URL",
+		Flag:        "registry-url",
+		Description: "Provider registry for namespace/name provider sources",
+		field:       func(c *Config) *string { return &c.RegistryURL },
+	},
+}
+
+// Keys returns the supported settings
+func Keys() []Key {
+	return append([]Key(nil), keys...)
+}
+
+// LookupKey returns the setting with the given name
+func LookupKey(name string) (Key, error) {
+	for _, k := range keys {
+		if k.Name == name {
+			return k, nil
+		}
+	}
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.Name
+	}
+	return Key{}, fmt.Errorf("unknown config key %q (supported: %s)", name, strings.Join(names, ", "))
 }
 
 // Default returns a default configuration
@@ -22,30 +121,247 @@ func Default() *Config {
 	homeDir, _ := os.UserHomeDir()
 	cwd, _ := os.Getwd()
 
-	return &Config{
-		ProvidersPath: filepath.Join(cwd, "providers"),
-		CachePath:     filepath.Join(homeDir, ".sourceplane"),
-		WorkingDir:    cwd,
+	cfg := &Config{
+		CachePath:   filepath.Join(homeDir, ".sourceplane"),
+		WorkingDir:  cwd,
+		RegistryURL: DefaultRegistryURL,
+		sources:     make(map[string]string),
 	}
+	for _, k := range keys {
+		cfg.sources[k.Name] = SourceDefault
+	}
+	return cfg
 }
 
-// Load loads configuration from environment or returns default
+// Load loads configuration from the config files and the environment
 func Load() (*Config, error) {
 	cfg := Default()
 
-	// Override with environment variables if set
-	if providersPath := os.Getenv("SOURCEPLANE_PROVIDERS_PATH"); providersPath != "" {
-		cfg.ProvidersPath = providersPath
+	if userFile, err := UserConfigPath(); err == nil {
+		if err := cfg.loadFile(userFile, SourceUser); err != nil {
+			return nil, err
+		}
+	}
+	if projectFile := FindProjectConfig(cfg.WorkingDir); projectFile != "" && projectFile != cfg.UserFile {
+		if err := cfg.loadFile(projectFile, SourceProject); err != nil {
+			return nil, err
+		}
 	}
 
-	if cachePath := os.Getenv("SOURCEPLANE_CACHE_PATH"); cachePath != "" {
-		cfg.CachePath = cachePath
+	// Override with environment variables if set
+	for _, k := range keys {
+		if value := os.Getenv(k.Env); value != "" {
+			cfg.set(k, value, SourceEnv, cfg.WorkingDir)
+		}
 	}
 
 	return cfg, nil
 }
 
+// UserConfigPath returns the path of the user config file
+func UserConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".sourceplane", "config.yaml"), nil
+}
+
+// FindProjectConfig returns the nearest .sourceplane/config.yaml in start or
+// its parent directories, or "" when there is none
+func FindProjectConfig(start string) string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ".sourceplane", "config.yaml")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadFile applies the settings in a config file, if it exists
+func (c *Config) loadFile(path, source string) error {
+	values, err := readFile(path)
+	if err != nil || values == nil {
+		return err
+	}
+
+	// Paths are relative to the directory holding .sourceplane
+	base := filepath.Dir(filepath.Dir(path))
+	for _, name := range sortedKeys(values) {
+		k, err := LookupKey(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if values[name] != "" {
+			c.set(k, values[name], source, base)
+		}
+	}
+
+	switch source {
+	case SourceUser:
+		c.UserFile = path
+	case SourceProject:
+		c.ProjectFile = path
+	}
+	return nil
+}
+
+// SetFlag applies a value given on the command line
+func (c *Config) SetFlag(name, value string) error {
+	k, err := LookupKey(name)
+	if err != nil {
+		return err
+	}
+	c.set(k, value, SourceFlag, c.WorkingDir)
+	return nil
+}
+
+func (c *Config) set(k Key, value, source, base string) {
+	if k.path && value != "" {
+		value = expandPath(value, base)
+	}
+	*k.field(c) = value
+	c.sources[k.Name] = source
+}
+
+// Get returns the value of a setting
+func (c *Config) Get(name string) (string, error) {
+	k, err := LookupKey(name)
+	if err != nil {
+		return "", err
+	}
+	return *k.field(c), nil
+}
+
+// Source returns where a setting's value came from: default, user,
+// project, env or flag
+func (c *Config) Source(name string) string {
+	return c.sources[name]
+}
+
+// ProviderCacheDir is the directory downloaded providers are cached in
+func (c *Config) ProviderCacheDir() string {
+	return filepath.Join(c.CachePath, "providers")
+}
+
 // EnsureCacheDir creates the cache directory if it doesn't exist
 func (c *Config) EnsureCacheDir() error {
 	return os.MkdirAll(c.CachePath, 0755)
 }
+
+// SetInFile writes a setting to a config file, creating it if needed. An
+// empty value removes the setting.
+func SetInFile(path, name, value string) error {
+	if _, err := LookupKey(name); err != nil {
+		return err
+	}
+
+	root := make(map[string]any)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if root == nil {
+			root = make(map[string]any)
+		}
+	}
+
+	section, field, _ := strings.Cut(name, ".")
+	values, _ := root[section].(map[string]any)
+	if values == nil {
+		values = make(map[string]any)
+	}
+	if value == "" {
+		delete(values, field)
+	} else {
+		values[field] = value
+	}
+	if len(values) == 0 {
+		delete(root, section)
+	} else {
+		root[section] = values
+	}
+
+	out, err := yaml.Marshal(root)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// readFile reads a config file into dotted keys, e.g. cache.path. It
+// returns nil when the file does not exist.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var root map[string]any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	for section, raw := range root {
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s must be a mapping", path, section)
+		}
+		for field, value := range fields {
+			switch value.(type) {
+			case nil:
+				values[section+"."+field] = ""
+			case map[string]any, []any:
+				return nil, fmt.Errorf("%s: %s.%s must be a string", path, section, field)
+			default:
+				values[section+"."+field] = fmt.Sprint(value)
+			}
+		}
+	}
+	return values, nil
+}
+
+// expandPath expands ~ and makes path absolute relative to base
+func expandPath(path, base string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	return filepath.Clean(path)
+}
+
+func sortedKeys(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
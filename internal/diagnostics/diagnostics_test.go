@@ -0,0 +1,145 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticString(t *testing.T) {
+	tests := []struct {
+		diag Diagnostic
+		want string
+	}{
+		{Diagnostic{File: "intent.yaml", Line: 42, Column: 5, Message: "unknown field"}, "intent.yaml:42:5: unknown field"},
+		{Diagnostic{File: "intent.yaml", Line: 42, Message: "unknown field"}, "intent.yaml:42: unknown field"},
+		{Diagnostic{File: "intent.yaml", Message: "unknown field"}, "intent.yaml: unknown field"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.diag.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSortAndCount(t *testing.T) {
+	diags := []Diagnostic{
+		{File: "b.yaml", Line: 1, Column: 1, Severity: SeverityError, Message: "b1"},
+		{File: "a.yaml", Line: 7, Column: 3, Severity: SeverityWarning, Message: "a7:3"},
+		{File: "a.yaml", Line: 7, Column: 1, Severity: SeverityError, Message: "a7:1"},
+		{File: "a.yaml", Line: 2, Column: 9, Severity: SeverityError, Message: "a2"},
+	}
+
+	Sort(diags)
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.Message)
+	}
+	if want := "a2,a7:1,a7:3,b1"; strings.Join(got, ",") != want {
+		t.Errorf("Sort() order = %v, want %s", got, want)
+	}
+
+	if n := Count(diags, SeverityError); n != 3 {
+		t.Errorf("Count(error) = %d, want 3", n)
+	}
+	if n := Count(diags, SeverityWarning); n != 1 {
+		t.Errorf("Count(warning) = %d, want 1", n)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	diags := []Diagnostic{
+		{File: "intent.yaml", Line: 3, Column: 5, Severity: SeverityError, Rule: "schema", Path: "components[0].spec.chart", Message: "is required"},
+		{File: "intent.yaml", Severity: SeverityWarning, Rule: "unknown-field", Message: "unknown field spce"},
+	}
+	if err := Write(&buf, FormatJSON, diags, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Diagnostics []map[string]any `json:"diagnostics"`
+		Summary     map[string]int   `json:"summary"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Diagnostics) != 2 {
+		t.Fatalf("got %d diagnostics, want 2", len(report.Diagnostics))
+	}
+	if report.Summary["errors"] != 1 || report.Summary["warnings"] != 1 {
+		t.Errorf("summary = %v, want 1 error and 1 warning", report.Summary)
+	}
+	if report.Diagnostics[0]["path"] != "components[0].spec.chart" {
+		t.Errorf("path = %v, want components[0].spec.chart", report.Diagnostics[0]["path"])
+	}
+	// Positions are left out when unknown
+	if _, ok := report.Diagnostics[1]["line"]; ok {
+		t.Errorf("diagnostic without a position has a line: %v", report.Diagnostics[1])
+	}
+}
+
+func TestWriteJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"diagnostics": []`) {
+		t.Errorf("WriteJSON(nil) = %s, want an empty diagnostics list", buf.String())
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	diags := []Diagnostic{
+		{File: `charts\intent.yaml`, Line: 3, Column: 5, Severity: SeverityError, Rule: "schema", Message: "chart: is required"},
+		{File: "intent.yaml", Severity: SeverityWarning, Rule: "unknown-field", Message: "unknown field <spce>"},
+	}
+	rules := []Rule{{ID: "schema", Description: "Component specs match the provider schema"}}
+	if err := Write(&buf, FormatSARIF, diags, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v, want one SARIF 2.1.0 run", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "schema" {
+		t.Errorf("rules = %+v, want the schema rule", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(run.Results))
+	}
+
+	first := run.Results[0]
+	if first.RuleID != "schema" || first.Level != "error" {
+		t.Errorf("result = %+v, want an error for rule schema", first)
+	}
+	location := first.Locations[0].PhysicalLocation
+	if location.Region == nil || location.Region.StartLine != 3 || location.Region.StartColumn != 5 {
+		t.Errorf("region = %+v, want line 3 column 5", location.Region)
+	}
+
+	second := run.Results[1]
+	if second.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("result without a position has a region: %+v", second.Locations[0].PhysicalLocation.Region)
+	}
+	// HTML characters in messages are written as is
+	if !strings.Contains(buf.String(), "unknown field <spce>") {
+		t.Errorf("SARIF output escapes the message:\n%s", buf.String())
+	}
+}
+
+func TestWriteUnsupportedFormat(t *testing.T) {
+	for _, format := range []string{FormatText, "xml"} {
+		if err := Write(&bytes.Buffer{}, format, nil, nil); err == nil || !strings.Contains(err.Error(), "unsupported format") {
+			t.Errorf("Write(%q) error = %v, want an unsupported format error", format, err)
+		}
+	}
+}
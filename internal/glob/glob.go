@@ -0,0 +1,91 @@
+// Package glob matches repository paths against glob patterns. Patterns use
+// path.Match syntax per segment, plus:
+//
+//   - ** matches any number of directories, e.g. services/api/**/*.go
+//   - a pattern matches a path and everything below it, so charts/api
+//     matches charts/api/values.yaml
+//   - a pattern without a slash that contains a wildcard matches in any
+//     directory, so *.md is the same as **/*.md
+//   - in a list of patterns, a leading ! excludes what earlier patterns
+//     matched, e.g. ["services/api/**", "!**/*.md"]
+//
+// Paths are slash-separated and relative to the repository root; a leading
+// ./ or / is ignored.
+package glob
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Match reports whether name, or a directory above it, matches pattern
+func Match(pattern, name string) bool {
+	pattern = Clean(pattern)
+	if !strings.Contains(pattern, "/") && hasMeta(pattern) {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(Clean(name), "/"))
+}
+
+// MatchList reports whether name is selected by a list of patterns. The last
+// pattern matching name decides: a name matched by a pattern starting with !
+// is excluded, so later patterns can re-include it.
+func MatchList(patterns []string, name string) bool {
+	selected := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		if Match(strings.TrimPrefix(pattern, "!"), name) {
+			selected = !negated
+		}
+	}
+	return selected
+}
+
+// Validate checks that a pattern, optionally negated, is well-formed
+func Validate(pattern string) error {
+	trimmed := strings.TrimPrefix(pattern, "!")
+	if strings.TrimSpace(trimmed) == "" {
+		return fmt.Errorf("empty pattern %q", pattern)
+	}
+	for _, segment := range strings.Split(Clean(trimmed), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Clean normalizes a repository-relative path or pattern, e.g.
+// ./charts/api/ -> charts/api
+func Clean(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// matchSegments matches pattern segments against the leading segments of a
+// path; whatever remains of the path is below a matched directory
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
@@ -0,0 +1,110 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"charts/api", "charts/api", true},
+		{"charts/api", "charts/api/values.yaml", true},
+		{"charts/api", "charts/api-gateway/values.yaml", false},
+		{"charts/api", "other/charts/api", false},
+		{"./charts/api/", "charts/api/values.yaml", true},
+		{"charts/api", "./charts/api/values.yaml", true},
+		{"/charts/api", "charts/api/values.yaml", true},
+		{"charts/*/values.yaml", "charts/api/values.yaml", true},
+		{"charts/*/values.yaml", "charts/api/env/values.yaml", false},
+		{"services/api/**/*.go", "services/api/main.go", true},
+		{"services/api/**/*.go", "services/api/internal/server/server.go", true},
+		{"services/api/**/*.go", "services/web/main.go", false},
+		{"**", "anything/at/all", true},
+		{"**/intent.yaml", "intent.yaml", true},
+		{"**/intent.yaml", "svc/intent.yaml", true},
+		{"*.md", "README.md", true},
+		{"*.md", "docs/guide/README.md", true},
+		{"*.md", "README.mdx", false},
+		{"docs", "docs/guide/README.md", true},
+		{"docs", "src/docs/README.md", false},
+		{"terraform/modules", "terraform/modules/vpc/main.tf", true},
+		{"main.?f", "main.tf", true},
+		{"[a-c]/x", "b/x", true},
+		{"[a-c]/x", "d/x", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchList(t *testing.T) {
+	patterns := []string{"services/api/**", "!**/*.md", "services/api/CHANGELOG.md"}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"services/api/main.go", true},
+		{"services/api/README.md", false},
+		{"services/api/docs/guide.md", false},
+		{"services/api/CHANGELOG.md", true},
+		{"services/web/main.go", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchList(patterns, tt.name); got != tt.want {
+			t.Errorf("MatchList(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if MatchList(nil, "services/api/main.go") {
+		t.Error("an empty list matches")
+	}
+	if MatchList([]string{"!**/*.md"}, "main.go") {
+		t.Error("a list of exclusions matches")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"charts/api", false},
+		{"!**/*.md", false},
+		{"services/[a-z]*/**", false},
+		{"", true},
+		{"!", true},
+		{"  ", true},
+		{"charts/[api", true},
+		{"!charts/[", true},
+	}
+
+	for _, tt := range tests {
+		err := Validate(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) = %v, want error %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := map[string]string{
+		"./charts/api/":     "charts/api",
+		"/charts/api":       "charts/api",
+		"charts//api/../db": "charts/db",
+		"../outside":        "outside",
+		".":                 "",
+		"":                  "",
+	}
+
+	for in, want := range tests {
+		if got := Clean(in); got != want {
+			t.Errorf("Clean(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -7,6 +7,12 @@ type Component struct {
 	Spec map[string]interface{} `yaml:"spec,omitempty"`
 	// Deprecated: use Spec instead
 	Inputs map[string]interface{} `yaml:"inputs,omitempty"`
+
+	// Paths are glob patterns for the files that belong to the component,
+	// overriding the paths its provider derives; IgnorePaths are files whose
+	// changes never affect it. Patterns support ** and ! negation.
+	Paths       []string `yaml:"paths,omitempty"`
+	IgnorePaths []string `yaml:"ignorePaths,omitempty"`
 }
 
 // Provider configuration with defaults
@@ -18,13 +24,32 @@ type Provider struct {
 
 // Repository represents an intent.yaml file (new format) or legacy sourceplane.yaml
 type Repository struct {
-	APIVersion    string              `yaml:"apiVersion"`
-	Kind          string              `yaml:"kind"`
-	Metadata      RepositoryMetadata  `yaml:"metadata"`
-	Providers     map[string]Provider `yaml:"providers,omitempty"`
-	Provider      string              `yaml:"provider,omitempty"` // Legacy support
-	Components    []Component         `yaml:"components"`
-	Relationships []Relationship      `yaml:"relationships,omitempty"`
+	APIVersion    string                 `yaml:"apiVersion"`
+	Kind          string                 `yaml:"kind"`
+	Metadata      RepositoryMetadata     `yaml:"metadata"`
+	Providers     map[string]Provider    `yaml:"providers,omitempty"`
+	Provider      string                 `yaml:"provider,omitempty"` // Legacy support
+	Components    []Component            `yaml:"components"`
+	Relationships []Relationship         `yaml:"relationships,omitempty"`
+	Environments  map[string]Environment `yaml:"environments,omitempty"`
+}
+
+// Environment overlays component specs and provider defaults for one
+// deployment target, e.g. dev, staging or prod
+type Environment struct {
+	Description string                      `yaml:"description,omitempty"`
+	Providers   map[string]ProviderOverlay  `yaml:"providers,omitempty"`
+	Components  map[string]ComponentOverlay `yaml:"components,omitempty"`
+}
+
+// ProviderOverlay overrides a provider's defaults in an environment
+type ProviderOverlay struct {
+	Defaults map[string]interface{} `yaml:"defaults,omitempty"`
+}
+
+// ComponentOverlay is deep-merged over a component's spec in an environment
+type ComponentOverlay struct {
+	Spec map[string]interface{} `yaml:"spec,omitempty"`
 }
 
 // Relationship between components
@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sourceplane/sourceplane/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a parsed intent file that remembers the position of every
+// value, so that problems can be reported as intent.yaml:42:5
+type Document struct {
+	Path string
+	root *yaml.Node
+}
+
+// SyntaxError is an intent file that is not valid YAML or does not match
+// the structure of an intent
+type SyntaxError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// UnknownField is a key in the intent file that no intent field matches,
+// usually a typo such as `spce:`
+type UnknownField struct {
+	Path   string // path of the key, e.g. "components[2].spce"
+	Key    string
+	Line   int
+	Column int
+}
+
+// ParseRepository loads an intent file, keeping the parsed document for
+// position lookups
+func ParseRepository(path string) (*models.Repository, *Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read intent.yaml: %w", err)
+	}
+	return ParseRepositoryData(path, data)
+}
+
+// ParseRepositoryData parses the content of an intent file, such as one read
+// from a git revision; path is used in errors
+func ParseRepositoryData(path string, data []byte) (*models.Repository, *Document, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, nil, newSyntaxError(path, err)
+	}
+
+	doc := &Document{Path: path}
+	var repo models.Repository
+	if len(node.Content) > 0 {
+		doc.root = node.Content[0]
+		if err := doc.root.Decode(&repo); err != nil {
+			return nil, nil, newSyntaxError(path, err)
+		}
+	}
+
+	return &repo, doc, nil
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+): `)
+
+// newSyntaxError converts a yaml.v3 error, which mentions the line in its
+// message, into a SyntaxError
+func newSyntaxError(path string, err error) *SyntaxError {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	if typeErr, ok := err.(*yaml.TypeError); ok && len(typeErr.Errors) > 0 {
+		message = typeErr.Errors[0]
+	}
+
+	syntaxErr := &SyntaxError{Path: path, Message: message}
+	if m := yamlLinePattern.FindStringSubmatchIndex(message); m != nil {
+		syntaxErr.Line, _ = strconv.Atoi(message[m[2]:m[3]])
+		syntaxErr.Message = message[:m[0]] + message[m[1]:]
+	}
+	return syntaxErr
+}
+
+// Position returns the line and column of the value at a path such as
+// "components[2].spec.chart". For mapping entries the position of the key is
+// returned. When the path does not exist, for example because a required
+// field is missing, the position of the closest existing parent is returned.
+func (d *Document) Position(path string) (int, int) {
+	if d == nil || d.root == nil {
+		return 1, 1
+	}
+
+	node := d.root
+	line, column := node.Line, node.Column
+	for _, segment := range splitPath(path) {
+		node = resolveAlias(node)
+		switch {
+		case segment.index >= 0:
+			if node.Kind != yaml.SequenceNode || segment.index >= len(node.Content) {
+				return line, column
+			}
+			node = node.Content[segment.index]
+			line, column = node.Line, node.Column
+		default:
+			key, value := mappingEntry(node, segment.key)
+			if key == nil {
+				return line, column
+			}
+			node = value
+			line, column = key.Line, key.Column
+		}
+	}
+	return line, column
+}
+
+// UnknownFields returns the keys in the document that do not match a field
+// of the intent structure. Free-form sections such as component specs and
+// provider defaults are not checked here; specs are validated against the
+// provider's schema instead.
+func (d *Document) UnknownFields() []UnknownField {
+	if d == nil || d.root == nil {
+		return nil
+	}
+	var fields []UnknownField
+	collectUnknownFields(d.root, reflect.TypeOf(models.Repository{}), "", &fields)
+	return fields
+}
+
+func collectUnknownFields(node *yaml.Node, t reflect.Type, path string, fields *[]UnknownField) {
+	node = resolveAlias(node)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		known := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldPath := joinPath(path, key.Value)
+			fieldType, ok := known[key.Value]
+			if !ok {
+				*fields = append(*fields, UnknownField{Path: fieldPath, Key: key.Value, Line: key.Line, Column: key.Column})
+				continue
+			}
+			collectUnknownFields(value, fieldType, fieldPath, fields)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			collectUnknownFields(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), fields)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			collectUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	}
+}
+
+// yamlFields maps the yaml keys of a struct to their field types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(field.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+type pathSegment struct {
+	key   string
+	index int // -1 for mapping keys
+}
+
+var indexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// splitPath splits "components[2].spec.chart" into its keys and indexes
+func splitPath(path string) []pathSegment {
+	var segments []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			continue
+		}
+		key := part
+		if i := strings.Index(part, "["); i >= 0 {
+			key = part[:i]
+		}
+		if key != "" {
+			segments = append(segments, pathSegment{key: key, index: -1})
+		}
+		for _, m := range indexPattern.FindAllStringSubmatch(part, -1) {
+			index, _ := strconv.Atoi(m[1])
+			segments = append(segments, pathSegment{index: index})
+		}
+	}
+	return segments
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
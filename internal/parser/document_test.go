@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testIntent = `apiVersion: sourceplane.io/v1
+kind: Repository
+metadata:
+  name: shop
+  owner: team-a
+providers:
+  helm:
+    source: sourceplane/helm
+    defaults:
+      anything: goes
+components:
+  - name: api
+    type: helm.service
+    spec:
+      chart: charts/api
+      free: form
+  - name: db
+    type: helm.service
+    spce:
+      chart: charts/db
+defaults: &defaults
+  chart: charts/shared
+environments:
+  prod:
+    components:
+      api:
+        spec: *defaults
+        specs: {}
+`
+
+func TestParseRepositoryData(t *testing.T) {
+	repo, doc, err := ParseRepositoryData("intent.yaml", []byte(testIntent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Metadata.Name != "shop" || len(repo.Components) != 2 {
+		t.Errorf("repo = %+v, want shop with 2 components", repo)
+	}
+	if doc.Path != "intent.yaml" {
+		t.Errorf("doc.Path = %s, want intent.yaml", doc.Path)
+	}
+}
+
+func TestParseRepositoryDataErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want SyntaxError
+	}{
+		{
+			name: "invalid yaml",
+			data: "metadata:\n  name: shop\n components: []\n",
+			want: SyntaxError{Path: "intent.yaml", Line: 2, Message: "did not find expected key"},
+		},
+		{
+			name: "wrong structure",
+			data: "metadata:\n  name: shop\ncomponents:\n  name: api\n",
+			want: SyntaxError{Path: "intent.yaml", Line: 4, Message: "cannot unmarshal !!map into []models.Component"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseRepositoryData("intent.yaml", []byte(tt.data))
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("error = %v, want a *SyntaxError", err)
+			}
+			if *syntaxErr != tt.want {
+				t.Errorf("error = %+v, want %+v", *syntaxErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorString(t *testing.T) {
+	if got := (&SyntaxError{Path: "intent.yaml", Line: 3, Message: "bad"}).Error(); got != "intent.yaml:3: bad" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := (&SyntaxError{Path: "intent.yaml", Message: "bad"}).Error(); got != "intent.yaml: bad" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestParseRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intent.yaml")
+	if err := os.WriteFile(path, []byte(testIntent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, doc, err := ParseRepository(path); err != nil || doc.Path != path {
+		t.Fatalf("ParseRepository() = %v, %v", doc, err)
+	}
+
+	_, _, err := ParseRepository(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "failed to read intent.yaml") {
+		t.Errorf("ParseRepository(missing) error = %v, want a read error", err)
+	}
+}
+
+func TestDocumentPosition(t *testing.T) {
+	_, doc, err := ParseRepositoryData("intent.yaml", []byte(testIntent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path         string
+		line, column int
+	}{
+		{path: "", line: 1, column: 1},
+		{path: "metadata.owner", line: 5, column: 3},
+		{path: "components", line: 11, column: 1},
+		{path: "components[1]", line: 17, column: 5},
+		{path: "components[0].spec.chart", line: 15, column: 7},
+		// Missing values resolve to their closest existing parent
+		{path: "components[0].spec.values.image", line: 14, column: 5},
+		{path: "components[1].spec.chart", line: 17, column: 5},
+		{path: "components[5].name", line: 11, column: 1},
+		{path: "metadata.name[0]", line: 4, column: 3},
+		// Aliases are followed into the anchored value
+		{path: "environments.prod.components.api.spec.chart", line: 22, column: 3},
+	}
+
+	for _, tt := range tests {
+		line, column := doc.Position(tt.path)
+		if line != tt.line || column != tt.column {
+			t.Errorf("Position(%q) = %d:%d, want %d:%d", tt.path, line, column, tt.line, tt.column)
+		}
+	}
+
+	var empty *Document
+	if line, column := empty.Position("components[0]"); line != 1 || column != 1 {
+		t.Errorf("nil Position() = %d:%d, want 1:1", line, column)
+	}
+}
+
+func TestDocumentUnknownFields(t *testing.T) {
+	_, doc, err := ParseRepositoryData("intent.yaml", []byte(testIntent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []UnknownField{
+		{Path: "components[1].spce", Key: "spce", Line: 19, Column: 5},
+		{Path: "defaults", Key: "defaults", Line: 21, Column: 1},
+		{Path: "environments.prod.components.api.specs", Key: "specs", Line: 28, Column: 9},
+	}
+	got := doc.UnknownFields()
+	if len(got) != len(want) {
+		t.Fatalf("UnknownFields() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	_, doc, err = ParseRepositoryData("intent.yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := doc.UnknownFields(); fields != nil {
+		t.Errorf("UnknownFields() of an empty file = %+v, want none", fields)
+	}
+}
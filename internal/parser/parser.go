@@ -11,17 +11,15 @@ import (
 
 // LoadRepository loads and parses an intent.yaml file
 func LoadRepository(path string) (*models.Repository, error) {
-	data, err := os.ReadFile(path)
+	repo, _, err := ParseRepository(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read intent.yaml: %w", err)
-	}
-
-	var repo models.Repository
-	if err := yaml.Unmarshal(data, &repo); err != nil {
-		return nil, fmt.Errorf("failed to parse intent.yaml: %w", err)
+		if _, ok := err.(*SyntaxError); ok {
+			return nil, fmt.Errorf("failed to parse intent.yaml: %w", err)
+		}
+		return nil, err
 	}
 
-	return &repo, nil
+	return repo, nil
 }
 
 // LoadRepositoryFromDir loads intent.yaml from a directory
@@ -0,0 +1,265 @@
+package providers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sourceplane/sourceplane/internal/config"
+	"github.com/sourceplane/sourceplane/internal/semver"
+)
+
+// ProviderFetcher handles fetching remote providers
+type ProviderFetcher struct {
+	cacheDir string
+}
+
+// NewProviderFetcher creates a provider fetcher that clones into the
+// configured cache directory
+func NewProviderFetcher(cfg *config.Config) (*ProviderFetcher, error) {
+	cacheDir := cfg.ProviderCacheDir()
+	
+	// Create cache directory if it doesn't exist
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	
+	return &ProviderFetcher{
+		cacheDir: cacheDir,
+	}, nil
+}
+
+// FetchProvider downloads a provider from a remote source if needed and
+// checks out version, a tag, branch or commit. An empty version tracks the
+// remote's default branch.
+// Returns the local path to the provider
+func (f *ProviderFetcher) FetchProvider(source, version string) (string, error) {
+	// Parse the source to determine provider name and repo
+	providerName, repoURL := f.parseSource(source)
+	
+	// Check if provider is already cached
+	providerPath := filepath.Join(f.cacheDir, providerName)
+	
+	// Only one process updates a clone at a time
+	unlock, err := lockFile(providerPath + ".install.lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	
+	// Check if provider exists and is a git repo
+	gitDir := filepath.Join(providerPath, ".git")
+	if _, err := os.Stat(gitDir); err == nil {
+		// Provider exists, try to update it
+		fmt.Fprintf(os.Stderr, "Updating provider %s from %s...\n", providerName, source)
+		if err := f.updateProvider(providerPath, version); err != nil {
+			if version != "" {
+				return "", fmt.Errorf("failed to check out %s of provider %s: %w", version, providerName, err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: failed to update provider: %v\n", err)
+			// Continue with existing version
+		}
+	} else {
+		// Provider doesn't exist, clone it next to the cache entry and move
+		// it into place once complete
+		fmt.Fprintf(os.Stderr, "Fetching provider %s from %s...\n", providerName, source)
+		tmp, err := os.MkdirTemp(f.cacheDir, "."+providerName+".tmp-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		
+		if err := f.cloneProvider(repoURL, tmp); err != nil {
+			return "", fmt.Errorf("failed to fetch provider: %w", err)
+		}
+		if version != "" {
+			if err := f.updateProvider(tmp, version); err != nil {
+				return "", fmt.Errorf("failed to check out %s of provider %s: %w", version, providerName, err)
+			}
+		}
+		
+		// Remove what an interrupted clone left behind
+		if err := os.RemoveAll(providerPath); err != nil {
+			return "", fmt.Errorf("failed to replace %s: %w", providerPath, err)
+		}
+		if err := os.Rename(tmp, providerPath); err != nil {
+			return "", fmt.Errorf("failed to move provider into the cache: %w", err)
+		}
+	}
+	
+	// Verify provider.yaml exists
+	providerYamlPath := filepath.Join(providerPath, "provider.yaml")
+	if _, err := os.Stat(providerYamlPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("provider.yaml not found in %s", providerPath)
+	}
+	
+	return providerPath, nil
+}
+
+// ResolveVersion returns the git ref to check out for a version: the
+// highest version tag satisfying it when it is a version constraint, such
+// as ">=1.2, <2.0", or the version itself when it is a branch or commit
+func (f *ProviderFetcher) ResolveVersion(source, version string) (string, error) {
+	if version == "" {
+		return "", nil
+	}
+	constraints, err := semver.ParseConstraints(version)
+	if err != nil {
+		return version, nil
+	}
+
+	_, repoURL := f.parseSource(source)
+	tags, err := listGitTags(repoURL)
+	if err != nil {
+		return "", err
+	}
+	tag, ok := semver.Highest(constraints, tags)
+	if !ok {
+		return "", noMatchingVersion(source, version, tags)
+	}
+	return tag, nil
+}
+
+// Revision returns the commit checked out in a fetched provider
+func (f *ProviderFetcher) Revision(providerPath string) (string, error) {
+	out, err := exec.Command("git", "-C", providerPath, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read revision of %s: %w", providerPath, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// parseSource extracts provider name and repository URL from source string
+// Examples:
+//   - github.com/sourceplane/providers/helm -> (helm, https://github.com/sourceplane/providers)
+//   - github.com/org/provider-name -> (provider-name, https://github.com/org/provider-name)
+//   - file:///srv/git/helm.git -> (helm, file:///srv/git/helm.git)
+func (f *ProviderFetcher) parseSource(source string) (string, string) {
+	// Sources with another scheme, such as file:// or ssh://, are cloned
+	// from the URL as written
+	if scheme, rest, found := strings.Cut(source, "://"); found && scheme != "https" && scheme != "http" {
+		return strings.TrimSuffix(path.Base(rest), ".git"), source
+	}
+
+	// Remove protocol if present
+	source = strings.TrimPrefix(source, "https://")
+	source = strings.TrimPrefix(source, "http://")
+	
+	parts := strings.Split(source, "/")
+	
+	if len(parts) < 3 {
+		// Invalid source, return as-is
+		return source, "https://" + source
+	}
+	
+	// Extract provider name from last part
+	providerName := parts[len(parts)-1]
+	
+	// Build repo URL
+	repoURL := "https://" + source
+	
+	return providerName, repoURL
+}
+
+// cloneProvider clones a git repository
+func (f *ProviderFetcher) cloneProvider(repoURL, destPath string) error {
+	cmd := exec.Command("git", "clone", "--depth", "1", repoURL, destPath)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+	
+	return nil
+}
+
+// updateProvider checks out version, or the tip of the remote's default
+// branch when version is empty. A commit that is already present locally,
+// such as a locked revision, is checked out without fetching.
+func (f *ProviderFetcher) updateProvider(providerPath, version string) error {
+	if isCommit(version) {
+		checkout := exec.Command("git", "checkout", "--quiet", "--detach", version)
+		checkout.Dir = providerPath
+		if checkout.Run() == nil {
+			return nil
+		}
+	}
+
+	ref := version
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"fetch", "--depth", "1", "origin", ref},
+		{"checkout", "--quiet", "--detach", "FETCH_HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = providerPath
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("git %s failed: %w", args[0], err)
+		}
+	}
+	
+	return nil
+}
+
+// listGitTags lists the tags of a remote git repository
+func listGitTags(repoURL string) ([]string, error) {
+	out, err := exec.Command("git", "ls-remote", "--tags", "--refs", repoURL).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("failed to list versions of %s: %s", repoURL, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to list versions of %s: %w", repoURL, err)
+	}
+
+	var tags []string
+	for _, line := range strings.Split(string(out), "\n") {
+		_, ref, found := strings.Cut(strings.TrimSpace(line), "\t")
+		if found {
+			tags = append(tags, strings.TrimPrefix(ref, "refs/tags/"))
+		}
+	}
+	return tags, nil
+}
+
+// noMatchingVersion reports that none of a source's versions satisfies a
+// constraint
+func noMatchingVersion(source, constraint string, available []string) error {
+	versions := semver.Sort(available)
+	if len(versions) == 0 {
+		return fmt.Errorf("no version of %s matches %s: the source has no version tags", source, constraint)
+	}
+	return fmt.Errorf("no version of %s matches %s (available: %s)", source, constraint, strings.Join(versions, ", "))
+}
+
+// isCommit reports whether version is a full git commit hash
+func isCommit(version string) bool {
+	if len(version) != 40 {
+		return false
+	}
+	for _, c := range version {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRemoteSource checks if a source is remote (vs local path)
+func IsRemoteSource(source string) bool {
+	// Remote sources typically start with domain names
+	return strings.Contains(source, "github.com") ||
+		strings.Contains(source, "gitlab.com") ||
+		strings.Contains(source, "bitbucket.org") ||
+		strings.HasPrefix(source, "https://") ||
+		strings.HasPrefix(source, "http://") ||
+		strings.HasPrefix(source, "git@")
+}
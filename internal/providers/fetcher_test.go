@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newBareProviderRepo creates a bare git repository holding a provider with
+// the tags v1.0.0, v1.2.0 and v2.0.0-rc.1, and an untagged 1.3.0-dev commit
+// on main. It returns the file:// source of the repository and the commit of
+// each version.
+func newBareProviderRepo(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0755); err != nil {
+		t.Fatal(err)
+	}
+	runGitCmd(t, work, "init", "-q", "-b", "main")
+
+	commits := make(map[string]string)
+	for _, version := range []string{"1.0.0", "1.2.0", "2.0.0-rc.1", "1.3.0-dev"} {
+		content := "name: helm\nversion: " + version + "\n"
+		if err := os.WriteFile(filepath.Join(work, "provider.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCmd(t, work, "add", "-A")
+		runGitCmd(t, work, "commit", "-q", "-m", version)
+		if !strings.HasSuffix(version, "-dev") {
+			runGitCmd(t, work, "tag", "v"+version)
+		}
+		commits[version] = strings.TrimSpace(runGitCmd(t, work, "rev-parse", "HEAD"))
+	}
+
+	bare := filepath.Join(dir, "helm.git")
+	runGitCmd(t, dir, "clone", "-q", "--bare", work, bare)
+	return "file://" + filepath.ToSlash(bare), commits
+}
+
+func runGitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestProviderFetcherResolveVersion(t *testing.T) {
+	source, _ := newBareProviderRepo(t)
+	fetcher := &ProviderFetcher{cacheDir: t.TempDir()}
+
+	tests := []struct {
+		version string
+		want    string
+		wantErr string
+	}{
+		{version: "", want: ""},
+		{version: ">=1.0, <2.0", want: "v1.2.0"},
+		{version: "^1.0.0", want: "v1.2.0"},
+		{version: "~> 1.0.0", want: "v1.0.0"},
+		{version: "1.0", want: "v1.0.0"},
+		{version: ">=1.0", want: "v1.2.0"},
+		{version: "2.0.0-rc.1", want: "v2.0.0-rc.1"},
+		{version: "main", want: "main"},
+		{version: ">=3.0", wantErr: "available: v1.0.0, v1.2.0, v2.0.0-rc.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := fetcher.ResolveVersion(source, tt.version)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveVersion(%q) error = %v, want it to contain %q", tt.version, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveVersion(%q) = %q, want %q", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderFetcherFetchProvider(t *testing.T) {
+	source, commits := newBareProviderRepo(t)
+	fetcher := &ProviderFetcher{cacheDir: t.TempDir()}
+
+	// The first fetch clones, the following ones update the clone
+	steps := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "v1.0.0", want: "1.0.0"},
+		{ref: "v1.2.0", want: "1.2.0"},
+		{ref: commits["1.0.0"], want: "1.0.0"},
+		{ref: "", want: "1.3.0-dev"},
+	}
+
+	for _, step := range steps {
+		dir, err := fetcher.FetchProvider(source, step.ref)
+		if err != nil {
+			t.Fatalf("FetchProvider(%q): %v", step.ref, err)
+		}
+		if want := filepath.Join(fetcher.cacheDir, "helm"); dir != want {
+			t.Errorf("FetchProvider(%q) = %s, want %s", step.ref, dir, want)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "provider.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "version: "+step.want+"\n") {
+			t.Errorf("FetchProvider(%q) checked out %q, want version %s", step.ref, data, step.want)
+		}
+
+		revision, err := fetcher.Revision(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revision != commits[step.want] {
+			t.Errorf("Revision after FetchProvider(%q) = %s, want %s", step.ref, revision, commits[step.want])
+		}
+	}
+}
+
+func TestProviderFetcherFetchUnknownVersion(t *testing.T) {
+	source, _ := newBareProviderRepo(t)
+	fetcher := &ProviderFetcher{cacheDir: t.TempDir()}
+
+	if _, err := fetcher.FetchProvider(source, "v9.9.9"); err == nil {
+		t.Fatal("expected an error for a missing tag")
+	}
+	// A failed first fetch leaves nothing in the cache
+	if _, err := os.Stat(filepath.Join(fetcher.cacheDir, "helm")); !os.IsNotExist(err) {
+		t.Errorf("cache entry exists after a failed fetch: %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package providers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxExtractedSize bounds the content extracted from a provider archive
+const maxExtractedSize = 512 << 20
+
+// cacheMarker is the .lock file written into a provider cache directory once
+// it is complete. Directories without one are partial downloads.
+type cacheMarker struct {
+	Version       string `json:"version"`
+	ArchiveSHA256 string `json:"archiveSha256,omitempty"`
+	Hash          string `json:"hash,omitempty"` // HashDir of the directory
+}
+
+// install populates a provider cache directory. fill downloads into a
+// temporary directory next to dir and returns the archive checksum; the
+// result is renamed into place only when complete, so other processes never
+// see a partial provider. Concurrent installs of the same directory are
+// serialized with a file lock, and a cached directory whose content no
+// longer matches its marker is downloaded again.
+func install(dir, version string, fill func(tmp string) (string, error)) error {
+	if verifyInstall(dir) == nil {
+		return nil
+	}
+
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	unlock, err := lockFile(dir + ".install.lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another process may have installed it while we waited for the lock
+	if err := verifyInstall(dir); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Warning: %v; downloading it again\n", err)
+	}
+
+	tmp, err := os.MkdirTemp(parent, "."+filepath.Base(dir)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	archiveSHA256, err := fill(tmp)
+	if err != nil {
+		return err
+	}
+
+	hash, err := HashDir(tmp)
+	if err != nil {
+		return err
+	}
+	marker, err := json.Marshal(cacheMarker{Version: version, ArchiveSHA256: archiveSHA256, Hash: hash})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, ".lock"), marker, 0644); err != nil {
+		return fmt.Errorf("failed to create lock file: %w", err)
+	}
+
+	// Move a broken install out of the way before putting the new one in place
+	if _, err := os.Lstat(dir); err == nil {
+		old := tmp + ".old"
+		if err := os.Rename(dir, old); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dir, err)
+		}
+		defer os.RemoveAll(old)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return fmt.Errorf("failed to move provider into the cache: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Provider cached at %s\n", dir)
+	return nil
+}
+
+// verifyInstall checks that dir is a complete install whose content matches
+// the hash recorded when it was cached. It returns an error satisfying
+// os.IsNotExist when nothing is cached.
+func verifyInstall(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, ".lock"))
+	if err != nil {
+		return err
+	}
+
+	var marker cacheMarker
+	if json.Unmarshal(data, &marker) != nil || marker.Hash == "" {
+		// Written before content hashes were recorded
+		return nil
+	}
+	hash, err := HashDir(dir)
+	if err != nil {
+		return err
+	}
+	if hash != marker.Hash {
+		return fmt.Errorf("cached provider at %s was modified (expected %s, found %s)", dir, shortHash(marker.Hash), shortHash(hash))
+	}
+	return nil
+}
+
+// downloadArchive downloads url to a temporary file and returns it with its
+// sha256 checksum. When expectedSHA256 is set, a download with a different
+// checksum is rejected. The caller removes the file.
+func downloadArchive(client *http.Client, url, expectedSHA256 string) (*os.File, string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download provider: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", &httpStatusError{url: url, status: resp.StatusCode}
+	}
+
+	archive, err := os.CreateTemp("", "sourceplane-provider-*.tar.gz")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup := func() {
+		archive.Close()
+		os.Remove(archive.Name())
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archive, h), resp.Body); err != nil {
+		cleanup()
+		return nil, "", fmt.Errorf("failed to download provider: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if expectedSHA256 != "" && !strings.EqualFold(sum, strings.TrimPrefix(expectedSHA256, "sha256:")) {
+		cleanup()
+		return nil, "", fmt.Errorf("checksum mismatch for %s: expected sha256 %s, downloaded archive has %s", path.Base(url), expectedSHA256, sum)
+	}
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, "", err
+	}
+	return archive, sum, nil
+}
+
+// httpStatusError reports a download that did not return 200 OK
+type httpStatusError struct {
+	url    string
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("failed to download provider: HTTP %d", e.status)
+}
+
+// extractTarGz extracts a gzipped tarball into destDir, removing the first
+// strip path components of each entry. GitHub archives wrap the repository
+// in one root directory, so they are extracted with strip 1.
+//
+// Entries must stay inside destDir: absolute paths, .. components and
+// symlinks pointing outside the archive are rejected, and no entry is
+// written through a symlink. Symlinks are resolved once everything is
+// extracted, so a chain of links cannot lead outside destDir either; links to
+// missing files are rejected. Hard links and device files are not supported.
+func extractTarGz(r io.Reader, destDir string, strip int) error {
+	// Create destination directory
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	// Extract tarball
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	symlinks := make(map[string]bool)
+	var extracted int64
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		// Skip the root directories in the archive
+		parts := strings.SplitN(strings.TrimPrefix(header.Name, "./"), "/", strip+1)
+		if len(parts) < strip+1 || strings.Trim(parts[strip], "/") == "" {
+			continue
+		}
+		relativePath := filepath.FromSlash(strings.TrimSuffix(parts[strip], "/"))
+
+		if !filepath.IsLocal(relativePath) {
+			return fmt.Errorf("archive entry %q points outside the provider directory", header.Name)
+		}
+		for dir := relativePath; dir != "."; dir = filepath.Dir(dir) {
+			if symlinks[dir] {
+				return fmt.Errorf("archive entry %q is written through a symlink", header.Name)
+			}
+		}
+
+		target := filepath.Join(destDir, relativePath)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to create parent directory: %w", err)
+			}
+
+			extracted += header.Size
+			if extracted > maxExtractedSize {
+				return fmt.Errorf("archive is larger than %d MiB", maxExtractedSize>>20)
+			}
+
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm()|0600)
+			if err != nil {
+				return fmt.Errorf("failed to create file: %w", err)
+			}
+
+			if _, err := io.CopyN(f, tr, header.Size); err != nil {
+				f.Close()
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+		case tar.TypeSymlink:
+			link := filepath.FromSlash(header.Linkname)
+			if filepath.IsAbs(link) || !filepath.IsLocal(filepath.Join(filepath.Dir(relativePath), link)) {
+				return fmt.Errorf("archive entry %q links outside the provider directory (%s)", header.Name, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to create parent directory: %w", err)
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return fmt.Errorf("failed to create symlink: %w", err)
+			}
+			symlinks[relativePath] = true
+		default:
+			return fmt.Errorf("archive entry %q has unsupported type %q", header.Name, header.Typeflag)
+		}
+	}
+
+	return checkSymlinks(destDir, symlinks)
+}
+
+// checkSymlinks resolves each extracted symlink and checks that it ends up
+// inside destDir. Each link's target was checked on its own when it was
+// extracted, but a target can go through another link, e.g. a/l -> .. and
+// b -> a/l/.., which only the file system resolves.
+func checkSymlinks(destDir string, symlinks map[string]bool) error {
+	root, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return err
+	}
+	for link := range symlinks {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(destDir, link))
+		if err != nil {
+			return fmt.Errorf("archive symlink %q does not resolve to a file in the provider directory: %w", filepath.ToSlash(link), err)
+		}
+		if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) && rel != "." {
+			return fmt.Errorf("archive symlink %q links outside the provider directory", filepath.ToSlash(link))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package providers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry is one entry of a test archive; a symlink when link is set, a
+// directory when name ends in /
+type tarEntry struct {
+	name    string
+	content string
+	link    string
+}
+
+func buildTarGz(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644}
+		switch {
+		case e.link != "":
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = e.link
+		case strings.HasSuffix(e.name, "/"):
+			header.Typeflag = tar.TypeDir
+			header.Mode = 0755
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(e.content))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractTarGz(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "provider")
+	archive := buildTarGz(t, []tarEntry{
+		{name: "repo-1.0.0/"},
+		{name: "repo-1.0.0/provider.yaml", content: "name: test\n"},
+		{name: "repo-1.0.0/schemas/service.yaml", content: "type: object\n"},
+		{name: "repo-1.0.0/schema.yaml", link: "schemas/service.yaml"},
+	})
+
+	if err := extractTarGz(archive, dest, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"provider.yaml": "name: test\n",
+		"schema.yaml":   "type: object\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestExtractTarGzRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		wantErr string
+	}{
+		{
+			name:    "absolute path",
+			entries: []tarEntry{{name: "/etc/passwd", content: "x"}},
+			wantErr: "outside the provider directory",
+		},
+		{
+			name:    "parent directory entry",
+			entries: []tarEntry{{name: "../evil", content: "x"}},
+			wantErr: "outside the provider directory",
+		},
+		{
+			name:    "nested parent directory entry",
+			entries: []tarEntry{{name: "a/../../evil", content: "x"}},
+			wantErr: "outside the provider directory",
+		},
+		{
+			name:    "absolute symlink",
+			entries: []tarEntry{{name: "passwd", link: "/etc/passwd"}},
+			wantErr: "links outside the provider directory",
+		},
+		{
+			name:    "parent directory symlink",
+			entries: []tarEntry{{name: "a/up", link: "../../outside"}},
+			wantErr: "links outside the provider directory",
+		},
+		{
+			name: "write through a symlink",
+			entries: []tarEntry{
+				{name: "dir", link: "."},
+				{name: "dir/file", content: "x"},
+			},
+			wantErr: "written through a symlink",
+		},
+		{
+			name: "chained symlinks",
+			entries: []tarEntry{
+				{name: "a/"},
+				{name: "a/l", link: ".."},
+				{name: "b", link: "a/l/.."},
+			},
+			wantErr: "links outside the provider directory",
+		},
+		{
+			name: "chained symlinks to an existing directory",
+			entries: []tarEntry{
+				{name: "a/"},
+				{name: "a/l", link: ".."},
+				{name: "b", link: "a/l/../outside"},
+			},
+			wantErr: "links outside the provider directory",
+		},
+		{
+			name: "symlink created before the link it goes through",
+			entries: []tarEntry{
+				{name: "a/"},
+				{name: "b", link: "a/l/../outside"},
+				{name: "a/l", link: ".."},
+			},
+			wantErr: "links outside the provider directory",
+		},
+		{
+			name:    "dangling symlink",
+			entries: []tarEntry{{name: "missing", link: "nothing/here"}},
+			wantErr: "does not resolve",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A directory next to the provider directory, for links to
+			// resolve to when they escape
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "outside"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			dest := filepath.Join(dir, "provider")
+			err := extractTarGz(buildTarGz(t, tt.entries), dest, 0)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("extractTarGz() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
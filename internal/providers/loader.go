@@ -5,137 +5,81 @@ import (
 	"os"
 	"path/filepath"
 
-	"gopkg.in/yaml.v3"
+	"github.com/sourceplane/sourceplane/internal/config"
+	"github.com/sourceplane/sourceplane/internal/parser"
 )
 
-// Provider represents a provider definition loaded from provider.yaml
-type Provider struct {
-	Name       string                 `yaml:"name"`
-	Version    string                 `yaml:"version"`
-	APIVersion string                 `yaml:"apiVersion"`
-	Kind       string                 `yaml:"kind"`
-	Kinds      []ProviderKind         `yaml:"kinds"`
-	Extensions map[string]interface{} `yaml:",inline"`
-}
-
-// ProviderKind represents a supported component kind
-type ProviderKind struct {
-	Name        string `yaml:"name"`
-	FullType    string `yaml:"fullType"`
-	Description string `yaml:"description"`
-	Category    string `yaml:"category"`
-}
-
-// IntentProviderConfig represents provider configuration in intent.yaml
-type IntentProviderConfig struct {
-	Source  string `yaml:"source,omitempty"`
-	Version string `yaml:"version"`
-}
-
 // LoadProvidersFromIntent loads providers defined in an intent.yaml file
-func LoadProvidersFromIntent(intentPath string) (map[string]*Provider, error) {
-	// Read intent.yaml
-	data, err := os.ReadFile(intentPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read intent file: %w", err)
-	}
-
-	// Parse intent
-	var intent struct {
-		Providers map[string]IntentProviderConfig `yaml:"providers"`
-	}
-
-	if err := yaml.Unmarshal(data, &intent); err != nil {
-		return nil, fmt.Errorf("failed to parse intent file: %w", err)
-	}
-
-	// Initialize provider cache
-	cache, err := NewProviderCache()
+func LoadProvidersFromIntent(cfg *config.Config, intentPath string) (map[string]*Provider, error) {
+	intent, err := parser.LoadRepository(intentPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize provider cache: %w", err)
+		return nil, err
 	}
 
-	// Load each provider
+	resolver := NewResolver(cfg, filepath.Dir(intentPath))
 	providers := make(map[string]*Provider)
-	intentDir := filepath.Dir(intentPath)
-
 	for name, config := range intent.Providers {
 		fmt.Fprintf(os.Stderr, "Loading provider: %s\n", name)
 
-		var provider *Provider
-
-		if config.Source != "" {
-			// Load from remote source
-			provider, err = cache.LoadProviderFromCache(config.Source, config.Version)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load provider %s: %w", name, err)
-			}
-		} else {
-			// Legacy: Load from local providers directory
-			providerPath := filepath.Join(intentDir, "providers", name, "provider.yaml")
-			providerData, err := os.ReadFile(providerPath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read provider %s: %w", name, err)
-			}
-
-			provider = &Provider{}
-			if err := yaml.Unmarshal(providerData, provider); err != nil {
-				return nil, fmt.Errorf("failed to parse provider %s: %w", name, err)
-			}
+		provider, err := resolver.Resolve(name, config)
+		if err != nil {
+			return nil, err
 		}
-
 		providers[name] = provider
 	}
 
 	return providers, nil
 }
 
-// InitProviders downloads all providers specified in intent.yaml
-func InitProviders(intentPath string) error {
-	// Read intent.yaml
-	data, err := os.ReadFile(intentPath)
-	if err != nil {
-		return fmt.Errorf("failed to read intent file: %w", err)
-	}
-
-	var intent struct {
-		Providers map[string]IntentProviderConfig `yaml:"providers"`
-	}
+// InitProviders downloads all providers specified in intent.yaml and
+// records them in intent.lock. Providers that are already locked keep their
+// locked version.
+func InitProviders(cfg *config.Config, intentPath string) error {
+	return lockProviders(cfg, intentPath, LockUpdate)
+}
 
-	if err := yaml.Unmarshal(data, &intent); err != nil {
-		return fmt.Errorf("failed to parse intent file: %w", err)
-	}
+// UpgradeProviders resolves all providers specified in intent.yaml afresh,
+// ignoring locked versions, and rewrites intent.lock
+func UpgradeProviders(cfg *config.Config, intentPath string) error {
+	return lockProviders(cfg, intentPath, LockUpgrade)
+}
 
-	// Initialize provider cache
-	cache, err := NewProviderCache()
+func lockProviders(cfg *config.Config, intentPath string, mode LockMode) error {
+	intent, err := parser.LoadRepository(intentPath)
 	if err != nil {
-		return fmt.Errorf("failed to initialize provider cache: %w", err)
+		return err
 	}
 
-	// Download each provider
-	manifest := make(map[string]string)
+	dir := filepath.Dir(intentPath)
+	resolver := NewResolver(cfg, dir)
+	resolver.SetLockMode(mode)
 
-	for name, config := range intent.Providers {
+	for _, name := range sortedNames(intent.Providers) {
+		config := intent.Providers[name]
 		if config.Source == "" {
 			fmt.Fprintf(os.Stderr, "Skipping %s (local provider)\n", name)
 			continue
 		}
+		if source, err := ParseProviderSource(config.Source); err == nil && source.Type == "local" {
+			fmt.Fprintf(os.Stderr, "Skipping %s (local provider at %s)\n", name, config.Source)
+			continue
+		}
 
-		fmt.Fprintf(os.Stderr, "Initializing provider: %s@%s from %s\n", name, config.Version, config.Source)
+		fmt.Fprintf(os.Stderr, "Initializing provider: %s from %s\n", name, declaration(config.Source, config.Version))
 
-		providerPath, err := cache.GetProviderPath(config.Source, config.Version)
+		provider, err := resolver.Resolve(name, config)
 		if err != nil {
 			return fmt.Errorf("failed to initialize provider %s: %w", name, err)
 		}
 
-		manifest[name] = providerPath
-		fmt.Fprintf(os.Stderr, "✓ Provider %s ready at %s\n", name, providerPath)
+		locked, _ := resolver.Locked(name)
+		fmt.Fprintf(os.Stderr, "✓ Provider %s %s ready at %s\n", name, locked.Version, provider.Dir)
 	}
 
-	// Save manifest
-	if err := cache.SaveCacheManifest(manifest); err != nil {
-		return fmt.Errorf("failed to save manifest: %w", err)
+	if err := resolver.Lock().Save(dir); err != nil {
+		return err
 	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", filepath.Join(dir, LockFileName))
 
 	return nil
 }
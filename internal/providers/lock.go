@@ -0,0 +1,186 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the name of the lock file kept next to intent.yaml
+const LockFileName = "intent.lock"
+
+const lockFileVersion = 1
+
+const lockFileHeader = `# This file is maintained by 'sp providers init' and 'sp providers upgrade'.
+# It records the exact provider versions plans use; commit it with intent.yaml.
+`
+
+// LockFile records the exact version and content hash of each remote
+// provider an intent uses
+type LockFile struct {
+	Version   int                       `yaml:"version"`
+	Providers map[string]LockedProvider `yaml:"providers"`
+}
+
+// LockedProvider is a provider pinned by the lock file
+type LockedProvider struct {
+	Source     string `yaml:"source"`
+	Constraint string `yaml:"constraint,omitempty"` // Version as declared in intent.yaml
+	Version    string `yaml:"version"`              // Resolved release version or git commit
+	Hash       string `yaml:"hash"`                 // Content hash of the provider directory
+}
+
+// LockMode controls how a Resolver uses the lock file
+type LockMode int
+
+const (
+	// LockVerify uses locked versions and fails when the lock is out of date
+	// or a provider's content does not match its hash. Without a lock file
+	// providers are resolved unlocked.
+	LockVerify LockMode = iota
+	// LockUpdate keeps locked versions that still match the intent and locks
+	// providers that are new or whose declaration changed
+	LockUpdate
+	// LockUpgrade ignores the lock file and resolves every provider afresh
+	LockUpgrade
+)
+
+// NewLockFile creates an empty lock file
+func NewLockFile() *LockFile {
+	return &LockFile{
+		Version:   lockFileVersion,
+		Providers: make(map[string]LockedProvider),
+	}
+}
+
+// LoadLockFile reads the lock file in dir. It returns nil when there is none.
+func LoadLockFile(dir string) (*LockFile, error) {
+	path := filepath.Join(dir, LockFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", LockFileName, err)
+	}
+	return ParseLockFile(path, data)
+}
+
+// ParseLockFile parses the content of a lock file; path is used in errors
+func ParseLockFile(path string, data []byte) (*LockFile, error) {
+	lock := NewLockFile()
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if lock.Version != lockFileVersion {
+		return nil, fmt.Errorf("%s has unsupported version %d", path, lock.Version)
+	}
+	if lock.Providers == nil {
+		lock.Providers = make(map[string]LockedProvider)
+	}
+	return lock, nil
+}
+
+// Save writes the lock file to dir
+func (l *LockFile) Save(dir string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, LockFileName)
+	if err := os.WriteFile(path, append([]byte(lockFileHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", LockFileName, err)
+	}
+	return nil
+}
+
+// Matches reports whether a locked provider was resolved from the given
+// intent declaration
+func (p LockedProvider) Matches(source, constraint string) bool {
+	return p.Source == source && p.Constraint == constraint
+}
+
+// HashDir computes the content hash of a provider directory. The hash
+// covers the path and content of every file, so it changes when files are
+// added, removed, renamed or edited. Git metadata and cache markers are
+// ignored.
+func HashDir(dir string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == ".lock" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", dir, err)
+	}
+	sort.Strings(files)
+
+	summary := sha256.New()
+	for _, file := range files {
+		sum, err := hashFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", dir, err)
+		}
+		fmt.Fprintf(summary, "%s  %s\n", sum, file)
+	}
+	return "sha256:" + hex.EncodeToString(summary.Sum(nil)), nil
+}
+
+// hashFile hashes a file's content, or a symlink's target
+func hashFile(path string) (string, error) {
+	h := sha256.New()
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, "symlink:"+target)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// shortHash abbreviates a hash for messages
+func shortHash(hash string) string {
+	algorithm, sum, found := strings.Cut(hash, ":")
+	if !found || len(sum) <= 12 {
+		return hash
+	}
+	return algorithm + ":" + sum[:12]
+}
@@ -0,0 +1,245 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourceplane/sourceplane/internal/config"
+	"github.com/sourceplane/sourceplane/internal/models"
+)
+
+// newLockTestResolver returns a resolver for intents in baseDir that
+// downloads registry providers into a temporary cache
+func newLockTestResolver(t *testing.T, baseDir string, mode LockMode) *Resolver {
+	t.Helper()
+	resolver := NewResolver(&config.Config{CachePath: t.TempDir()}, baseDir)
+	resolver.cache = &ProviderCache{baseDir: t.TempDir(), registryURL: "https://registry.invalid"}
+	resolver.SetLockMode(mode)
+	return resolver
+}
+
+func TestResolverLock(t *testing.T) {
+	source := newTestRegistry(t).URL + "/sourceplane/helm"
+	declared := models.Provider{Source: source, Version: "^0.1"}
+
+	// Lock the provider as `sp providers init` does
+	baseDir := t.TempDir()
+	resolver := newLockTestResolver(t, baseDir, LockUpdate)
+	if _, err := resolver.Resolve("helm", declared); err != nil {
+		t.Fatal(err)
+	}
+	locked, ok := resolver.Locked("helm")
+	if !ok || locked.Version != "0.1.0" || locked.Constraint != "^0.1" || !strings.HasPrefix(locked.Hash, "sha256:") {
+		t.Fatalf("Locked(helm) = %+v, %v, want version 0.1.0 with a hash", locked, ok)
+	}
+	if err := resolver.Lock().Save(baseDir); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := LoadLockFile(baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Providers["helm"] != locked {
+		t.Fatalf("saved lock = %+v, want %+v", lock.Providers["helm"], locked)
+	}
+
+	tests := []struct {
+		name     string
+		mode     LockMode
+		declared models.Provider
+		edit     func(lock *LockFile)
+		wantErr  string
+	}{
+		{name: "lock matches", declared: declared},
+		{
+			name:     "hash mismatch",
+			declared: declared,
+			edit: func(lock *LockFile) {
+				entry := lock.Providers["helm"]
+				entry.Hash = "sha256:" + strings.Repeat("0", 64)
+				lock.Providers["helm"] = entry
+			},
+			wantErr: "checksum mismatch for provider 'helm' 0.1.0: intent.lock has sha256:000000000000, downloaded content has " + shortHash(locked.Hash),
+		},
+		{
+			name:     "stale lock",
+			declared: models.Provider{Source: source, Version: "^0.2"},
+			wantErr:  "provider 'helm' was locked for " + source + " ^0.1 but intent.yaml declares " + source + " ^0.2",
+		},
+		{
+			name:     "missing lock entry",
+			declared: declared,
+			edit:     func(lock *LockFile) { delete(lock.Providers, "helm") },
+			wantErr:  "provider 'helm' is not in intent.lock",
+		},
+		{
+			name:     "stale lock is updated",
+			mode:     LockUpdate,
+			declared: models.Provider{Source: source, Version: "^0.2"},
+		},
+		{
+			name:     "missing lock entry is added",
+			mode:     LockUpdate,
+			declared: declared,
+			edit:     func(lock *LockFile) { delete(lock.Providers, "helm") },
+		},
+		{
+			name:     "upgrade ignores the lock",
+			mode:     LockUpgrade,
+			declared: declared,
+			edit: func(lock *LockFile) {
+				entry := lock.Providers["helm"]
+				entry.Hash = "sha256:" + strings.Repeat("0", 64)
+				lock.Providers["helm"] = entry
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			edited, err := LoadLockFile(baseDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.edit != nil {
+				tt.edit(edited)
+			}
+			if err := edited.Save(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			resolver := newLockTestResolver(t, dir, tt.mode)
+			_, err = resolver.Resolve("helm", tt.declared)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := resolver.Locked("helm"); !ok {
+				t.Error("resolved provider is not locked")
+			}
+		})
+	}
+}
+
+// TestResolverLockPinsVersion checks that a locked version is used even when
+// a newer release matches the declared constraint
+func TestResolverLockPinsVersion(t *testing.T) {
+	source := newTestRegistry(t).URL + "/sourceplane/helm"
+	declared := models.Provider{Source: source, Version: ">=0.1.0, <1.0.0"}
+
+	baseDir := t.TempDir()
+	first, err := newLockTestResolver(t, baseDir, LockUpdate).Resolve("helm", declared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != "0.2.0" {
+		t.Fatalf("resolved version = %s, want 0.2.0", first.Version)
+	}
+
+	lock := NewLockFile()
+	hash, err := HashDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Providers["helm"] = LockedProvider{Source: source, Constraint: declared.Version, Version: "0.1.0", Hash: hash}
+	if err := lock.Save(baseDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The locked version is downloaded, and its content checked
+	_, err = newLockTestResolver(t, baseDir, LockVerify).Resolve("helm", declared)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch for provider 'helm' 0.1.0") {
+		t.Errorf("Resolve() error = %v, want the locked version 0.1.0 to be verified", err)
+	}
+}
+
+func TestParseLockFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: "version: 1\nproviders:\n  helm:\n    source: sourceplane/helm\n    version: 0.1.0\n    hash: sha256:abc\n"},
+		{name: "no providers", data: "version: 1\n"},
+		{name: "unsupported version", data: "version: 2\nproviders: {}\n", wantErr: "intent.lock has unsupported version 2"},
+		{name: "invalid yaml", data: "version: [1\n", wantErr: "failed to parse intent.lock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock, err := ParseLockFile(LockFileName, []byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseLockFile() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lock.Providers == nil {
+				t.Error("ParseLockFile() left Providers nil")
+			}
+		})
+	}
+
+	lock, err := LoadLockFile(t.TempDir())
+	if lock != nil || err != nil {
+		t.Errorf("LoadLockFile() without a lock file = %v, %v, want nil, nil", lock, err)
+	}
+}
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		h, err := HashDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	write("provider.yaml", "name: helm\n")
+	write("templates/job.yaml", "commands: []\n")
+	base := hash()
+
+	// Git metadata and cache markers are ignored
+	write(".git/HEAD", "ref: refs/heads/main\n")
+	write(".lock", "")
+	if got := hash(); got != base {
+		t.Errorf("hash changed after adding .git and .lock: %s, want %s", got, base)
+	}
+
+	write("templates/job.yaml", "commands: [helm lint]\n")
+	edited := hash()
+	if edited == base {
+		t.Error("hash did not change when a file was edited")
+	}
+
+	if err := os.Rename(filepath.Join(dir, "templates", "job.yaml"), filepath.Join(dir, "templates", "jobs.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if hash() == edited {
+		t.Error("hash did not change when a file was renamed")
+	}
+}
@@ -0,0 +1,350 @@
+package providers
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourceplane/sourceplane/internal/glob"
+	"github.com/sourceplane/sourceplane/internal/schema"
+)
+
+// Provider is a provider definition loaded from provider.yaml. It is the one
+// provider model shared by lint, the component and org commands and thin-ci.
+type Provider struct {
+	Name         string          `yaml:"name"`
+	Version      string          `yaml:"version"`
+	APIVersion   string          `yaml:"apiVersion"`
+	Kind         string          `yaml:"kind"`
+	Metadata     ProviderInfo    `yaml:"metadata,omitempty"`
+	Kinds        []ProviderKind  `yaml:"kinds"`
+	Capabilities map[string]bool `yaml:"capabilities,omitempty"`
+	ThinCI       ThinCIConfig    `yaml:"thinCI,omitempty"`
+
+	// Extensions holds sections this model does not describe, such as
+	// execution, config and hooks
+	Extensions map[string]interface{} `yaml:",inline"`
+
+	// Dir is the directory holding provider.yaml and the kind schemas
+	Dir string `yaml:"-"`
+	// Source is where the provider was resolved from, e.g. a local path or
+	// github.com/owner/repo
+	Source string `yaml:"-"`
+}
+
+// ProviderInfo describes a provider
+type ProviderInfo struct {
+	Description   string `yaml:"description,omitempty"`
+	Maintainer    string `yaml:"maintainer,omitempty"`
+	Repository    string `yaml:"repository,omitempty"`
+	Documentation string `yaml:"documentation,omitempty"`
+}
+
+// ProviderKind represents a supported component kind
+type ProviderKind struct {
+	Name        string `yaml:"name"`
+	FullType    string `yaml:"fullType"`
+	Description string `yaml:"description"`
+	Category    string `yaml:"category"`
+	Schema      string `yaml:"schema,omitempty"` // Spec schema, relative to the provider directory
+}
+
+// ThinCIConfig holds thin-ci specific provider configuration
+type ThinCIConfig struct {
+	Actions  []ProviderAction     `yaml:"actions"`
+	Inputs   map[string]InputSpec `yaml:"inputs,omitempty"` // Typed job inputs with defaults
+	Defaults map[string]any       `yaml:"defaults,omitempty"`
+	Ordering []string             `yaml:"ordering,omitempty"` // Default action ordering
+
+	ChangeDetection ChangeDetection `yaml:"changeDetection,omitempty"`
+}
+
+// ChangeDetection declares which changed files affect a provider's
+// components:
+//
+//	thinCI:
+//	  changeDetection:
+//	    specPaths:                # spec fields holding the component's own paths
+//	      - chart.path
+//	      - valuesPath
+//	    conventionPath: "helm/{{.component}}"  # used when no spec path is set
+//	    sharedPaths:              # files affecting every component of the provider
+//	      - helm/charts
+//	    ignorePaths:              # files that never affect a component
+//	      - "*.md"
+//
+// Paths are relative to the intent file's directory and use the glob package's
+// syntax: a path matches the file itself and everything below it, ** matches
+// any number of directories and a leading ! excludes files.
+// conventionPath is a template rendered with component and provider.
+// Providers that declare nothing use DefaultChangeDetection.
+type ChangeDetection struct {
+	SpecPaths      []string `json:"specPaths,omitempty" yaml:"specPaths,omitempty"`
+	ConventionPath string   `json:"conventionPath,omitempty" yaml:"conventionPath,omitempty"`
+	SharedPaths    []string `json:"sharedPaths,omitempty" yaml:"sharedPaths,omitempty"`
+	IgnorePaths    []string `json:"ignorePaths,omitempty" yaml:"ignorePaths,omitempty"`
+}
+
+// Default change detection for providers that declare none
+const (
+	DefaultSpecPath       = "path"
+	DefaultConventionPath = "{{.provider}}/{{.component}}"
+)
+
+// builtinChangeDetection holds the rules the planner had built in before
+// providers declared their own, for providers that still declare none
+var builtinChangeDetection = map[string]ChangeDetection{
+	"terraform": {
+		SpecPaths:   []string{"module.source", "path"},
+		SharedPaths: []string{"terraform/modules"},
+	},
+}
+
+// DefaultChangeDetection returns the change detection of a provider that
+// declares none: the built-in rules for the provider, if any, with the spec
+// field `path` and the convention <provider>/<component>
+func DefaultChangeDetection(provider string) ChangeDetection {
+	rules := builtinChangeDetection[provider]
+	if len(rules.SpecPaths) == 0 {
+		rules.SpecPaths = []string{DefaultSpecPath}
+	}
+	if rules.ConventionPath == "" {
+		rules.ConventionPath = DefaultConventionPath
+	}
+	return rules
+}
+
+// IsZero reports whether nothing is declared
+func (c ChangeDetection) IsZero() bool {
+	return len(c.SpecPaths) == 0 && c.ConventionPath == "" && len(c.SharedPaths) == 0 && len(c.IgnorePaths) == 0
+}
+
+// Validate checks the declared path patterns
+func (c ChangeDetection) Validate() error {
+	for _, patterns := range [][]string{c.SharedPaths, c.IgnorePaths} {
+		for _, pattern := range patterns {
+			if err := glob.Validate(pattern); err != nil {
+				return err
+			}
+		}
+	}
+	for _, field := range c.SpecPaths {
+		if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") {
+			return fmt.Errorf("invalid spec field %q", field)
+		}
+	}
+	return nil
+}
+
+// ProviderAction describes what a provider can do in CI
+type ProviderAction struct {
+	Name        string         `json:"name" yaml:"name"` // plan, apply, destroy, validate
+	Description string         `json:"description" yaml:"description"`
+	Order       int            `json:"order" yaml:"order"`                                 // Execution order relative to other actions
+	JobTemplate map[string]any `json:"jobTemplate,omitempty" yaml:"jobTemplate,omitempty"` // Provider-defined job structure template
+	Commands    []string       `json:"commands,omitempty" yaml:"commands,omitempty"`
+	PreSteps    []ActionStep   `json:"preSteps,omitempty" yaml:"preSteps,omitempty"`
+	PostSteps   []ActionStep   `json:"postSteps,omitempty" yaml:"postSteps,omitempty"`
+	Inputs      map[string]any `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Outputs     []string       `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// ActionStep represents a single step within an action
+type ActionStep struct {
+	Name    string         `json:"name" yaml:"name"`
+	Command string         `json:"command" yaml:"command"`
+	Inputs  map[string]any `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Timeout string         `json:"timeout,omitempty" yaml:"timeout,omitempty"` // e.g. "5m"; plain numbers are minutes
+}
+
+// Input types a provider may declare
+const (
+	InputTypeString  = "string"
+	InputTypeNumber  = "number"
+	InputTypeInteger = "integer"
+	InputTypeBoolean = "boolean"
+	InputTypeObject  = "object"
+	InputTypeArray   = "array"
+)
+
+// InputSpec declares a job input in a provider's thinCI section:
+//
+//	thinCI:
+//	  inputs:
+//	    namespace:
+//	      type: string
+//	      default: default
+//	    chartPath:
+//	      type: string
+//	      required: true
+//
+// String defaults are templates rendered with the job's core fields,
+// e.g. `default: "{{.component}}"`. `from` names a dotted path in the
+// component spec the input is read from, e.g. `from: chart.path`.
+type InputSpec struct {
+	Type        string `json:"type,omitempty" yaml:"type,omitempty"`
+	Default     any    `json:"default,omitempty" yaml:"default,omitempty"`
+	Required    bool   `json:"required,omitempty" yaml:"required,omitempty"`
+	From        string `json:"from,omitempty" yaml:"from,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Validate checks that the declared type is known and the default matches it
+func (s InputSpec) Validate() error {
+	switch s.Type {
+	case "", InputTypeString, InputTypeNumber, InputTypeInteger, InputTypeBoolean, InputTypeObject, InputTypeArray:
+	default:
+		return fmt.Errorf("unknown type %q", s.Type)
+	}
+	if s.Default != nil {
+		if err := s.Check(s.Default); err != nil {
+			return fmt.Errorf("default %w", err)
+		}
+	}
+	return nil
+}
+
+// Check reports whether value matches the declared type
+func (s InputSpec) Check(value any) error {
+	ok := true
+	switch s.Type {
+	case InputTypeString:
+		_, ok = value.(string)
+	case InputTypeNumber:
+		_, ok = toFloat(value)
+	case InputTypeInteger:
+		f, isNumber := toFloat(value)
+		ok = isNumber && f == math.Trunc(f)
+	case InputTypeBoolean:
+		_, ok = value.(bool)
+	case InputTypeObject:
+		_, ok = value.(map[string]any)
+	case InputTypeArray:
+		_, ok = value.([]any)
+		if !ok {
+			_, ok = value.([]string)
+		}
+	}
+	if !ok {
+		return fmt.Errorf("must be of type %s, got %T", s.Type, value)
+	}
+	return nil
+}
+
+// toFloat converts numeric values decoded from JSON or YAML to float64
+func toFloat(val any) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// LoadProviderFile loads a provider definition from a provider.yaml file
+func LoadProviderFile(path string) (*Provider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider.yaml: %w", err)
+	}
+
+	var provider Provider
+	if err := yaml.Unmarshal(data, &provider); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	provider.Dir = filepath.Dir(path)
+
+	if err := provider.ThinCI.ChangeDetection.Validate(); err != nil {
+		return nil, fmt.Errorf("provider %s has invalid thinCI changeDetection: %w", provider.Name, err)
+	}
+
+	names := make([]string, 0, len(provider.ThinCI.Inputs))
+	for name := range provider.ThinCI.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := provider.ThinCI.Inputs[name].Validate(); err != nil {
+			return nil, fmt.Errorf("provider %s has invalid thinCI input %s: %w", provider.Name, name, err)
+		}
+	}
+
+	return &provider, nil
+}
+
+// ValidateComponentType checks if a component type is supported by a provider
+func (p *Provider) ValidateComponentType(componentType string) error {
+	// Check if the component type matches provider.kind format
+	expectedPrefix := p.Name + "."
+	if !strings.HasPrefix(componentType, expectedPrefix) {
+		return fmt.Errorf("component type '%s' does not match provider '%s' (expected format: %s<kind>)",
+			componentType, p.Name, expectedPrefix)
+	}
+
+	// Extract the kind from the type (e.g., "helm.service" -> "service")
+	kind := strings.TrimPrefix(componentType, expectedPrefix)
+
+	// Check if this kind is supported by the provider
+	for _, supportedKind := range p.Kinds {
+		if supportedKind.Name == kind || supportedKind.FullType == componentType {
+			return nil
+		}
+	}
+
+	// Build list of supported types
+	supportedTypes := make([]string, len(p.Kinds))
+	for i, k := range p.Kinds {
+		supportedTypes[i] = k.FullType
+	}
+
+	return fmt.Errorf("component type '%s' is not supported by provider '%s' (supported types: %s)",
+		componentType, p.Name, strings.Join(supportedTypes, ", "))
+}
+
+// KindSchema loads the spec schema for a component type. The schema is
+// looked up, in order, at the path the kind declares with `schema:`, at
+// schemas/<kind>.yaml, and at the provider-wide schema.yaml. It returns nil
+// when the provider has no schema.
+func (p *Provider) KindSchema(componentType string) (*schema.Schema, error) {
+	kind := componentType
+	if _, after, found := strings.Cut(componentType, "."); found {
+		kind = after
+	}
+
+	for _, k := range p.Kinds {
+		if (k.Name == kind || k.FullType == componentType) && k.Schema != "" {
+			return schema.Load(filepath.Join(p.Dir, k.Schema))
+		}
+	}
+
+	if p.Dir == "" {
+		return nil, nil
+	}
+	for _, path := range []string{
+		filepath.Join(p.Dir, "schemas", kind+".yaml"),
+		filepath.Join(p.Dir, "schema.yaml"),
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return schema.Load(path)
+		}
+	}
+	return nil, nil
+}
+
+// GetProviderNameFromType extracts the provider name from a component type
+// e.g., "helm.service" -> "helm"
+func GetProviderNameFromType(componentType string) string {
+	parts := strings.SplitN(componentType, ".", 2)
+	if len(parts) > 0 {
+		return parts[0]
+	}
+	return ""
+}
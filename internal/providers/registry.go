@@ -1,44 +1,38 @@
 package providers
 
 import (
-	"archive/tar"
-	"compress/gzip"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"gopkg.in/yaml.v3"
+	"github.com/sourceplane/sourceplane/internal/config"
+	"github.com/sourceplane/sourceplane/internal/semver"
 )
 
 // ProviderSource represents where to fetch a provider from
 type ProviderSource struct {
-	Type    string // "github", "local", "registry"
+	Type    string // "github", "git", "local", "registry"
 	URL     string
 	Version string
 }
 
 // ProviderCache manages local provider caching
 type ProviderCache struct {
-	baseDir string
+	baseDir     string
+	gitHubURL   string
+	registryURL string
 }
 
-// NewProviderCache creates a new provider cache
-func NewProviderCache() (*ProviderCache, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	cacheDir := filepath.Join(home, ".sourceplane", "providers")
+// NewProviderCache creates a provider cache in the configured cache directory
+func NewProviderCache(cfg *config.Config) (*ProviderCache, error) {
+	cacheDir := cfg.ProviderCacheDir()
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	return &ProviderCache{baseDir: cacheDir}, nil
+	return &ProviderCache{baseDir: cacheDir, gitHubURL: "https://github.com", registryURL: cfg.RegistryURL}, nil
 }
 
 // ParseProviderSource parses a provider source string
@@ -48,7 +42,7 @@ func ParseProviderSource(source string) (*ProviderSource, error) {
 	}
 
 	// Check if it's a local path
-	if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "/") || strings.HasPrefix(source, "file://") {
+	if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") || strings.HasPrefix(source, "/") || strings.HasPrefix(source, "file://") {
 		return &ProviderSource{
 			Type: "local",
 			URL:  strings.TrimPrefix(source, "file://"),
@@ -63,6 +57,23 @@ func ParseProviderSource(source string) (*ProviderSource, error) {
 		}, nil
 	}
 
+	// Git is only cloned over https, so a plain http source is a registry,
+	// such as one served locally
+	if strings.HasPrefix(source, "http://") {
+		return &ProviderSource{
+			Type: "registry",
+			URL:  source,
+		}, nil
+	}
+
+	// Other hosts are cloned with git
+	if IsRemoteSource(source) {
+		return &ProviderSource{
+			Type: "git",
+			URL:  source,
+		}, nil
+	}
+
 	// Default to registry
 	return &ProviderSource{
 		Type: "registry",
@@ -82,130 +93,159 @@ func (c *ProviderCache) GetProviderPath(source, version string) (string, error)
 		return ps.URL, nil
 	case "github":
 		return c.getGitHubProvider(ps.URL, version)
+	case "git":
+		return "", fmt.Errorf("git provider source %s is fetched with ProviderFetcher, not the release cache", source)
+	case "registry":
+		return c.getRegistryProvider(ps.URL, version)
 	default:
 		return "", fmt.Errorf("unsupported provider source type: %s", ps.Type)
 	}
 }
 
-// getGitHubProvider downloads and caches a provider from GitHub
+// getGitHubProvider downloads and caches a provider from GitHub. Sources of
+// the form github.com/owner/repo/path name a provider in a subdirectory of
+// the repository.
 func (c *ProviderCache) getGitHubProvider(source, version string) (string, error) {
-	// Parse GitHub source: github.com/owner/repo
-	parts := strings.Split(strings.TrimPrefix(source, "github.com/"), "/")
-	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid GitHub source format: %s", source)
+	owner, repo, subdir, err := parseGitHubSource(source)
+	if err != nil {
+		return "", err
 	}
 
-	owner := parts[0]
-	repo := parts[1]
+	release, err := c.ResolveVersion(source, version)
+	if err != nil {
+		return "", err
+	}
 
-	// Clean version string (remove >= or other operators)
-	cleanVersion := strings.TrimPrefix(version, ">=")
-	cleanVersion = strings.TrimPrefix(cleanVersion, "~>")
-	cleanVersion = strings.TrimSpace(cleanVersion)
+	providerDir := filepath.Join(c.baseDir, owner, repo, release)
+	err = install(providerDir, release, func(tmp string) (string, error) {
+		return c.downloadGitHubProvider(owner, repo, release, tmp)
+	})
+	if err != nil {
+		return "", err
+	}
 
-	// Create provider cache directory
-	providerDir := filepath.Join(c.baseDir, owner, repo, cleanVersion)
-	lockFile := filepath.Join(providerDir, ".lock")
+	return filepath.Join(providerDir, subdir), nil
+}
 
-	// Check if already cached
-	if _, err := os.Stat(lockFile); err == nil {
-		return providerDir, nil
+// getRegistryProvider downloads and caches a provider from a registry
+func (c *ProviderCache) getRegistryProvider(source, version string) (string, error) {
+	registryURL, name, err := parseRegistrySource(source, c.registryURL)
+	if err != nil {
+		return "", err
 	}
+	client := NewRegistryClient(registryURL)
 
-	// Download provider
-	if err := c.downloadGitHubProvider(owner, repo, cleanVersion, providerDir); err != nil {
+	release, err := c.ResolveVersion(source, version)
+	if err != nil {
 		return "", err
 	}
 
-	// Create lock file
-	if err := os.WriteFile(lockFile, []byte(cleanVersion), 0644); err != nil {
-		return "", fmt.Errorf("failed to create lock file: %w", err)
+	host := strings.TrimPrefix(strings.TrimPrefix(registryURL, "https://"), "http://")
+	providerDir := filepath.Join(c.baseDir, "registry", strings.ReplaceAll(host, ":", "_"), name, release)
+	err = install(providerDir, release, func(tmp string) (string, error) {
+		published, err := client.ResolveVersion(name, release)
+		if err != nil {
+			return "", err
+		}
+		if err := client.Download(published, tmp); err != nil {
+			return "", err
+		}
+		return published.SHA256, nil
+	})
+	if err != nil {
+		return "", err
 	}
 
 	return providerDir, nil
 }
 
-// downloadGitHubProvider downloads a provider release from GitHub
-func (c *ProviderCache) downloadGitHubProvider(owner, repo, version, destDir string) error {
-	// If version doesn't start with 'v', add it
-	tagVersion := version
-	if !strings.HasPrefix(tagVersion, "v") {
-		tagVersion = "v" + tagVersion
+// ResolveVersion returns the highest release of a GitHub or registry
+// provider that satisfies a version constraint, e.g. 0.3.1 for
+// ">=0.2, <1.0". GitHub releases are the repository's version tags. An
+// exact version is returned as is, without asking the source, so pinned
+// providers resolve offline.
+func (c *ProviderCache) ResolveVersion(source, constraint string) (string, error) {
+	constraints, err := semver.ParseConstraints(constraint)
+	if err != nil {
+		return "", err
+	}
+	if exact, ok := constraints.Exact(); ok {
+		return exact.String(), nil
 	}
 
-	// Construct GitHub release URL
-	url := fmt.Sprintf("https://github.com/%s/%s/archive/refs/tags/%s.tar.gz", owner, repo, tagVersion)
-
-	fmt.Fprintf(os.Stderr, "Downloading provider from %s...\n", url)
-
-	// Download tarball
-	resp, err := http.Get(url)
+	ps, err := ParseProviderSource(source)
 	if err != nil {
-		return fmt.Errorf("failed to download provider: %w", err)
+		return "", err
+	}
+	if ps.Type == "registry" {
+		registryURL, name, err := parseRegistrySource(source, c.registryURL)
+		if err != nil {
+			return "", err
+		}
+		published, err := NewRegistryClient(registryURL).ResolveVersion(name, constraint)
+		if err != nil {
+			return "", err
+		}
+		release, err := semver.Parse(published.Version)
+		if err != nil {
+			return "", err
+		}
+		return release.String(), nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download provider: HTTP %d", resp.StatusCode)
+	owner, repo, _, err := parseGitHubSource(source)
+	if err != nil {
+		return "", err
+	}
+	tags, err := listGitTags(fmt.Sprintf("%s/%s/%s", c.gitHubURL, owner, repo))
+	if err != nil {
+		return "", err
 	}
 
-	// Create destination directory
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+	tag, ok := semver.Highest(constraints, tags)
+	if !ok {
+		return "", noMatchingVersion(source, constraint, tags)
 	}
+	release, _ := semver.Parse(tag)
+	return release.String(), nil
+}
 
-	// Extract tarball
-	gzr, err := gzip.NewReader(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+// parseGitHubSource splits github.com/owner/repo[/path]
+func parseGitHubSource(source string) (owner, repo, subdir string, err error) {
+	parts := strings.Split(strings.TrimPrefix(source, "github.com/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid GitHub source format: %s", source)
 	}
-	defer gzr.Close()
+	return parts[0], parts[1], filepath.Join(parts[2:]...), nil
+}
 
-	tr := tar.NewReader(gzr)
+// downloadGitHubProvider downloads a provider release from GitHub and
+// returns the archive checksum. The release tag may be written with or
+// without a v prefix.
+func (c *ProviderCache) downloadGitHubProvider(owner, repo, version, destDir string) (string, error) {
+	for _, tag := range []string{"v" + version, version} {
+		// Construct GitHub release URL
+		url := fmt.Sprintf("%s/%s/%s/archive/refs/tags/%s.tar.gz", c.gitHubURL, owner, repo, tag)
 
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar: %w", err)
-		}
+		fmt.Fprintf(os.Stderr, "Downloading provider from %s...\n", url)
 
-		// Skip the root directory in the archive
-		parts := strings.SplitN(header.Name, "/", 2)
-		if len(parts) < 2 {
+		archive, sum, err := downloadArchive(http.DefaultClient, url, "")
+		if statusErr, ok := err.(*httpStatusError); ok && statusErr.status == http.StatusNotFound {
 			continue
 		}
-		relativePath := parts[1]
-
-		target := filepath.Join(destDir, relativePath)
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return fmt.Errorf("failed to create directory: %w", err)
-			}
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return fmt.Errorf("failed to create parent directory: %w", err)
-			}
-
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file: %w", err)
-			}
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(archive.Name())
+		defer archive.Close()
 
-			if _, err := io.Copy(f, tr); err != nil {
-				f.Close()
-				return fmt.Errorf("failed to write file: %w", err)
-			}
-			f.Close()
+		if err := extractTarGz(archive, destDir, 1); err != nil {
+			return "", err
 		}
+		return sum, nil
 	}
 
-	fmt.Fprintf(os.Stderr, "Provider cached at %s\n", destDir)
-	return nil
+	return "", fmt.Errorf("failed to download provider: release %s of github.com/%s/%s not found", version, owner, repo)
 }
 
 // LoadProviderFromCache loads a provider definition from the cache
@@ -215,19 +255,13 @@ func (c *ProviderCache) LoadProviderFromCache(source, version string) (*Provider
 		return nil, err
 	}
 
-	// Load provider.yaml
-	providerFile := filepath.Join(providerPath, "provider.yaml")
-	data, err := os.ReadFile(providerFile)
+	provider, err := LoadProviderFile(filepath.Join(providerPath, "provider.yaml"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read provider.yaml: %w", err)
-	}
-
-	var provider Provider
-	if err := yaml.Unmarshal(data, &provider); err != nil {
-		return nil, fmt.Errorf("failed to parse provider.yaml: %w", err)
+		return nil, err
 	}
+	provider.Source = source
 
-	return &provider, nil
+	return provider, nil
 }
 
 // ClearCache removes all cached providers
@@ -244,9 +278,18 @@ func (c *ProviderCache) ListCachedProviders() ([]CachedProvider, error) {
 			return err
 		}
 
+		// Skip clones and downloads that are still in progress
+		if info.IsDir() && (info.Name() == ".git" || strings.Contains(info.Name(), ".tmp-")) {
+			return filepath.SkipDir
+		}
+
 		if info.Name() == ".lock" {
 			relPath, _ := filepath.Rel(c.baseDir, filepath.Dir(path))
 			parts := strings.Split(relPath, string(filepath.Separator))
+			if len(parts) == 5 && parts[0] == "registry" {
+				// registry/<host>/<namespace>/<name>/<version>
+				parts = []string{filepath.Join(parts[1], parts[2]), parts[3], parts[4]}
+			}
 			if len(parts) >= 3 {
 				cached = append(cached, CachedProvider{
 					Owner:   parts[0],
@@ -270,32 +313,3 @@ type CachedProvider struct {
 	Version string
 	Path    string
 }
-
-// SaveCacheManifest saves a manifest of installed providers
-func (c *ProviderCache) SaveCacheManifest(providers map[string]string) error {
-	manifestPath := filepath.Join(c.baseDir, "manifest.json")
-	data, err := json.MarshalIndent(providers, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(manifestPath, data, 0644)
-}
-
-// LoadCacheManifest loads the manifest of installed providers
-func (c *ProviderCache) LoadCacheManifest() (map[string]string, error) {
-	manifestPath := filepath.Join(c.baseDir, "manifest.json")
-	data, err := os.ReadFile(manifestPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return make(map[string]string), nil
-		}
-		return nil, err
-	}
-
-	var manifest map[string]string
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return nil, err
-	}
-
-	return manifest, nil
-}
@@ -0,0 +1,231 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sourceplane/sourceplane/internal/semver"
+)
+
+// RegistryClient talks to a provider registry. A registry is a set of JSON
+// documents served over HTTP, so a static file server can host one:
+//
+//	GET <registry>/index.json
+//	    {"providers": [{"name": "sourceplane/helm", "description": "...", "latestVersion": "0.2.0"}]}
+//	GET <registry>/providers/<namespace>/<name>.json
+//	    {"name": "sourceplane/helm", "description": "...", "repository": "...",
+//	     "versions": [{"version": "0.2.0", "url": "helm-0.2.0.tar.gz", "sha256": "..."}]}
+//
+// A version's url points to a gzipped tarball with provider.yaml at its
+// root; a relative url is resolved against the provider document. The
+// sha256 is the checksum of the tarball.
+type RegistryClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// RegistryIndex lists the providers a registry offers
+type RegistryIndex struct {
+	Providers []RegistryEntry `json:"providers"`
+}
+
+// RegistryEntry summarizes a provider in the registry index
+type RegistryEntry struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	LatestVersion string `json:"latestVersion,omitempty"`
+}
+
+// RegistryProvider describes a provider and its published versions
+type RegistryProvider struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Repository  string            `json:"repository,omitempty"`
+	Versions    []RegistryVersion `json:"versions"`
+}
+
+// RegistryVersion is a published provider version
+type RegistryVersion struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+}
+
+// NewRegistryClient creates a client for the registry at baseURL
+func NewRegistryClient(baseURL string) *RegistryClient {
+	return &RegistryClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// URL returns the registry's base URL
+func (c *RegistryClient) URL() string {
+	return c.baseURL
+}
+
+// Index fetches the registry index
+func (c *RegistryClient) Index() (*RegistryIndex, error) {
+	var index RegistryIndex
+	if err := c.getJSON(c.baseURL+"/index.json", &index); err != nil {
+		if err == errNotFound {
+			return nil, fmt.Errorf("%s is not a provider registry: index.json not found", c.baseURL)
+		}
+		return nil, err
+	}
+	sort.Slice(index.Providers, func(i, j int) bool {
+		return index.Providers[i].Name < index.Providers[j].Name
+	})
+	return &index, nil
+}
+
+// Search returns the providers whose name or description contains query,
+// ignoring case. An empty query returns every provider.
+func (c *RegistryClient) Search(query string) ([]RegistryEntry, error) {
+	index, err := c.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	var matches []RegistryEntry
+	for _, entry := range index.Providers {
+		if strings.Contains(strings.ToLower(entry.Name), query) || strings.Contains(strings.ToLower(entry.Description), query) {
+			matches = append(matches, entry)
+		}
+	}
+	return matches, nil
+}
+
+// Provider fetches a provider's description and versions. name is
+// <namespace>/<name>, e.g. sourceplane/helm.
+func (c *RegistryClient) Provider(name string) (*RegistryProvider, error) {
+	if strings.Count(name, "/") != 1 || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return nil, fmt.Errorf("invalid registry provider name %q (expected namespace/name)", name)
+	}
+
+	docURL := fmt.Sprintf("%s/providers/%s.json", c.baseURL, name)
+	var provider RegistryProvider
+	if err := c.getJSON(docURL, &provider); err != nil {
+		if err == errNotFound {
+			return nil, fmt.Errorf("provider %s not found in registry %s", name, c.baseURL)
+		}
+		return nil, err
+	}
+
+	// Resolve download URLs relative to the provider document
+	base, err := url.Parse(docURL)
+	if err != nil {
+		return nil, err
+	}
+	for i, version := range provider.Versions {
+		ref, err := url.Parse(version.URL)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s version %s has an invalid url: %w", name, version.Version, err)
+		}
+		provider.Versions[i].URL = base.ResolveReference(ref).String()
+	}
+	return &provider, nil
+}
+
+// ResolveVersion returns the highest published version of a provider that
+// satisfies a version constraint
+func (c *RegistryClient) ResolveVersion(name, constraint string) (*RegistryVersion, error) {
+	constraints, err := semver.ParseConstraints(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := c.Provider(name)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, len(provider.Versions))
+	for i, v := range provider.Versions {
+		versions[i] = v.Version
+	}
+
+	best, ok := semver.Highest(constraints, versions)
+	if !ok {
+		return nil, noMatchingVersion(name, constraint, versions)
+	}
+	for i := range provider.Versions {
+		if provider.Versions[i].Version == best {
+			return &provider.Versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("version %s of %s not found", best, name)
+}
+
+// Download fetches a provider version, verifies its checksum and extracts
+// it into destDir
+func (c *RegistryClient) Download(version *RegistryVersion, destDir string) error {
+	if version.SHA256 == "" {
+		return fmt.Errorf("version %s has no sha256 checksum", version.Version)
+	}
+
+	fmt.Fprintf(os.Stderr, "Downloading provider from %s...\n", version.URL)
+
+	// The archive is verified before anything is extracted from it
+	archive, _, err := downloadArchive(c.httpClient, version.URL, version.SHA256)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	return extractTarGz(archive, destDir, 0)
+}
+
+var errNotFound = fmt.Errorf("not found")
+
+func (c *RegistryClient) getJSON(docURL string, v any) error {
+	resp, err := c.httpClient.Get(docURL)
+	if err != nil {
+		return fmt.Errorf("failed to reach registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("registry request %s failed: HTTP %d", docURL, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid registry response from %s: %w", docURL, err)
+	}
+	return nil
+}
+
+// parseRegistrySource splits a registry source into the registry URL and
+// the provider name. Sources are [[scheme://]host/]namespace/name; without a
+// host the default registry is used, and a host without a scheme is served
+// over https.
+func parseRegistrySource(source, defaultURL string) (string, string, error) {
+	scheme, rest, explicit := strings.Cut(source, "://")
+	if !explicit {
+		scheme, rest = "https", source
+	} else if scheme != "http" && scheme != "https" {
+		return "", "", fmt.Errorf("invalid registry source %q (unsupported scheme %s)", source, scheme)
+	}
+
+	parts := strings.Split(rest, "/")
+	registryURL := defaultURL
+	hasHost := len(parts) == 3 && parts[0] != "" && (explicit || strings.ContainsAny(parts[0], ".:"))
+	if hasHost {
+		registryURL = scheme + "://" + parts[0]
+		parts = parts[1:]
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || explicit && !hasHost {
+		return "", "", fmt.Errorf("invalid registry source %q (expected [[scheme://]host/]namespace/name)", source)
+	}
+	return registryURL, strings.Join(parts, "/"), nil
+}
@@ -0,0 +1,272 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry serves a registry with the provider sourceplane/helm in
+// the versions 0.1.0, 0.2.0 and 1.0.0. The archive of 1.0.0 does not match
+// its published checksum.
+func newTestRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	archives := make(map[string][]byte)
+	var versions []RegistryVersion
+	for _, version := range []string{"0.1.0", "0.2.0", "1.0.0"} {
+		archive := buildTarGz(t, []tarEntry{
+			{name: "provider.yaml", content: "name: helm\nversion: " + version + "\n"},
+		}).Bytes()
+		sum := sha256.Sum256(archive)
+		checksum := hex.EncodeToString(sum[:])
+		if version == "1.0.0" {
+			checksum = strings.Repeat("0", 64)
+		}
+
+		file := "helm-" + version + ".tar.gz"
+		archives["/archives/"+file] = archive
+		// A relative url is resolved against the provider document
+		versions = append(versions, RegistryVersion{Version: version, URL: "../../archives/" + file, SHA256: checksum})
+	}
+
+	documents := map[string]any{
+		"/index.json": RegistryIndex{Providers: []RegistryEntry{
+			{Name: "sourceplane/terraform", Description: "Terraform modules", LatestVersion: "0.3.0"},
+			{Name: "sourceplane/helm", Description: "Helm charts for Kubernetes", LatestVersion: "1.0.0"},
+		}},
+		"/providers/sourceplane/helm.json": RegistryProvider{
+			Name:        "sourceplane/helm",
+			Description: "Helm charts for Kubernetes",
+			Versions:    versions,
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if archive, ok := archives[r.URL.Path]; ok {
+			w.Write(archive)
+			return
+		}
+		document, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(document)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRegistryClientSearch(t *testing.T) {
+	client := NewRegistryClient(newTestRegistry(t).URL)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"sourceplane/helm", "sourceplane/terraform"}},
+		{query: "HELM", want: []string{"sourceplane/helm"}},
+		{query: "kubernetes", want: []string{"sourceplane/helm"}},
+		{query: "pulumi", want: nil},
+	}
+
+	for _, tt := range tests {
+		entries, err := client.Search(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, names, tt.want)
+		}
+	}
+}
+
+func TestRegistryClientNotARegistry(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := NewRegistryClient(server.URL).Index()
+	if err == nil || !strings.Contains(err.Error(), "is not a provider registry") {
+		t.Fatalf("Index() error = %v, want a not a registry error", err)
+	}
+}
+
+func TestRegistryClientProvider(t *testing.T) {
+	server := newTestRegistry(t)
+	client := NewRegistryClient(server.URL)
+
+	provider, err := client.Provider("sourceplane/helm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.Versions) != 3 {
+		t.Fatalf("got %d versions, want 3", len(provider.Versions))
+	}
+	if want := server.URL + "/archives/helm-0.1.0.tar.gz"; provider.Versions[0].URL != want {
+		t.Errorf("version url = %s, want %s", provider.Versions[0].URL, want)
+	}
+
+	if _, err := client.Provider("sourceplane/missing"); err == nil || !strings.Contains(err.Error(), "not found in registry") {
+		t.Errorf("Provider(missing) error = %v, want a not found error", err)
+	}
+	if _, err := client.Provider("helm"); err == nil || !strings.Contains(err.Error(), "invalid registry provider name") {
+		t.Errorf("Provider(helm) error = %v, want an invalid name error", err)
+	}
+}
+
+func TestRegistryClientResolveVersion(t *testing.T) {
+	client := NewRegistryClient(newTestRegistry(t).URL)
+
+	tests := []struct {
+		constraint string
+		want       string
+		wantErr    string
+	}{
+		{constraint: "", want: "1.0.0"},
+		{constraint: "^0.1", want: "0.1.0"},
+		{constraint: ">=0.1, <1.0", want: "0.2.0"},
+		{constraint: "0.2.0", want: "0.2.0"},
+		{constraint: ">=2.0", wantErr: "available: 0.1.0, 0.2.0, 1.0.0"},
+	}
+
+	for _, tt := range tests {
+		version, err := client.ResolveVersion("sourceplane/helm", tt.constraint)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveVersion(%q) error = %v, want it to contain %q", tt.constraint, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version.Version != tt.want {
+			t.Errorf("ResolveVersion(%q) = %s, want %s", tt.constraint, version.Version, tt.want)
+		}
+	}
+}
+
+func TestRegistryClientDownload(t *testing.T) {
+	client := NewRegistryClient(newTestRegistry(t).URL)
+
+	version, err := client.ResolveVersion("sourceplane/helm", "0.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "helm")
+	if err := client.Download(version, dest); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "provider.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "version: 0.2.0") {
+		t.Errorf("provider.yaml = %q, want version 0.2.0", data)
+	}
+}
+
+func TestRegistryClientDownloadChecksumMismatch(t *testing.T) {
+	client := NewRegistryClient(newTestRegistry(t).URL)
+
+	version, err := client.ResolveVersion("sourceplane/helm", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "helm")
+	err = client.Download(version, dest)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Download() error = %v, want a checksum mismatch", err)
+	}
+	// Nothing is extracted from an archive that fails verification
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("%s exists after a checksum mismatch", dest)
+	}
+
+	version.SHA256 = ""
+	if err := client.Download(version, dest); err == nil || !strings.Contains(err.Error(), "no sha256 checksum") {
+		t.Errorf("Download() without checksum error = %v, want a missing checksum error", err)
+	}
+}
+
+func TestProviderCacheRegistryProvider(t *testing.T) {
+	server := newTestRegistry(t)
+	cache := &ProviderCache{baseDir: t.TempDir(), registryURL: "https://registry.invalid"}
+
+	// A source naming an http registry is not sent to the default registry
+	source := server.URL + "/sourceplane/helm"
+	if ps, err := ParseProviderSource(source); err != nil || ps.Type != "registry" {
+		t.Fatalf("ParseProviderSource(%s) = %+v, %v, want a registry source", source, ps, err)
+	}
+
+	release, err := cache.ResolveVersion(source, "^0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release != "0.1.0" {
+		t.Errorf("ResolveVersion = %s, want 0.1.0", release)
+	}
+
+	dir, err := cache.GetProviderPath(source, release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := LoadProviderFile(filepath.Join(dir, "provider.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.Version != "0.1.0" {
+		t.Errorf("cached provider version = %s, want 0.1.0", provider.Version)
+	}
+}
+
+func TestParseRegistrySource(t *testing.T) {
+	const defaultURL = "https://registry.sourceplane.io"
+
+	tests := []struct {
+		source  string
+		url     string
+		name    string
+		wantErr bool
+	}{
+		{source: "sourceplane/helm", url: defaultURL, name: "sourceplane/helm"},
+		{source: "registry.example.com/sourceplane/helm", url: "https://registry.example.com", name: "sourceplane/helm"},
+		{source: "localhost:8080/sourceplane/helm", url: "https://localhost:8080", name: "sourceplane/helm"},
+		{source: "http://localhost:8080/sourceplane/helm", url: "http://localhost:8080", name: "sourceplane/helm"},
+		{source: "https://registry.example.com/sourceplane/helm", url: "https://registry.example.com", name: "sourceplane/helm"},
+		{source: "http://sourceplane/helm", wantErr: true},
+		{source: "http:///sourceplane/helm", wantErr: true},
+		{source: "ftp://example.com/sourceplane/helm", wantErr: true},
+		{source: "helm", wantErr: true},
+		{source: "a/b/c/d", wantErr: true},
+		{source: "sourceplane/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		url, name, err := parseRegistrySource(tt.source, defaultURL)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseRegistrySource(%q) = %s, %s, want an error", tt.source, url, name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRegistrySource(%q): %v", tt.source, err)
+			continue
+		}
+		if url != tt.url || name != tt.name {
+			t.Errorf("parseRegistrySource(%q) = %s, %s, want %s, %s", tt.source, url, name, tt.url, tt.name)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package providers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sourceplane/sourceplane/internal/config"
+	"github.com/sourceplane/sourceplane/internal/models"
+)
+
+// Resolver finds the providers an intent uses. It is the single place that
+// decides where a provider comes from:
+//
+//   - no source: the providers/<name> directory next to the intent or in a
+//     parent directory
+//   - a local path (./, ../, / or file://): that directory, relative to the
+//     intent
+//   - github.com/owner/repo[/path] with a version: a release archive kept
+//     in the provider cache
+//   - [host/]namespace/name: a version published in a provider registry,
+//     kept in the provider cache
+//   - any other git remote, or GitHub without a version: a clone kept up to
+//     date by the provider fetcher
+//
+// Remote providers are pinned by the intent.lock file in baseDir: their
+// locked version is used and their content must match the locked hash.
+// Resolved providers are remembered by name, so each is loaded once.
+type Resolver struct {
+	config   *config.Config
+	baseDir  string
+	cache    *ProviderCache
+	fetcher  *ProviderFetcher
+	resolved map[string]*Provider
+
+	lockMode   LockMode
+	lock       *LockFile
+	lockLoaded bool
+	locked     map[string]LockedProvider
+}
+
+// NewResolver creates a resolver for intents in baseDir
+func NewResolver(cfg *config.Config, baseDir string) *Resolver {
+	return &Resolver{
+		config:   cfg,
+		baseDir:  baseDir,
+		resolved: make(map[string]*Provider),
+		locked:   make(map[string]LockedProvider),
+	}
+}
+
+// SetLockMode sets how the resolver uses intent.lock; the default is LockVerify
+func (r *Resolver) SetLockMode(mode LockMode) {
+	r.lockMode = mode
+}
+
+// Locked returns the exact version and hash a remote provider resolved to
+func (r *Resolver) Locked(name string) (LockedProvider, bool) {
+	locked, ok := r.locked[name]
+	return locked, ok
+}
+
+// Lock returns a lock file pinning the remote providers resolved so far
+func (r *Resolver) Lock() *LockFile {
+	lock := NewLockFile()
+	for name, locked := range r.locked {
+		lock.Providers[name] = locked
+	}
+	return lock
+}
+
+// Resolve loads a provider declared in an intent's providers section
+func (r *Resolver) Resolve(name string, config models.Provider) (*Provider, error) {
+	if provider, ok := r.resolved[name]; ok {
+		return provider, nil
+	}
+
+	dir, err := r.locate(name, config)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "provider.yaml")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("provider '%s' not found (expected at %s)", name, path)
+	}
+
+	provider, err := LoadProviderFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider '%s': %w", name, err)
+	}
+	if provider.Name == "" {
+		provider.Name = name
+	}
+	provider.Source = config.Source
+	if provider.Source == "" {
+		provider.Source = dir
+	}
+
+	r.resolved[name] = provider
+	return provider, nil
+}
+
+// ForComponent resolves the provider of a component type, using the intent's
+// declaration of the provider when there is one
+func (r *Resolver) ForComponent(intent *models.Repository, componentType string) (*Provider, error) {
+	name := GetProviderNameFromType(componentType)
+	if name == "" {
+		return nil, fmt.Errorf("invalid type format '%s' (expected: provider.kind)", componentType)
+	}
+	var config models.Provider
+	if intent != nil {
+		config = intent.Providers[name]
+	}
+	return r.Resolve(name, config)
+}
+
+// ResolveAll resolves every provider the intents declare, plus local
+// providers for component types whose provider is not declared. When no
+// intent declares providers, all providers in the local providers directory
+// are loaded.
+func (r *Resolver) ResolveAll(intents []*models.Repository) (map[string]*Provider, error) {
+	declared := make(map[string]models.Provider)
+	for _, intent := range intents {
+		for name, config := range intent.Providers {
+			if _, exists := declared[name]; !exists {
+				declared[name] = config
+			}
+		}
+	}
+
+	if len(declared) == 0 {
+		return r.resolveLocal()
+	}
+
+	resolved := make(map[string]*Provider)
+	for _, name := range sortedNames(declared) {
+		provider, err := r.Resolve(name, declared[name])
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = provider
+	}
+
+	// Undeclared providers are optional here; the planner and lint report
+	// components whose provider cannot be found
+	for _, intent := range intents {
+		for _, comp := range intent.Components {
+			name := GetProviderNameFromType(comp.Type)
+			if _, ok := resolved[name]; ok || name == "" {
+				continue
+			}
+			if provider, err := r.Resolve(name, models.Provider{}); err == nil {
+				resolved[name] = provider
+			}
+		}
+	}
+
+	return resolved, nil
+}
+
+// LocalProviders returns the names of the providers in the local providers directory
+func (r *Resolver) LocalProviders() ([]string, error) {
+	providersDir := findProvidersDirectory(r.config, r.baseDir)
+	if providersDir == "" {
+		return nil, fmt.Errorf("providers directory not found")
+	}
+
+	entries, err := os.ReadDir(providersDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			// Check if this directory contains a provider.yaml
+			providerYaml := filepath.Join(providersDir, entry.Name(), "provider.yaml")
+			if _, err := os.Stat(providerYaml); err == nil {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// resolveLocal loads every provider in the local providers directory
+func (r *Resolver) resolveLocal() (map[string]*Provider, error) {
+	names, err := r.LocalProviders()
+	if err != nil {
+		return nil, fmt.Errorf("%w (searched from %s)", err, r.baseDir)
+	}
+
+	resolved := make(map[string]*Provider)
+	for _, name := range names {
+		provider, err := r.Resolve(name, models.Provider{})
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = provider
+	}
+	return resolved, nil
+}
+
+// locate returns the directory holding a provider's provider.yaml,
+// downloading the provider first when its source is remote
+func (r *Resolver) locate(name string, config models.Provider) (string, error) {
+	if config.Source == "" {
+		providersDir := findProvidersDirectory(r.config, r.baseDir)
+		if providersDir == "" {
+			return "", fmt.Errorf("provider '%s' not found locally and no remote source specified", name)
+		}
+		return filepath.Join(providersDir, name), nil
+	}
+
+	source, err := ParseProviderSource(config.Source)
+	if err != nil {
+		return "", err
+	}
+
+	if source.Type == "local" {
+		dir := source.URL
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(r.baseDir, dir)
+		}
+		return dir, nil
+	}
+
+	entry, err := r.lockedProvider(name, config)
+	if err != nil {
+		return "", err
+	}
+	version := config.Version
+	if entry != nil {
+		version = entry.Version
+	}
+
+	var dir, exact string
+	switch {
+	case (source.Type == "github" && config.Version != "") || source.Type == "registry":
+		cache, err := r.providerCache()
+		if err != nil {
+			return "", err
+		}
+		if exact, err = cache.ResolveVersion(config.Source, version); err != nil {
+			return "", err
+		}
+		if dir, err = cache.GetProviderPath(config.Source, exact); err != nil {
+			return "", err
+		}
+	case source.Type == "github" || source.Type == "git":
+		fetcher, err := r.providerFetcher()
+		if err != nil {
+			return "", err
+		}
+		ref, err := fetcher.ResolveVersion(config.Source, version)
+		if err != nil {
+			return "", err
+		}
+		if dir, err = fetcher.FetchProvider(config.Source, ref); err != nil {
+			return "", err
+		}
+		if exact, err = fetcher.Revision(dir); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported source %s for provider '%s'", config.Source, name)
+	}
+
+	hash, err := HashDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if entry != nil && entry.Hash != hash {
+		return "", fmt.Errorf("checksum mismatch for provider '%s' %s: %s has %s, downloaded content has %s (run 'sp providers upgrade' if the change is expected)",
+			name, exact, LockFileName, shortHash(entry.Hash), shortHash(hash))
+	}
+
+	r.locked[name] = LockedProvider{
+		Source:     config.Source,
+		Constraint: config.Version,
+		Version:    exact,
+		Hash:       hash,
+	}
+	return dir, nil
+}
+
+// lockedProvider returns the lock file entry to use for a remote provider,
+// or nil when the provider should be resolved from its declaration
+func (r *Resolver) lockedProvider(name string, config models.Provider) (*LockedProvider, error) {
+	if r.lockMode == LockUpgrade {
+		return nil, nil
+	}
+
+	if !r.lockLoaded {
+		lock, err := LoadLockFile(r.baseDir)
+		if err != nil {
+			return nil, err
+		}
+		r.lock = lock
+		r.lockLoaded = true
+	}
+	if r.lock == nil {
+		return nil, nil
+	}
+
+	entry, ok := r.lock.Providers[name]
+	switch {
+	case ok && entry.Matches(config.Source, config.Version):
+		return &entry, nil
+	case r.lockMode == LockUpdate:
+		return nil, nil
+	case !ok:
+		return nil, fmt.Errorf("provider '%s' is not in %s (run 'sp providers init' to lock it)", name, LockFileName)
+	default:
+		return nil, fmt.Errorf("provider '%s' was locked for %s but intent.yaml declares %s (run 'sp providers init' to update %s)",
+			name, declaration(entry.Source, entry.Constraint), declaration(config.Source, config.Version), LockFileName)
+	}
+}
+
+// declaration formats a provider source and version constraint
+func declaration(source, constraint string) string {
+	if constraint == "" {
+		return source
+	}
+	return source + " " + constraint
+}
+
+func (r *Resolver) providerCache() (*ProviderCache, error) {
+	if r.cache == nil {
+		cache, err := NewProviderCache(r.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize provider cache: %w", err)
+		}
+		r.cache = cache
+	}
+	return r.cache, nil
+}
+
+func (r *Resolver) providerFetcher() (*ProviderFetcher, error) {
+	if r.fetcher == nil {
+		fetcher, err := NewProviderFetcher(r.config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create provider fetcher: %w", err)
+		}
+		r.fetcher = fetcher
+	}
+	return r.fetcher, nil
+}
+
+// findProvidersDirectory returns the configured providers directory, or
+// searches for a providers/ directory in start and its parent directories
+func findProvidersDirectory(cfg *config.Config, start string) string {
+	if cfg.ProvidersPath != "" {
+		if info, err := os.Stat(cfg.ProvidersPath); err == nil && info.IsDir() {
+			return cfg.ProvidersPath
+		}
+		return ""
+	}
+
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		providersPath := filepath.Join(dir, "providers")
+		if info, err := os.Stat(providersPath); err == nil && info.IsDir() {
+			return providersPath
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func sortedNames(m map[string]models.Provider) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,577 @@
+// Package schema validates component specs against the JSON Schemas that
+// providers ship for their component kinds. Schemas are written in YAML and
+// follow draft-07; the keywords that matter for describing specs are
+// supported (types, required, properties, items, enum, const, patterns,
+// numeric and length bounds, oneOf/anyOf/allOf/not, if/then/else and local
+// $ref). Annotation keywords such as description, examples and default are
+// ignored, as is format.
+package schema
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a parsed JSON Schema document
+type Schema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+// ValidationError describes one way a value does not match a schema
+type ValidationError struct {
+	Path    string // dotted path to the value, e.g. "chart" or "hooks.preDeploy[0]"
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Load reads a schema from a YAML or JSON file
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a schema from YAML or JSON
+func Parse(data []byte) (*Schema, error) {
+	var root any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, fmt.Errorf("schema must be a mapping")
+	}
+	return &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}, nil
+}
+
+// Validate checks value against the schema and returns every problem found,
+// ordered by path
+func (s *Schema) Validate(value any) []ValidationError {
+	errs := s.validate(s.root, value, "")
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Path < errs[j].Path
+	})
+	return errs
+}
+
+func (s *Schema) validate(node any, value any, path string) []ValidationError {
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			return []ValidationError{{Path: path, Message: "is not allowed"}}
+		}
+		return nil
+	case map[string]any:
+		return s.validateObject(n, value, path)
+	default:
+		return nil
+	}
+}
+
+func (s *Schema) validateObject(node map[string]any, value any, path string) []ValidationError {
+	if ref, ok := node["$ref"].(string); ok {
+		target, err := s.resolveRef(ref)
+		if err != nil {
+			return []ValidationError{{Path: path, Message: err.Error()}}
+		}
+		// In draft-07 $ref replaces any sibling keywords
+		return s.validate(target, value, path)
+	}
+
+	var errs []ValidationError
+	fail := func(format string, args ...any) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if t, ok := node["type"]; ok {
+		if !matchesType(t, value) {
+			fail("must be of type %s, got %s", typeList(t), typeOf(value))
+			// Other keywords are meaningless once the type is wrong
+			return errs
+		}
+	}
+
+	if enum, ok := node["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if equal(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of: %s", formatValues(enum))
+		}
+	}
+
+	if c, ok := node["const"]; ok && !equal(c, value) {
+		fail("must be %s", formatValue(c))
+	}
+
+	switch v := value.(type) {
+	case string:
+		errs = append(errs, s.validateString(node, v, path)...)
+	case map[string]any:
+		errs = append(errs, s.validateProperties(node, v, path)...)
+	case []any:
+		errs = append(errs, s.validateItems(node, v, path)...)
+	default:
+		if f, ok := toFloat(value); ok {
+			errs = append(errs, validateNumber(node, f, path)...)
+		}
+	}
+
+	errs = append(errs, s.validateCombinators(node, value, path)...)
+	return errs
+}
+
+func (s *Schema) validateString(node map[string]any, value, path string) []ValidationError {
+	var errs []ValidationError
+	length := len([]rune(value))
+
+	if min, ok := toInt(node["minLength"]); ok && length < min {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must be at least %d characters long", min)})
+	}
+	if max, ok := toInt(node["maxLength"]); ok && length > max {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must be at most %d characters long", max)})
+	}
+	if pattern, ok := node["pattern"].(string); ok {
+		re, err := s.compile(pattern)
+		if err != nil {
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("schema has invalid pattern %q: %v", pattern, err)})
+		} else if !re.MatchString(value) {
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must match pattern %s", pattern)})
+		}
+	}
+	return errs
+}
+
+func validateNumber(node map[string]any, value float64, path string) []ValidationError {
+	var errs []ValidationError
+	fail := func(format string, args ...any) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if min, ok := toFloat(node["minimum"]); ok && value < min {
+		fail("must be >= %v", min)
+	}
+	if max, ok := toFloat(node["maximum"]); ok && value > max {
+		fail("must be <= %v", max)
+	}
+	if min, ok := toFloat(node["exclusiveMinimum"]); ok && value <= min {
+		fail("must be > %v", min)
+	}
+	if max, ok := toFloat(node["exclusiveMaximum"]); ok && value >= max {
+		fail("must be < %v", max)
+	}
+	if multiple, ok := toFloat(node["multipleOf"]); ok && multiple > 0 {
+		if q := value / multiple; q != math.Trunc(q) {
+			fail("must be a multiple of %v", multiple)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateProperties(node map[string]any, value map[string]any, path string) []ValidationError {
+	var errs []ValidationError
+
+	if required, ok := node["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, exists := value[name]; !exists {
+				errs = append(errs, ValidationError{Path: joinPath(path, name), Message: "is required"})
+			}
+		}
+	}
+
+	if min, ok := toInt(node["minProperties"]); ok && len(value) < min {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at least %d properties", min)})
+	}
+	if max, ok := toInt(node["maxProperties"]); ok && len(value) > max {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at most %d properties", max)})
+	}
+
+	properties, _ := node["properties"].(map[string]any)
+	patternProperties, _ := node["patternProperties"].(map[string]any)
+	additional, hasAdditional := node["additionalProperties"]
+
+	for _, name := range sortedKeys(value) {
+		propPath := joinPath(path, name)
+		matched := false
+
+		if propSchema, ok := properties[name]; ok {
+			matched = true
+			errs = append(errs, s.validate(propSchema, value[name], propPath)...)
+		}
+		for _, pattern := range sortedKeys(patternProperties) {
+			re, err := s.compile(pattern)
+			if err != nil || !re.MatchString(name) {
+				continue
+			}
+			matched = true
+			errs = append(errs, s.validate(patternProperties[pattern], value[name], propPath)...)
+		}
+
+		if !matched && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				errs = append(errs, ValidationError{Path: propPath, Message: "is not an allowed property"})
+			} else {
+				errs = append(errs, s.validate(additional, value[name], propPath)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func (s *Schema) validateItems(node map[string]any, value []any, path string) []ValidationError {
+	var errs []ValidationError
+
+	if min, ok := toInt(node["minItems"]); ok && len(value) < min {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at least %d items", min)})
+	}
+	if max, ok := toInt(node["maxItems"]); ok && len(value) > max {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must have at most %d items", max)})
+	}
+	if unique, _ := node["uniqueItems"].(bool); unique {
+	duplicates:
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if equal(value[i], value[j]) {
+					errs = append(errs, ValidationError{Path: path, Message: "must not contain duplicate items"})
+					break duplicates
+				}
+			}
+		}
+	}
+
+	switch items := node["items"].(type) {
+	case []any:
+		// Tuple validation: one schema per position, then additionalItems
+		for i, item := range value {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if i < len(items) {
+				errs = append(errs, s.validate(items[i], item, itemPath)...)
+			} else if additional, ok := node["additionalItems"]; ok {
+				errs = append(errs, s.validate(additional, item, itemPath)...)
+			}
+		}
+	case nil:
+	default:
+		for i, item := range value {
+			errs = append(errs, s.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	if contains, ok := node["contains"]; ok {
+		found := false
+		for _, item := range value {
+			if len(s.validate(contains, item, path)) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, ValidationError{Path: path, Message: "must contain at least one matching item"})
+		}
+	}
+
+	return errs
+}
+
+func (s *Schema) validateCombinators(node map[string]any, value any, path string) []ValidationError {
+	var errs []ValidationError
+
+	if allOf, ok := node["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			errs = append(errs, s.validate(sub, value, path)...)
+		}
+	}
+
+	if anyOf, ok := node["anyOf"].([]any); ok {
+		matched := 0
+		for _, sub := range anyOf {
+			if len(s.validate(sub, value, path)) == 0 {
+				matched++
+				break
+			}
+		}
+		if matched == 0 {
+			if alternatives := requiredAlternatives(anyOf); alternatives != "" {
+				errs = append(errs, ValidationError{Path: path, Message: "must have " + alternatives})
+			} else {
+				errs = append(errs, ValidationError{Path: path, Message: "must match at least one of the allowed schemas"})
+			}
+		}
+	}
+
+	if oneOf, ok := node["oneOf"].([]any); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if len(s.validate(sub, value, path)) == 0 {
+				matched++
+			}
+		}
+		alternatives := requiredAlternatives(oneOf)
+		switch {
+		case matched == 0 && alternatives != "":
+			errs = append(errs, ValidationError{Path: path, Message: "must have either " + alternatives})
+		case matched == 0:
+			// Report the problems of the closest alternative
+			errs = append(errs, s.closest(oneOf, value, path)...)
+		case matched > 1 && alternatives != "":
+			errs = append(errs, ValidationError{Path: path, Message: "must have only one of " + alternatives})
+		case matched > 1:
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("must match exactly one of the allowed schemas, matched %d", matched)})
+		}
+	}
+
+	if not, ok := node["not"]; ok {
+		if len(s.validate(not, value, path)) == 0 {
+			errs = append(errs, ValidationError{Path: path, Message: "must not match the disallowed schema"})
+		}
+	}
+
+	if cond, ok := node["if"]; ok {
+		if len(s.validate(cond, value, path)) == 0 {
+			if then, ok := node["then"]; ok {
+				errs = append(errs, s.validate(then, value, path)...)
+			}
+		} else if els, ok := node["else"]; ok {
+			errs = append(errs, s.validate(els, value, path)...)
+		}
+	}
+
+	return errs
+}
+
+// closest returns the errors of the alternative with the fewest problems
+func (s *Schema) closest(alternatives []any, value any, path string) []ValidationError {
+	var best []ValidationError
+	for i, sub := range alternatives {
+		errs := s.validate(sub, value, path)
+		if i == 0 || len(errs) < len(best) {
+			best = errs
+		}
+	}
+	return best
+}
+
+// requiredAlternatives describes oneOf/anyOf branches that differ only in
+// their required properties as "repo+name or path". It returns "" when any
+// branch has no required properties.
+func requiredAlternatives(alternatives []any) string {
+	parts := make([]string, 0, len(alternatives))
+	for _, sub := range alternatives {
+		m, ok := sub.(map[string]any)
+		if !ok {
+			return ""
+		}
+		required, ok := m["required"].([]any)
+		if !ok || len(required) == 0 {
+			return ""
+		}
+		names := make([]string, 0, len(required))
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				names = append(names, name)
+			}
+		}
+		parts = append(parts, strings.Join(names, "+"))
+	}
+	return strings.Join(parts, " or ")
+}
+
+// resolveRef resolves a local reference such as "#/definitions/chart"
+func (s *Schema) resolveRef(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported schema reference %q: only local references are supported", ref)
+	}
+
+	current := s.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return current, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+		}
+		current, ok = m[token]
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+		}
+	}
+	return current, nil
+}
+
+func (s *Schema) compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.patterns[pattern] = re
+	return re, nil
+}
+
+// matchesType reports whether value has the JSON type, or one of the types, in t
+func matchesType(t any, value any) bool {
+	switch v := t.(type) {
+	case string:
+		return isType(v, value)
+	case []any:
+		for _, item := range v {
+			if name, ok := item.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, value any) bool {
+	switch name {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	}
+	return false
+}
+
+// typeOf returns the JSON type name of a decoded value
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		if f, ok := toFloat(v); ok {
+			if f == math.Trunc(f) {
+				return "integer"
+			}
+			return "number"
+		}
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func typeList(t any) string {
+	if list, ok := t.([]any); ok {
+		names := make([]string, 0, len(list))
+		for _, item := range list {
+			names = append(names, fmt.Sprint(item))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// equal compares decoded values, treating numbers of different Go types alike
+func equal(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(val any) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func toInt(val any) (int, bool) {
+	f, ok := toFloat(val)
+	return int(f), ok
+}
+
+func formatValue(val any) string {
+	if s, ok := val.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(val)
+}
+
+func formatValues(vals []any) string {
+	parts := make([]string, len(vals))
+	for i, val := range vals {
+		parts[i] = formatValue(val)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
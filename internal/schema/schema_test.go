@@ -0,0 +1,306 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validate parses a YAML schema and value and returns the validation errors
+// formatted as path: message
+func validate(t *testing.T, schemaYAML, valueYAML string) []string {
+	t.Helper()
+	s, err := Parse([]byte(schemaYAML))
+	if err != nil {
+		t.Fatalf("Parse schema: %v", err)
+	}
+	var value any
+	if err := yaml.Unmarshal([]byte(valueYAML), &value); err != nil {
+		t.Fatalf("Unmarshal value: %v", err)
+	}
+	var got []string
+	for _, e := range s.Validate(value) {
+		got = append(got, e.Error())
+	}
+	return got
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string
+	}{
+		// type
+		{name: "type string", schema: "type: string", value: `"x"`},
+		{name: "type mismatch", schema: "type: string", value: "1", want: []string{"must be of type string, got integer"}},
+		{name: "type integer rejects fraction", schema: "type: integer", value: "1.5", want: []string{"must be of type integer, got number"}},
+		{name: "type number accepts integer", schema: "type: number", value: "2"},
+		{name: "type list", schema: "type: [string, 'null']", value: "~"},
+		{name: "type list mismatch", schema: "type: [string, 'null']", value: "true", want: []string{"must be of type string or null, got boolean"}},
+		{name: "type object", schema: "type: object", value: "[]", want: []string{"must be of type object, got array"}},
+		{name: "type mismatch stops other keywords", schema: "{type: string, enum: [a]}", value: "1", want: []string{"must be of type string, got integer"}},
+
+		// enum and const
+		{name: "enum", schema: "enum: [a, b, 1]", value: "b"},
+		{name: "enum number", schema: "enum: [a, b, 1]", value: "1.0"},
+		{name: "enum mismatch", schema: "enum: [a, b, 1]", value: "c", want: []string{`must be one of: "a", "b", 1`}},
+		{name: "const mismatch", schema: "const: prod", value: "dev", want: []string{`must be "prod"`}},
+
+		// required and properties
+		{
+			name:   "required",
+			schema: "{type: object, required: [chart, namespace]}",
+			value:  "{chart: api}",
+			want:   []string{"namespace: is required"},
+		},
+		{
+			name:   "nested required",
+			schema: "{properties: {values: {required: [image]}}}",
+			value:  "{values: {}}",
+			want:   []string{"values.image: is required"},
+		},
+		{
+			name:   "property type",
+			schema: "{properties: {replicas: {type: integer}}}",
+			value:  "{replicas: two}",
+			want:   []string{"replicas: must be of type integer, got string"},
+		},
+		{
+			name:   "property bounds",
+			schema: "{minProperties: 2, maxProperties: 1}",
+			value:  "{a: 1}",
+			want:   []string{"must have at least 2 properties"},
+		},
+
+		// additionalProperties
+		{
+			name:   "additionalProperties false",
+			schema: "{properties: {chart: {}}, additionalProperties: false}",
+			value:  "{chart: api, chrat: api}",
+			want:   []string{"chrat: is not an allowed property"},
+		},
+		{
+			name:   "additionalProperties schema",
+			schema: "{properties: {chart: {}}, additionalProperties: {type: string}}",
+			value:  "{chart: 1, labels: 2}",
+			want:   []string{"labels: must be of type string, got integer"},
+		},
+		{
+			name:   "patternProperties are not additional",
+			schema: "{patternProperties: {'^x-': {type: string}}, additionalProperties: false}",
+			value:  "{x-team: 1, other: a}",
+			want:   []string{"other: is not an allowed property", "x-team: must be of type string, got integer"},
+		},
+
+		// strings and numbers
+		{name: "minLength counts runes", schema: "minLength: 2", value: `"é"`, want: []string{"must be at least 2 characters long"}},
+		{name: "maxLength", schema: "maxLength: 2", value: "abc", want: []string{"must be at most 2 characters long"}},
+		{name: "pattern", schema: "pattern: '^[a-z]+$'", value: "Api", want: []string{"must match pattern ^[a-z]+$"}},
+		{name: "invalid pattern", schema: "pattern: '('", value: "a", want: []string{`schema has invalid pattern "(": error parsing regexp: missing closing ): ` + "`(`"}},
+		{name: "minimum", schema: "minimum: 1", value: "0", want: []string{"must be >= 1"}},
+		{name: "exclusiveMaximum", schema: "exclusiveMaximum: 10", value: "10", want: []string{"must be < 10"}},
+		{name: "multipleOf", schema: "multipleOf: 0.5", value: "1.25", want: []string{"must be a multiple of 0.5"}},
+
+		// arrays
+		{
+			name:   "items",
+			schema: "{items: {type: string}}",
+			value:  "[a, 1, b, 2]",
+			want:   []string{"[1]: must be of type string, got integer", "[3]: must be of type string, got integer"},
+		},
+		{
+			name:   "tuple items",
+			schema: "{items: [{type: string}], additionalItems: false}",
+			value:  "[a, b]",
+			want:   []string{"[1]: is not allowed"},
+		},
+		{name: "uniqueItems", schema: "uniqueItems: true", value: "[1, 2, 1.0]", want: []string{"must not contain duplicate items"}},
+		{name: "contains", schema: "contains: {const: main}", value: "[dev]", want: []string{"must contain at least one matching item"}},
+
+		// combinators
+		{
+			name:   "oneOf required alternatives",
+			schema: "oneOf: [{required: [repo, name]}, {required: [path]}]",
+			value:  "{}",
+			want:   []string{"must have either repo+name or path"},
+		},
+		{
+			name:   "oneOf more than one",
+			schema: "oneOf: [{required: [repo, name]}, {required: [path]}]",
+			value:  "{repo: r, name: n, path: p}",
+			want:   []string{"must have only one of repo+name or path"},
+		},
+		{
+			name:   "oneOf reports the closest alternative",
+			schema: "oneOf: [{required: [x, y]}, {properties: {a: {type: string}}}]",
+			value:  "{a: 1}",
+			want:   []string{"a: must be of type string, got integer"},
+		},
+		{name: "anyOf", schema: "anyOf: [{type: string}, {type: integer}]", value: "true", want: []string{"must match at least one of the allowed schemas"}},
+		{name: "not", schema: "not: {const: latest}", value: "latest", want: []string{"must not match the disallowed schema"}},
+		{
+			name:   "if then",
+			schema: "{if: {properties: {kind: {const: oci}}}, then: {required: [registry]}, else: {required: [repo]}}",
+			value:  "{kind: oci}",
+			want:   []string{"registry: is required"},
+		},
+		{
+			name:   "if else",
+			schema: "{if: {properties: {kind: {const: oci}}}, then: {required: [registry]}, else: {required: [repo]}}",
+			value:  "{kind: git}",
+			want:   []string{"repo: is required"},
+		},
+		{name: "false schema", schema: "false", value: "1", want: []string{"is not allowed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validate(t, tt.schema, tt.value)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRef(t *testing.T) {
+	const schema = `
+definitions:
+  port:
+    type: integer
+    maximum: 65535
+  a~b/c:
+    type: string
+type: object
+properties:
+  port:
+    $ref: "#/definitions/port"
+  name:
+    $ref: "#/definitions/a~0b~1c"
+  ports:
+    type: array
+    items:
+      $ref: "#/definitions/port"
+  self:
+    $ref: "#"
+  missing:
+    $ref: "#/definitions/missing"
+  remote:
+    $ref: "other.yaml#/definitions/port"
+  sibling:
+    $ref: "#/definitions/port"
+    type: string
+`
+
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "valid", value: "{port: 80, name: api, ports: [80, 443], sibling: 1}"},
+		{name: "referenced schema", value: "{port: 70000}", want: []string{"port: must be <= 65535"}},
+		{name: "escaped pointer", value: "{name: 1}", want: []string{"name: must be of type string, got integer"}},
+		{name: "items", value: "{ports: [80, http]}", want: []string{"ports[1]: must be of type integer, got string"}},
+		{name: "root reference", value: "{self: {port: x}}", want: []string{"self.port: must be of type integer, got string"}},
+		{name: "unresolvable", value: "{missing: 1}", want: []string{`missing: unresolvable schema reference "#/definitions/missing"`}},
+		{name: "remote", value: "{remote: 1}", want: []string{`remote: unsupported schema reference "other.yaml#/definitions/port": only local references are supported`}},
+		// In draft-07 $ref replaces its sibling keywords
+		{name: "siblings ignored", value: "{sibling: x}", want: []string{"sibling: must be of type integer, got string"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validate(t, schema, tt.value)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateErrorPaths checks that errors carry the path of the value, in
+// the form the intent document resolves to a line and column
+func TestValidateErrorPaths(t *testing.T) {
+	const schema = `
+type: object
+required: [chart]
+properties:
+  chart:
+    type: string
+  hooks:
+    type: object
+    properties:
+      preDeploy:
+        type: array
+        items:
+          type: object
+          required: [run]
+          properties:
+            run:
+              type: string
+`
+	const value = `
+hooks:
+  preDeploy:
+    - run: migrate
+    - run: 42
+    - name: seed
+`
+
+	s, err := Parse([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v any
+	if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := s.Validate(v)
+	want := []ValidationError{
+		{Path: "chart", Message: "is required"},
+		{Path: "hooks.preDeploy[1].run", Message: "must be of type string, got integer"},
+		{Path: "hooks.preDeploy[2].run", Message: "is required"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Validate() = %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("error %d = %+v, want %+v", i, errs[i], want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range []string{"- a\n- b\n", "just a string", "{a: [}"} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "object", "required": ["chart"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.Validate(map[string]any{}); len(errs) != 1 || errs[0].Error() != "chart: is required" {
+		t.Errorf("Validate() = %v, want chart: is required", errs)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil || !strings.Contains(err.Error(), "failed to read schema") {
+		t.Errorf("Load(missing) error = %v, want a read error", err)
+	}
+}
@@ -0,0 +1,428 @@
+// Package semver parses semantic versions and resolves provider version
+// constraints against the versions a source offers. Constraints combine
+// comparisons with `,` (and) and `||` (or):
+//
+//	1.2.3          exactly 1.2.3; a partial version such as 1.2 matches 1.2.x
+//	>=1.2, <2.0    comparisons: =, !=, >, >=, <, <=
+//	~> 1.2         pessimistic: >=1.2.0, <2.0.0 (~> 1.2.3 is >=1.2.3, <1.3.0)
+//	^1.2.3         compatible: >=1.2.3, <2.0.0 (^0.2.3 is >=0.2.3, <0.3.0)
+//	~1.2.3         patch updates: >=1.2.3, <1.3.0
+//	1.2 - 1.4      inclusive range: >=1.2.0, <1.5.0
+//	1.x, *         wildcards
+//
+// Pre-releases only match a constraint that names a pre-release of the same
+// major.minor.patch, so `>=1.0` never selects 2.0.0-rc.1.
+package semver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+	Metadata   string
+}
+
+// Parse parses a version such as 1.2.3, v1.2.3 or 1.2.3-rc.1+build.5.
+// Missing minor and patch numbers are zero.
+func Parse(s string) (*Version, error) {
+	v, parts, wildcard, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+	if wildcard || parts == 0 {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// String formats the version without a v prefix
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Metadata != "" {
+		s += "+" + v.Metadata
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than o.
+// Build metadata is ignored.
+func (v *Version) Compare(o *Version) int {
+	for _, d := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// comparePrerelease orders pre-release identifiers; a release is higher than
+// any of its pre-releases
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil:
+			return -1 // numeric identifiers sort before alphanumeric ones
+		case bErr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// parsePartial parses a possibly partial version, returning how many of
+// major, minor and patch were given and whether the version ends in a
+// wildcard (x, X or *)
+func parsePartial(s string) (*Version, int, bool, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if s == "" {
+		return nil, 0, false, fmt.Errorf("invalid version %q", raw)
+	}
+
+	v := &Version{}
+	if core, metadata, found := strings.Cut(s, "+"); found {
+		s, v.Metadata = core, metadata
+	}
+	if core, prerelease, found := strings.Cut(s, "-"); found {
+		if prerelease == "" {
+			return nil, 0, false, fmt.Errorf("invalid version %q", raw)
+		}
+		s, v.Prerelease = core, prerelease
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return nil, 0, false, fmt.Errorf("invalid version %q", raw)
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	parts := 0
+	for i, field := range fields {
+		if field == "x" || field == "X" || field == "*" {
+			if i != len(fields)-1 || v.Prerelease != "" {
+				return nil, 0, false, fmt.Errorf("invalid version %q", raw)
+			}
+			return v, parts, true, nil
+		}
+		n, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("invalid version %q", raw)
+		}
+		*numbers[i] = n
+		parts++
+	}
+	if v.Prerelease != "" && parts < 3 {
+		return nil, 0, false, fmt.Errorf("invalid version %q: pre-releases need major.minor.patch", raw)
+	}
+	return v, parts, false, nil
+}
+
+// comparison is a single bound such as >=1.2.0
+type comparison struct {
+	op      string
+	version *Version
+}
+
+func (c comparison) check(v *Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// Constraints is a parsed version constraint
+type Constraints struct {
+	raw string
+	// alternatives are or-ed; the comparisons within one are and-ed
+	alternatives [][]comparison
+}
+
+// ParseConstraints parses a version constraint. An empty constraint or *
+// matches any release.
+func ParseConstraints(s string) (*Constraints, error) {
+	c := &Constraints{raw: strings.TrimSpace(s)}
+	for _, alternative := range strings.Split(s, "||") {
+		var set []comparison
+		for _, part := range strings.Split(alternative, ",") {
+			comparisons, err := parsePart(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", c.raw, err)
+			}
+			set = append(set, comparisons...)
+		}
+		c.alternatives = append(c.alternatives, set)
+	}
+	return c, nil
+}
+
+// parsePart parses one comma-separated part: a hyphen range or one or more
+// whitespace-separated comparisons
+func parsePart(part string) ([]comparison, error) {
+	if part == "" {
+		return nil, nil
+	}
+
+	if lower, upper, found := strings.Cut(part, " - "); found {
+		from, err := parseComparison(">=", strings.TrimSpace(lower))
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseComparison("<=", strings.TrimSpace(upper))
+		if err != nil {
+			return nil, err
+		}
+		return append(from, to...), nil
+	}
+
+	var comparisons []comparison
+	fields := strings.Fields(part)
+	for i := 0; i < len(fields); i++ {
+		op, version := splitOperator(fields[i])
+		if version == "" && i+1 < len(fields) {
+			// Operator separated from its version, e.g. ">= 1.2"
+			i++
+			version = fields[i]
+		}
+		parsed, err := parseComparison(op, version)
+		if err != nil {
+			return nil, err
+		}
+		comparisons = append(comparisons, parsed...)
+	}
+	return comparisons, nil
+}
+
+var operators = []string{"~>", ">=", "<=", "!=", "==", ">", "<", "=", "^", "~"}
+
+func splitOperator(s string) (string, string) {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op, strings.TrimSpace(strings.TrimPrefix(s, op))
+		}
+	}
+	return "", s
+}
+
+// parseComparison expands an operator and a possibly partial version into
+// bounds
+func parseComparison(op, version string) ([]comparison, error) {
+	if version == "" {
+		return nil, fmt.Errorf("operator %s has no version", op)
+	}
+	if version == "*" || version == "x" || version == "X" {
+		if op == "" || op == "=" || op == "==" || op == ">=" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("operator %s cannot be used with %s", op, version)
+	}
+
+	v, parts, _, err := parsePartial(version)
+	if err != nil {
+		return nil, err
+	}
+	if parts == 0 {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	// next is the first version after everything the partial version covers,
+	// e.g. 1.3.0 for 1.2
+	next := bump(v, parts)
+
+	switch op {
+	case "", "=", "==":
+		if parts == 3 {
+			return []comparison{{"=", v}}, nil
+		}
+		return []comparison{{">=", v}, {"<", next}}, nil
+	case "!=":
+		return []comparison{{"!=", v}}, nil
+	case ">":
+		if parts == 3 {
+			return []comparison{{">", v}}, nil
+		}
+		return []comparison{{">=", next}}, nil
+	case ">=":
+		return []comparison{{">=", v}}, nil
+	case "<":
+		return []comparison{{"<", v}}, nil
+	case "<=":
+		if parts == 3 {
+			return []comparison{{"<=", v}}, nil
+		}
+		return []comparison{{"<", next}}, nil
+	case "~>":
+		// The last given number may increase
+		if parts == 1 {
+			return []comparison{{">=", v}, {"<", bump(v, 1)}}, nil
+		}
+		return []comparison{{">=", v}, {"<", bump(v, parts-1)}}, nil
+	case "~":
+		if parts == 1 {
+			return []comparison{{">=", v}, {"<", bump(v, 1)}}, nil
+		}
+		return []comparison{{">=", v}, {"<", bump(v, 2)}}, nil
+	case "^":
+		// The left-most non-zero number may not change
+		switch {
+		case v.Major > 0 || parts == 1:
+			return []comparison{{">=", v}, {"<", bump(v, 1)}}, nil
+		case v.Minor > 0 || parts == 2:
+			return []comparison{{">=", v}, {"<", bump(v, 2)}}, nil
+		default:
+			return []comparison{{">=", v}, {"<", bump(v, 3)}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// bump increments the number at position parts (1 major, 2 minor, 3 patch)
+// and zeroes the ones after it
+func bump(v *Version, parts int) *Version {
+	switch parts {
+	case 1:
+		return &Version{Major: v.Major + 1}
+	case 2:
+		return &Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
+
+// Check reports whether v satisfies the constraint
+func (c *Constraints) Check(v *Version) bool {
+	for _, set := range c.alternatives {
+		if checkSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkSet(set []comparison, v *Version) bool {
+	for _, comparison := range set {
+		if !comparison.check(v) {
+			return false
+		}
+	}
+	if v.Prerelease == "" {
+		return true
+	}
+	// Pre-releases are only selected when asked for explicitly
+	for _, comparison := range set {
+		b := comparison.version
+		if b.Prerelease != "" && b.Major == v.Major && b.Minor == v.Minor && b.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// Exact returns the version when the constraint pins exactly one version
+func (c *Constraints) Exact() (*Version, bool) {
+	if len(c.alternatives) != 1 || len(c.alternatives[0]) != 1 || c.alternatives[0][0].op != "=" {
+		return nil, false
+	}
+	return c.alternatives[0][0].version, true
+}
+
+// String returns the constraint as written
+func (c *Constraints) String() string {
+	return c.raw
+}
+
+// Highest returns the highest of candidates that satisfies the constraint,
+// as written in candidates (e.g. the tag v1.2.3). Candidates that are not
+// versions are skipped.
+func Highest(c *Constraints, candidates []string) (string, bool) {
+	var best *Version
+	bestName := ""
+	for _, candidate := range candidates {
+		v, err := Parse(candidate)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best, bestName = v, candidate
+		}
+	}
+	return bestName, best != nil
+}
+
+// Sort orders versions from lowest to highest; strings that are not
+// versions are dropped
+func Sort(candidates []string) []string {
+	type parsed struct {
+		name    string
+		version *Version
+	}
+	var versions []parsed
+	for _, candidate := range candidates {
+		if v, err := Parse(candidate); err == nil {
+			versions = append(versions, parsed{candidate, v})
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].version.Compare(versions[j].version) < 0
+	})
+
+	sorted := make([]string, len(versions))
+	for i, v := range versions {
+		sorted[i] = v.name
+	}
+	return sorted
+}
@@ -0,0 +1,203 @@
+package semver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "1.2.3", want: "1.2.3"},
+		{in: "v1.2.3", want: "1.2.3"},
+		{in: "1.2", want: "1.2.0"},
+		{in: "1", want: "1.0.0"},
+		{in: "1.2.3-rc.1+build.5", want: "1.2.3-rc.1+build.5"},
+		{in: "1.x", wantErr: true},
+		{in: "*", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "main", wantErr: true},
+		{in: "1.2.3.4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			v, err := Parse(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %s, want an error", tt.in, v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.String() != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, v, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+	}
+
+	for _, tt := range tests {
+		a, err := Parse(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Parse(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.Compare(a); got != -tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestConstraintsCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-rc.1"}},
+		{"*", []string{"0.0.1", "9.9.9"}, nil},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4", "1.2.2"}},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{"1.x", []string{"1.0.0", "1.9.0"}, []string{"2.0.0", "0.9.0"}},
+		{"!=1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{">1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{">=1.2, <2.0", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0"}},
+		{">= 1.2 < 2.0", []string{"1.5.0"}, []string{"2.0.0"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"~> 1.2", []string{"1.2.0", "1.9.0"}, []string{"2.0.0", "1.1.0"}},
+		{"~> 1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~> 1", []string{"1.9.0"}, []string{"2.0.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"2.0.0", "1.2.2"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.2.3", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"1.2 - 1.4", []string{"1.2.0", "1.4.9"}, []string{"1.5.0", "1.1.9"}},
+		{"1.2.3 - 1.4.0", []string{"1.4.0"}, []string{"1.4.1"}},
+		{"<1.0 || >=2.0", []string{"0.9.0", "2.1.0"}, []string{"1.5.0"}},
+		// Pre-releases only match a constraint naming one of the same version
+		{">=1.0", []string{"1.0.0"}, []string{"2.0.0-rc.1"}},
+		{">=2.0.0-rc.1", []string{"2.0.0-rc.1", "2.0.0-rc.2", "2.0.0"}, []string{"2.1.0-rc.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraints(tt.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.match {
+				if v, _ := Parse(s); !c.Check(v) {
+					t.Errorf("%q does not match %s", tt.constraint, s)
+				}
+			}
+			for _, s := range tt.noMatch {
+				if v, _ := Parse(s); c.Check(v) {
+					t.Errorf("%q matches %s", tt.constraint, s)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConstraintsErrors(t *testing.T) {
+	for _, constraint := range []string{">=", "main", ">1.x.y", "<*", "1.2.3.4", "=> 1.0"} {
+		if _, err := ParseConstraints(constraint); err == nil {
+			t.Errorf("ParseConstraints(%q) succeeded, want an error", constraint)
+		}
+	}
+}
+
+func TestExact(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"=1.2.3", "1.2.3"},
+		{"1.2", ""},
+		{">=1.2.3", ""},
+		{"1.2.3 || 1.2.4", ""},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraints(tt.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if v, ok := c.Exact(); ok {
+			got = v.String()
+		}
+		if got != tt.want {
+			t.Errorf("Exact(%q) = %q, want %q", tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestHighest(t *testing.T) {
+	candidates := []string{"v0.9.0", "v1.0.0", "v1.2.0", "latest", "v1.10.0", "v2.0.0-rc.1", "1.5.0"}
+
+	tests := []struct {
+		constraint string
+		want       string
+		ok         bool
+	}{
+		{">=1.0, <2.0", "v1.10.0", true},
+		{"~> 1.2.0", "v1.2.0", true},
+		{"^0.9", "v0.9.0", true},
+		{"1.5", "1.5.0", true},
+		{"", "v1.10.0", true},
+		{"2.0.0-rc.1", "v2.0.0-rc.1", true},
+		{">=3", "", false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraints(tt.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := Highest(c, candidates)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Highest(%q) = %q, %v, want %q, %v", tt.constraint, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	got := Sort([]string{"v1.10.0", "main", "v1.2.0", "1.2.0-rc.1", "v0.1.0"})
+	want := []string{"v0.1.0", "1.2.0-rc.1", "v1.2.0", "v1.10.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sort() = %v, want %v", got, want)
+	}
+}
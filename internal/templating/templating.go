@@ -0,0 +1,287 @@
+// Package templating renders the Go templates found in provider job
+// definitions. It is shared by the thin-ci planner, which resolves templates
+// while building a plan, and the executor, which resolves whatever is left
+// when a job runs.
+package templating
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// noValue is what text/template prints for missing keys outside strict mode
+const noValue = "<no value>"
+
+// Engine renders templates with a shared set of helper functions
+type Engine struct {
+	strict bool
+	funcs  template.FuncMap
+}
+
+// New creates an engine. In strict mode referencing a missing key is an
+// error; otherwise it renders as an empty string.
+func New(strict bool) *Engine {
+	return &Engine{strict: strict, funcs: Funcs()}
+}
+
+// Strict reports whether the engine errors on missing keys
+func (e *Engine) Strict() bool {
+	return e.strict
+}
+
+// WithFuncs returns a copy of the engine with additional template functions
+func (e *Engine) WithFuncs(funcs template.FuncMap) *Engine {
+	merged := make(template.FuncMap, len(e.funcs)+len(funcs))
+	for name, fn := range e.funcs {
+		merged[name] = fn
+	}
+	for name, fn := range funcs {
+		merged[name] = fn
+	}
+	return &Engine{strict: e.strict, funcs: merged}
+}
+
+// IsTemplate reports whether s contains template actions
+func IsTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// Render executes text as a template named name against data
+func (e *Engine) Render(name, text string, data map[string]any) (string, error) {
+	if !IsTemplate(text) {
+		return text, nil
+	}
+
+	missingKey := "missingkey=default"
+	if e.strict {
+		missingKey = "missingkey=error"
+	}
+
+	tmpl, err := template.New(name).Option(missingKey).Funcs(e.funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	if e.strict {
+		return buf.String(), nil
+	}
+	return strings.ReplaceAll(buf.String(), noValue, ""), nil
+}
+
+// RenderValue renders every string in val, returning a copy. field names the
+// value in errors, e.g. "metadata.environment.url".
+func (e *Engine) RenderValue(field string, val any, data map[string]any) (any, error) {
+	return Walk(field, val, func(path, s string) (string, error) {
+		return e.Render(path, s, data)
+	})
+}
+
+// Walk returns a deep copy of val with fn applied to every string in it.
+// Maps and slices decoded from JSON or YAML are copied, so templates shared
+// between jobs are never modified. Map keys are visited in sorted order, so
+// the same error is reported on every run. Errors are wrapped in a
+// *FieldError naming the path of the failing string.
+func Walk(path string, val any, fn func(path, s string) (string, error)) (any, error) {
+	switch v := val.(type) {
+	case string:
+		out, err := fn(path, v)
+		if err != nil {
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) {
+				return nil, err
+			}
+			return nil, &FieldError{Field: path, Err: err}
+		}
+		return out, nil
+	case []string:
+		out := make([]string, len(v))
+		for i, item := range v {
+			rendered, err := Walk(fmt.Sprintf("%s[%d]", path, i), item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered.(string)
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			rendered, err := Walk(fmt.Sprintf("%s[%d]", path, i), item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for _, k := range sortedKeys(v) {
+			rendered, err := Walk(joinPath(path, k), v[k], fn)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case map[string]string:
+		out := make(map[string]string, len(v))
+		for _, k := range sortedKeys(v) {
+			rendered, err := Walk(joinPath(path, k), v[k], fn)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered.(string)
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FieldError reports a template that failed to render, naming its location
+type FieldError struct {
+	Job   string // set by callers that render job fields
+	Field string
+	Err   error
+}
+
+func (fe *FieldError) Error() string {
+	message := cleanTemplateError(fe.Err.Error())
+	if fe.Job != "" {
+		return fmt.Sprintf("job %s: field %s: %s", fe.Job, fe.Field, message)
+	}
+	return fmt.Sprintf("field %s: %s", fe.Field, message)
+}
+
+func (fe *FieldError) Unwrap() error {
+	return fe.Err
+}
+
+var (
+	execErrorPattern  = regexp.MustCompile(`^template: .*?:(\d+):(\d+): executing ".*?" at (<.*?>): `)
+	parseErrorPattern = regexp.MustCompile(`^template: .*?:(\d+): `)
+)
+
+// cleanTemplateError drops the template name text/template puts in front of
+// its messages, which repeats the field name
+func cleanTemplateError(message string) string {
+	if m := execErrorPattern.FindStringSubmatch(message); m != nil {
+		return fmt.Sprintf("%s (line %s, col %s): %s", m[3], m[1], m[2], message[len(m[0]):])
+	}
+	if m := parseErrorPattern.FindStringSubmatch(message); m != nil {
+		return fmt.Sprintf("line %s: %s", m[1], message[len(m[0]):])
+	}
+	return message
+}
+
+// Funcs returns the helper functions available to every template
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"default":  defaultValue,
+		"required": required,
+		"toJson":   toJSON,
+		"quote":    quote,
+		"lower":    lower,
+	}
+}
+
+// defaultValue returns def when the piped value is missing or empty:
+//
+//	{{ .namespace | default "default" }}
+func defaultValue(def any, given ...any) any {
+	if len(given) == 0 || isEmpty(given[0]) {
+		return def
+	}
+	return given[0]
+}
+
+// required fails rendering with message when val is missing or empty:
+//
+//	{{ required "chartPath is required" .chartPath }}
+func required(message string, val any) (any, error) {
+	if val == nil {
+		return nil, errors.New(message)
+	}
+	if s, ok := val.(string); ok && s == "" {
+		return nil, errors.New(message)
+	}
+	return val, nil
+}
+
+// toJSON encodes val as compact JSON
+func toJSON(val any) (string, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// quote wraps each value in double quotes, skipping nils
+func quote(vals ...any) string {
+	quoted := make([]string, 0, len(vals))
+	for _, val := range vals {
+		if val == nil {
+			continue
+		}
+		quoted = append(quoted, fmt.Sprintf("%q", toString(val)))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// lower converts a value to lower case
+func lower(val any) string {
+	return strings.ToLower(toString(val))
+}
+
+func toString(val any) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprint(val)
+}
+
+// isEmpty reports whether a value is nil or the zero value of its type
+func isEmpty(val any) bool {
+	if val == nil {
+		return true
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
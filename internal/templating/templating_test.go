@@ -0,0 +1,242 @@
+package templating
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+var testData = map[string]any{
+	"component": "api",
+	"namespace": "",
+	"replicas":  3,
+	"values": map[string]any{
+		"image": map[string]any{"tag": "v1"},
+	},
+	"labels": []any{"a", "b"},
+	"name":   "Web API",
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		strict  bool
+		want    string
+		wantErr string
+	}{
+		{name: "plain text", text: "helm lint", want: "helm lint"},
+		{name: "plain text is not parsed", text: "echo {not a template}", strict: true, want: "echo {not a template}"},
+		{name: "key", text: "helm upgrade {{.component}}", want: "helm upgrade api"},
+		{name: "nested key", text: "--set image.tag={{.values.image.tag}}", want: "--set image.tag=v1"},
+		{name: "number", text: "--replicas {{.replicas}}", want: "--replicas 3"},
+
+		// Missing keys
+		{name: "missing key is empty", text: "helm upgrade {{.component}} {{.chartPath}}", want: "helm upgrade api "},
+		{name: "missing nested key is empty", text: "tag={{.values.image.digest}}", want: "tag="},
+		{
+			name:    "missing key in strict mode",
+			text:    "helm upgrade {{.component}} {{.chartPath}}",
+			strict:  true,
+			wantErr: `map has no entry for key "chartPath"`,
+		},
+		{name: "present key in strict mode", text: "{{.values.image.tag}}", strict: true, want: "v1"},
+
+		// Errors
+		{name: "parse error", text: "{{.component", wantErr: "unclosed action"},
+		{name: "unknown function", text: "{{ upper .component }}", wantErr: `function "upper" not defined`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.strict).Render("field", tt.text, testData)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render(%q) error = %v, want it to contain %q", tt.text, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    string
+		wantErr string
+	}{
+		// default
+		{text: `{{ .namespace | default "default" }}`, want: "default"},
+		{text: `{{ .missing | default "default" }}`, want: "default"},
+		{text: `{{ .component | default "default" }}`, want: "api"},
+		{text: `{{ .replicas | default 1 }}`, want: "3"},
+		{text: `{{ default "x" }}`, want: "x"},
+		{text: `{{ .labels | default "none" }}`, want: "[a b]"},
+
+		// required
+		{text: `{{ required "component is required" .component }}`, want: "api"},
+		{text: `{{ required "chartPath is required" .chartPath }}`, wantErr: "chartPath is required"},
+		{text: `{{ required "namespace is required" .namespace }}`, wantErr: "namespace is required"},
+
+		// toJson
+		{text: `{{ toJson .values }}`, want: `{"image":{"tag":"v1"}}`},
+		{text: `{{ toJson .labels }}`, want: `["a","b"]`},
+		{text: `{{ toJson .name }}`, want: `"Web API"`},
+
+		// quote
+		{text: `{{ quote .name }}`, want: `"Web API"`},
+		{text: `{{ quote .component .replicas }}`, want: `"api" "3"`},
+		{text: `{{ quote .missing }}`, want: ""},
+		{text: `{{ quote "say \"hi\"" }}`, want: `"say \"hi\""`},
+
+		// lower
+		{text: `{{ .name | lower }}`, want: "web api"},
+		{text: `{{ lower .replicas }}`, want: "3"},
+	}
+
+	engine := New(false)
+	for _, tt := range tests {
+		got, err := engine.Render("field", tt.text, testData)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Render(%s) error = %v, want it to contain %q", tt.text, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Render(%s): %v", tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Render(%s) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWithFuncs(t *testing.T) {
+	base := New(true)
+	engine := base.WithFuncs(template.FuncMap{
+		"output": func(job, name string) string { return job + "/" + name },
+	})
+
+	got, err := engine.Render("field", `{{ output "db" "url" | lower }}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "db/url" {
+		t.Errorf("Render() = %q, want db/url", got)
+	}
+	if !engine.Strict() {
+		t.Error("WithFuncs() dropped strict mode")
+	}
+	// The original engine is unchanged
+	if _, err := base.Render("field", `{{ output "db" "url" }}`, nil); err == nil {
+		t.Error("WithFuncs() added the function to the original engine")
+	}
+}
+
+func TestRenderValue(t *testing.T) {
+	value := map[string]any{
+		"name":    "{{.component}}",
+		"args":    []any{"--tag", "{{.values.image.tag}}", 3},
+		"env":     map[string]string{"NS": "{{.namespace | default \"default\"}}"},
+		"steps":   []string{"echo {{.component}}"},
+		"enabled": true,
+	}
+
+	got, err := New(false).RenderValue("metadata", value, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"name":    "api",
+		"args":    []any{"--tag", "v1", 3},
+		"env":     map[string]string{"NS": "default"},
+		"steps":   []string{"echo api"},
+		"enabled": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RenderValue() = %v, want %v", got, want)
+	}
+	// The input is copied, not rendered in place
+	if value["name"] != "{{.component}}" || value["args"].([]any)[1] != "{{.values.image.tag}}" {
+		t.Errorf("RenderValue() modified its input: %v", value)
+	}
+}
+
+// TestErrorPositions checks that errors name the field holding the template
+// and the position of the failing action within it
+func TestErrorPositions(t *testing.T) {
+	value := map[string]any{
+		"commands": []any{
+			"helm lint",
+			"helm upgrade {{.component}}\n  --set tag={{.values.image.tag}} {{.chartPath}}",
+		},
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{
+			name:  "missing key",
+			value: value,
+			want:  `field commands[1]: <.chartPath> (line 2, col 36): map has no entry for key "chartPath"`,
+		},
+		{
+			name:  "function error",
+			value: map[string]any{"metadata": map[string]any{"url": `{{ required "namespace is required" .namespace }}`}},
+			want:  `field metadata.url: <required "namespace is required" .namespace> (line 1, col 3): error calling required: namespace is required`,
+		},
+		{
+			name:  "parse error",
+			value: []any{"ok", "first line\n{{ if .component }}"},
+			want:  `field commands[1]: line 2: unexpected EOF`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := ""
+			if _, ok := tt.value.([]any); ok {
+				field = "commands"
+			}
+			_, err := New(true).RenderValue(field, tt.value, testData)
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("RenderValue() error = %v, want a *FieldError", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err.Error(), tt.want)
+			}
+
+			fieldErr.Job = "api-deploy"
+			if !strings.HasPrefix(err.Error(), "job api-deploy: field ") {
+				t.Errorf("error with job = %q, want it to name the job", err.Error())
+			}
+		})
+	}
+}
+
+func TestWalkKeepsFieldErrors(t *testing.T) {
+	inner := &FieldError{Field: "inner", Err: errors.New("failed")}
+	_, err := Walk("outer", []any{"x"}, func(path, s string) (string, error) {
+		return "", inner
+	})
+	if err != inner {
+		t.Errorf("Walk() error = %v, want the FieldError returned by fn", err)
+	}
+	if !errors.Is(err, inner.Err) {
+		t.Error("FieldError does not unwrap to its cause")
+	}
+}
@@ -1,33 +1,78 @@
 package thinci
 
 import (
+	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/sourceplane/sourceplane/internal/glob"
 	"github.com/sourceplane/sourceplane/internal/models"
+	"github.com/sourceplane/sourceplane/internal/providers"
+	"github.com/sourceplane/sourceplane/internal/templating"
 )
 
-// ChangeDetector identifies which components are affected by file changes
+// ChangeDetector identifies which components are affected by file changes.
+// Which files belong to a component is declared by its provider in the
+// thinCI changeDetection section of provider.yaml.
 type ChangeDetector struct {
 	repositoryPath string
 	intents        []*models.Repository
+	registry       *ProviderRegistry
+
+	// sources are parallel to intents; without them any changed intent file
+	// affects every component
+	sources     []IntentSource
+	environment string
+
+	// baseDir is the directory the intents' paths are relative to, relative
+	// to the repository root that changed files are relative to
+	baseDir string
 }
 
 // NewChangeDetector creates a new change detector
-func NewChangeDetector(repositoryPath string, intents []*models.Repository) *ChangeDetector {
+func NewChangeDetector(repositoryPath string, intents []*models.Repository, registry *ProviderRegistry) *ChangeDetector {
 	return &ChangeDetector{
 		repositoryPath: repositoryPath,
 		intents:        intents,
+		registry:       registry,
 	}
 }
 
+// WithIntentSources compares each intent with its state at the base of the
+// diff, so that a changed intent file only affects the components whose
+// definition, provider configuration or locked provider version changed.
+// Overlays of the planned environment are compared too.
+func (cd *ChangeDetector) WithIntentSources(sources []IntentSource, environment string) *ChangeDetector {
+	cd.sources = sources
+	cd.environment = environment
+	return cd
+}
+
+// WithBaseDir sets the directory of the intent files relative to the
+// repository root. Changed files are relative to the root, while component,
+// provider and shared paths are relative to the intent, so files outside
+// baseDir only affect components through the intent itself.
+func (cd *ChangeDetector) WithBaseDir(baseDir string) *ChangeDetector {
+	cd.baseDir = baseDir
+	return cd
+}
+
 // DetectChanges analyzes changed files and returns affected components
 func (cd *ChangeDetector) DetectChanges(changedFiles []string) ([]ComponentChange, error) {
 	changes := make(map[string]*ComponentChange)
+	var order []string
 
-	for _, intent := range cd.intents {
+	for i, intent := range cd.intents {
+		var source *IntentSource
+		if i < len(cd.sources) {
+			source = &cd.sources[i]
+		}
 		for _, component := range intent.Components {
-			change := cd.checkComponentAffected(component, intent, changedFiles)
+			change, _, err := cd.checkComponentAffected(component, intent, source, changedFiles)
+			if err != nil {
+				return nil, err
+			}
 			if change != nil {
 				// Use component name as key to deduplicate
 				if existing, ok := changes[component.Name]; ok {
@@ -35,82 +80,159 @@ func (cd *ChangeDetector) DetectChanges(changedFiles []string) ([]ComponentChang
 					existing.AffectedPaths = append(existing.AffectedPaths, change.AffectedPaths...)
 				} else {
 					changes[component.Name] = change
+					order = append(order, component.Name)
 				}
 			}
 		}
 	}
 
-	// Convert map to slice
+	// Convert map to slice, in intent order
 	result := make([]ComponentChange, 0, len(changes))
-	for _, change := range changes {
-		result = append(result, *change)
+	for _, name := range order {
+		result = append(result, *changes[name])
 	}
 
 	return result, nil
 }
 
-// checkComponentAffected checks if a component is affected by the changed files
+// Evaluate applies the change detection rules to one component and returns
+// every rule evaluated, with the changed files each one matched
+func (cd *ChangeDetector) Evaluate(componentName string, changedFiles []string) ([]RuleEvaluation, error) {
+	var evaluations []RuleEvaluation
+	found := false
+
+	for i, intent := range cd.intents {
+		var source *IntentSource
+		if i < len(cd.sources) {
+			source = &cd.sources[i]
+		}
+		for _, component := range intent.Components {
+			if component.Name != componentName {
+				continue
+			}
+			_, componentEvaluations, err := cd.checkComponentAffected(component, intent, source, changedFiles)
+			if err != nil {
+				return nil, err
+			}
+			evaluations = append(evaluations, componentEvaluations...)
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("component '%s' not found in intent", componentName)
+	}
+	return evaluations, nil
+}
+
+// checkComponentAffected checks if a component is affected by the changed
+// files. It also returns every rule it evaluated, with the files each one
+// matched, for explaining the decision.
 func (cd *ChangeDetector) checkComponentAffected(
 	component models.Component,
 	intent *models.Repository,
+	source *IntentSource,
 	changedFiles []string,
-) *ComponentChange {
+) (*ComponentChange, []RuleEvaluation, error) {
 	var affectedPaths []string
 	var reason string
+	var evaluations []RuleEvaluation
 
 	// Extract provider name from component type (e.g., "terraform.database" -> "terraform")
 	provider := extractProvider(component.Type)
+	rules := cd.changeDetection(provider)
+	providerFile := cd.providerFile(provider)
 
-	// Check if intent.yaml itself changed
-	for _, file := range changedFiles {
-		if strings.HasSuffix(file, "intent.yaml") || strings.HasSuffix(file, "sourceplane.yaml") {
-			affectedPaths = append(affectedPaths, file)
-			reason = "Intent definition changed"
-			break
+	// match applies a rule to files below the intent directory, recording
+	// the files it matched
+	match := func(rule, origin string, patterns, files []string) []string {
+		evaluation := RuleEvaluation{Rule: rule, Source: origin, Patterns: patterns}
+		for _, file := range files {
+			if local, ok := cd.intentRelative(file); ok && glob.MatchList(patterns, local) {
+				evaluation.Matched = append(evaluation.Matched, file)
+			}
 		}
+		evaluations = append(evaluations, evaluation)
+		return evaluation.Matched
 	}
 
-	// Check component-specific paths
-	componentPaths := cd.getComponentPaths(component, provider)
-	for _, file := range changedFiles {
-		for _, compPath := range componentPaths {
-			if cd.pathMatches(file, compPath) {
-				affectedPaths = append(affectedPaths, file)
-				if reason == "" {
-					reason = "Component files changed"
-				}
+	// Check if the component's definition in the intent changed
+	if source != nil {
+		evaluation := cd.checkIntentChanged(component, intent, source, changedFiles)
+		evaluations = append(evaluations, evaluation)
+		if len(evaluation.Matched) > 0 {
+			affectedPaths, reason = evaluation.Matched, evaluation.Detail
+		}
+	} else {
+		evaluation := RuleEvaluation{Rule: "intent", Source: "any intent file", Patterns: []string{"**/intent.yaml", "**/sourceplane.yaml"}}
+		for _, file := range changedFiles {
+			if glob.MatchList(evaluation.Patterns, file) {
+				evaluation.Matched = append(evaluation.Matched, file)
 			}
 		}
+		evaluations = append(evaluations, evaluation)
+		if len(evaluation.Matched) > 0 {
+			affectedPaths = append(affectedPaths, evaluation.Matched[0])
+			reason = "Intent definition changed"
+		}
 	}
 
-	// Check provider-level changes
-	providerPaths := cd.getProviderPaths(provider)
+	// Ignored files affect neither the component nor shared paths. The
+	// component's ignorePaths come last, so its ! patterns can re-include
+	// files the provider ignores.
+	ignorePaths := append(append([]string{}, rules.IgnorePaths...), component.IgnorePaths...)
+	ignored := match("ignorePaths", providerFile+" and intent", ignorePaths, changedFiles)
+	relevantFiles := make([]string, 0, len(changedFiles))
 	for _, file := range changedFiles {
-		for _, provPath := range providerPaths {
-			if cd.pathMatches(file, provPath) {
-				affectedPaths = append(affectedPaths, file)
-				if reason == "" {
-					reason = "Provider configuration changed"
-				}
-			}
+		if !slices.Contains(ignored, file) {
+			relevantFiles = append(relevantFiles, file)
 		}
 	}
 
-	// Check shared module dependencies
-	sharedModulePaths := cd.getSharedModulePaths(component, provider)
+	// Check component-specific paths: the component's own paths when it
+	// declares them, otherwise the ones its provider derives
+	componentPaths, origin := component.Paths, "intent paths of "+component.Name
+	if len(componentPaths) == 0 {
+		var err error
+		componentPaths, origin, err = cd.getComponentPaths(component, provider, rules)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if matched := match("component paths", origin, componentPaths, relevantFiles); len(matched) > 0 {
+		affectedPaths = append(affectedPaths, matched...)
+		if reason == "" {
+			reason = "Component files changed"
+		}
+	}
+
+	// Check provider-level changes. Provider paths are relative to the
+	// repository, since a provider need not be below the intent directory.
+	providerPaths, providerOrigin := cd.getProviderPaths(provider)
+	evaluation := RuleEvaluation{Rule: "provider definition", Source: providerOrigin, Patterns: providerPaths}
 	for _, file := range changedFiles {
-		for _, modPath := range sharedModulePaths {
-			if cd.pathMatches(file, modPath) {
-				affectedPaths = append(affectedPaths, file)
-				if reason == "" {
-					reason = "Shared module changed"
-				}
-			}
+		if glob.MatchList(providerPaths, file) {
+			evaluation.Matched = append(evaluation.Matched, file)
+		}
+	}
+	evaluations = append(evaluations, evaluation)
+	if len(evaluation.Matched) > 0 {
+		affectedPaths = append(affectedPaths, evaluation.Matched...)
+		if reason == "" {
+			reason = "Provider configuration changed"
+		}
+	}
+
+	// Check paths the provider shares between its components
+	if matched := match("sharedPaths", providerFile, rules.SharedPaths, relevantFiles); len(matched) > 0 {
+		affectedPaths = append(affectedPaths, matched...)
+		if reason == "" {
+			reason = "Shared module changed"
 		}
 	}
 
 	if len(affectedPaths) == 0 {
-		return nil
+		return nil, evaluations, nil
 	}
 
 	return &ComponentChange{
@@ -119,116 +241,190 @@ func (cd *ChangeDetector) checkComponentAffected(
 		ComponentType: component.Type,
 		Reason:        reason,
 		AffectedPaths: affectedPaths,
-	}
+	}, evaluations, nil
 }
 
-// getComponentPaths returns paths that are specific to a component
-func (cd *ChangeDetector) getComponentPaths(component models.Component, provider string) []string {
-	paths := []string{}
-
-	// Extract path from component spec
-	if spec := component.Spec; spec != nil {
-		switch provider {
-		case "terraform":
-			// Check for module source path or inline path
-			if module, ok := spec["module"].(map[string]interface{}); ok {
-				if source, ok := module["source"].(string); ok && !strings.HasPrefix(source, "terraform-") {
-					// Local module path
-					paths = append(paths, source)
-				}
-			}
-			if path, ok := spec["path"].(string); ok {
-				paths = append(paths, path)
-			}
+// checkIntentChanged compares a component with its definition at the base
+// of the diff when its intent file or lock file changed. Detail names the
+// changed fields.
+func (cd *ChangeDetector) checkIntentChanged(
+	component models.Component,
+	intent *models.Repository,
+	source *IntentSource,
+	changedFiles []string,
+) RuleEvaluation {
+	evaluation := RuleEvaluation{
+		Rule:     "intent",
+		Source:   "definition at the merge-base",
+		Patterns: []string{source.Path, source.LockPath()},
+	}
+	var reasons, unchanged []string
 
-		case "helm":
-			// Check for chart path
-			if chart, ok := spec["chart"].(map[string]interface{}); ok {
-				if path, ok := chart["path"].(string); ok {
-					paths = append(paths, path)
-				}
-			}
-			if chartPath, ok := spec["chartPath"].(string); ok {
-				paths = append(paths, chartPath)
+	for _, file := range changedFiles {
+		switch file {
+		case source.Path:
+			fields, added := componentDiff(source, intent, component, cd.environment)
+			switch {
+			case added:
+				reasons = append(reasons, "Component added to intent")
+			case len(fields) > 0:
+				reasons = append(reasons, "Intent changed: "+strings.Join(fields, ", "))
+			default:
+				unchanged = append(unchanged, file)
+				continue
 			}
-			if valuesPath, ok := spec["valuesPath"].(string); ok {
-				paths = append(paths, valuesPath)
+			evaluation.Matched = append(evaluation.Matched, file)
+		case source.LockPath():
+			if reason := lockDiff(source, extractProvider(component.Type)); reason != "" {
+				reasons = append(reasons, reason)
+				evaluation.Matched = append(evaluation.Matched, file)
+			} else {
+				unchanged = append(unchanged, file)
 			}
 		}
 	}
 
-	// Fallback: convention-based paths
-	if len(paths) == 0 {
-		conventionPath := cd.getConventionBasedPath(component.Name, provider)
-		if conventionPath != "" {
-			paths = append(paths, conventionPath)
-		}
+	// Changed files that leave the component as it was are worth explaining
+	if len(reasons) > 0 {
+		evaluation.Detail = strings.Join(reasons, "; ")
+	} else if len(unchanged) > 0 {
+		evaluation.Detail = fmt.Sprintf("%s changed, but not the component's definition or locked provider", strings.Join(unchanged, " and "))
 	}
-
-	return paths
+	return evaluation
 }
 
-// getConventionBasedPath returns conventional paths based on provider
-func (cd *ChangeDetector) getConventionBasedPath(componentName, provider string) string {
-	switch provider {
-	case "terraform":
-		return filepath.Join("terraform", componentName)
-	case "helm":
-		return filepath.Join("helm", componentName)
-	default:
-		return filepath.Join(provider, componentName)
+// intentRelative returns a changed file relative to the intent directory;
+// ok is false for files outside it
+func (cd *ChangeDetector) intentRelative(file string) (string, bool) {
+	if cd.baseDir == "" || cd.baseDir == "." {
+		return file, true
 	}
+	rel, ok := strings.CutPrefix(glob.Clean(file), glob.Clean(cd.baseDir)+"/")
+	return rel, ok
 }
 
-// getProviderPaths returns paths that affect all components of a provider
-func (cd *ChangeDetector) getProviderPaths(provider string) []string {
-	return []string{
-		filepath.Join("providers", provider, "provider.yaml"),
-		filepath.Join("providers", provider, "schema.yaml"),
-		filepath.Join(".sourceplane", "providers", provider),
+// changeDetection returns the change detection rules a provider declares,
+// or the defaults when it declares none or is not registered
+func (cd *ChangeDetector) changeDetection(provider string) providers.ChangeDetection {
+	var rules providers.ChangeDetection
+	if cd.registry != nil {
+		if meta, err := cd.registry.GetProvider(provider); err == nil {
+			rules = meta.ThinCI.ChangeDetection
+		}
+	}
+	if rules.IsZero() {
+		return providers.DefaultChangeDetection(provider)
+	}
+	if len(rules.SpecPaths) == 0 {
+		rules.SpecPaths = []string{providers.DefaultSpecPath}
 	}
+	if rules.ConventionPath == "" {
+		rules.ConventionPath = providers.DefaultConventionPath
+	}
+	return rules
 }
 
-// getSharedModulePaths returns paths to shared modules that a component depends on
-func (cd *ChangeDetector) getSharedModulePaths(component models.Component, provider string) []string {
+// getComponentPaths returns paths that are specific to a component: the
+// values of the spec fields the provider declares as paths or, when none is
+// set, the provider's convention path. It also describes where the paths
+// came from.
+func (cd *ChangeDetector) getComponentPaths(component models.Component, provider string, rules providers.ChangeDetection) ([]string, string, error) {
 	paths := []string{}
+	fields := []string{}
 
-	switch provider {
-	case "terraform":
-		// Check for shared modules in spec
-		if spec := component.Spec; spec != nil {
-			if module, ok := spec["module"].(map[string]interface{}); ok {
-				if source, ok := module["source"].(string); ok {
-					if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
-						paths = append(paths, source)
-					}
-				}
-			}
+	for _, field := range rules.SpecPaths {
+		value, ok := lookupPath(component.Spec, field)
+		if !ok {
+			continue
+		}
+		if path, ok := value.(string); ok && isLocalPath(path) {
+			paths = append(paths, path)
+			fields = append(fields, "spec."+field)
 		}
-		// Common shared modules paths
-		paths = append(paths, "terraform/modules")
-	case "helm":
-		paths = append(paths, "helm/charts")
+	}
+	if len(paths) > 0 {
+		return paths, fmt.Sprintf("%s (specPaths of %s)", strings.Join(fields, ", "), cd.providerFile(provider)), nil
 	}
 
-	return paths
+	// Fallback: convention-based paths
+	conventionPath, err := templating.New(true).Render("conventionPath", rules.ConventionPath, map[string]any{
+		"component": component.Name,
+		"provider":  provider,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("provider %s conventionPath: %w", provider, err)
+	}
+	if conventionPath != "" {
+		paths = append(paths, conventionPath)
+	}
+
+	return paths, fmt.Sprintf("conventionPath %q of %s", rules.ConventionPath, cd.providerFile(provider)), nil
 }
 
-// pathMatches checks if a file path matches a pattern
-func (cd *ChangeDetector) pathMatches(file, pattern string) bool {
-	// Direct match
-	if file == pattern {
-		return true
+// providerFile names the provider.yaml a provider's change detection rules
+// come from, relative to the repository when it is inside it
+func (cd *ChangeDetector) providerFile(provider string) string {
+	if cd.registry != nil {
+		if meta, err := cd.registry.GetProvider(provider); err == nil && meta.Dir != "" {
+			file := filepath.Join(meta.Dir, "provider.yaml")
+			if rel, ok := cd.repositoryRelative(file); ok {
+				return rel
+			}
+			return file
+		}
 	}
+	return "provider " + provider + " (not loaded)"
+}
 
-	// Prefix match (file is under pattern directory)
-	if strings.HasPrefix(file, pattern+"/") {
-		return true
+// getProviderPaths returns paths that affect all components of a provider,
+// relative to the repository, and describes where they came from. A loaded
+// provider is affected by its own directory when that is inside the
+// repository; one loaded from elsewhere, such as the provider cache, only
+// changes through the intent and its lock file. Providers that are not
+// loaded fall back to the conventional locations next to the intent.
+func (cd *ChangeDetector) getProviderPaths(provider string) ([]string, string) {
+	if cd.registry != nil {
+		if meta, err := cd.registry.GetProvider(provider); err == nil && meta.Dir != "" {
+			if dir, ok := cd.repositoryRelative(meta.Dir); ok {
+				return []string{dir}, "directory of " + cd.providerFile(provider)
+			}
+			return nil, cd.providerFile(provider) + " (outside the repository)"
+		}
+	}
+
+	baseDir := cd.baseDir
+	if baseDir == "" {
+		baseDir = "."
+	}
+	return []string{
+		glob.Clean(filepath.Join(baseDir, "providers", provider, "provider.yaml")),
+		glob.Clean(filepath.Join(baseDir, "providers", provider, "schema.yaml")),
+		glob.Clean(filepath.Join(baseDir, ".sourceplane", "providers", provider)),
+	}, "built-in"
+}
+
+// repositoryRelative returns a path relative to the repository, with
+// slashes; ok is false for paths outside it. Relative paths are relative to
+// the working directory.
+func (cd *ChangeDetector) repositoryRelative(path string) (string, bool) {
+	root, err := filepath.Abs(cd.repositoryPath)
+	if err != nil {
+		return "", false
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", false
 	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
 
-	// Pattern match
-	matched, _ := filepath.Match(pattern, file)
-	return matched
+// isLocalPath reports whether a spec value is a path in the repository
+// rather than a URL or remote module source
+func isLocalPath(p string) bool {
+	return p != "" && !strings.Contains(p, "://") && !strings.Contains(p, "::") && !strings.HasPrefix(p, "git@")
 }
 
 // extractProvider extracts provider name from component type
@@ -0,0 +1,271 @@
+package thinci
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourceplane/sourceplane/internal/models"
+)
+
+func TestDetectChangesRelativeToIntentDir(t *testing.T) {
+	intent := &models.Repository{
+		Components: []models.Component{
+			{Name: "db", Type: "helm.service", Spec: map[string]any{"path": "./charts/db"}},
+			{Name: "api", Type: "helm.service", Spec: map[string]any{"path": "./charts/api"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		baseDir string
+		changed []string
+		want    map[string][]string
+	}{
+		{
+			name:    "intent at the repository root",
+			changed: []string{"charts/db/values.yaml"},
+			want:    map[string][]string{"db": {"charts/db/values.yaml"}},
+		},
+		{
+			name:    "intent in a subdirectory",
+			baseDir: "svc",
+			changed: []string{"svc/charts/db/values.yaml"},
+			want:    map[string][]string{"db": {"svc/charts/db/values.yaml"}},
+		},
+		{
+			name:    "file outside the intent directory",
+			baseDir: "svc",
+			changed: []string{"charts/db/values.yaml", "other/svc/charts/api/values.yaml"},
+			want:    map[string][]string{},
+		},
+		{
+			name:    "nested intent without intent sources",
+			baseDir: "svc",
+			changed: []string{"svc/intent.yaml"},
+			want: map[string][]string{
+				"db":  {"svc/intent.yaml"},
+				"api": {"svc/intent.yaml"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := NewChangeDetector(".", []*models.Repository{intent}, nil).WithBaseDir(tt.baseDir)
+			changes, err := detector.DetectChanges(tt.changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string][]string)
+			for _, change := range changes {
+				got[change.ComponentName] = change.AffectedPaths
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("affected = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectChangesTerraformDefaults(t *testing.T) {
+	intent := &models.Repository{
+		Components: []models.Component{
+			{Name: "network", Type: "terraform.module", Spec: map[string]any{
+				"module": map[string]any{"source": "./modules/network"},
+			}},
+			{Name: "database", Type: "terraform.database"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{name: "module source", changed: []string{"modules/network/main.tf"}, want: []string{"network"}},
+		{name: "convention path", changed: []string{"terraform/database/main.tf"}, want: []string{"database"}},
+		{name: "shared modules", changed: []string{"terraform/modules/vpc/main.tf"}, want: []string{"database", "network"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := NewChangeDetector(".", []*models.Repository{intent}, nil).DetectChanges(tt.changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, change := range changes {
+				got = append(got, change.ComponentName)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("affected components = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectChangesProviderPaths(t *testing.T) {
+	repo := t.TempDir()
+	intent := &models.Repository{
+		Components: []models.Component{
+			{Name: "api", Type: "helm.service", Paths: []string{"services/api"}},
+		},
+	}
+	registry := func(dir string) *ProviderRegistry {
+		registry := NewProviderRegistry()
+		registry.RegisterProvider(&ProviderMetadata{Name: "helm", Dir: dir})
+		return registry
+	}
+
+	tests := []struct {
+		name     string
+		registry *ProviderRegistry
+		baseDir  string
+		changed  string
+		want     bool
+	}{
+		{
+			name:     "loaded from the repository",
+			registry: registry(filepath.Join(repo, "platform", "helm")),
+			changed:  "platform/helm/templates/job.yaml",
+			want:     true,
+		},
+		{
+			name:     "conventional location of a provider loaded elsewhere",
+			registry: registry(filepath.Join(repo, "platform", "helm")),
+			changed:  "providers/helm/provider.yaml",
+		},
+		{
+			name:     "loaded from outside the repository",
+			registry: registry(filepath.Join(t.TempDir(), "helm")),
+			changed:  "providers/helm/provider.yaml",
+		},
+		{
+			name:    "not loaded",
+			changed: "providers/helm/provider.yaml",
+			want:    true,
+		},
+		{
+			name:    "not loaded, intent in a subdirectory",
+			baseDir: "svc",
+			changed: "svc/.sourceplane/providers/helm/provider.yaml",
+			want:    true,
+		},
+		{
+			name:    "not loaded, outside the intent directory",
+			baseDir: "svc",
+			changed: "providers/helm/provider.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := NewChangeDetector(repo, []*models.Repository{intent}, tt.registry).WithBaseDir(tt.baseDir)
+			changes, err := detector.DetectChanges([]string{tt.changed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(changes) > 0; got != tt.want {
+				t.Errorf("api affected = %v, want %v (%+v)", got, tt.want, changes)
+			}
+			if tt.want && changes[0].Reason != "Provider configuration changed" {
+				t.Errorf("reason = %q, want the provider configuration", changes[0].Reason)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	intent := &models.Repository{
+		Components: []models.Component{
+			{
+				Name:        "api",
+				Type:        "helm.service",
+				Paths:       []string{"services/api/**"},
+				IgnorePaths: []string{"**/*.md"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    map[string][]string // matched files by rule, for the rules that matched
+	}{
+		{
+			name:    "matched",
+			changed: []string{"services/api/main.go", "docs/index.md"},
+			want: map[string][]string{
+				"ignorePaths":     {"docs/index.md"},
+				"component paths": {"services/api/main.go"},
+			},
+		},
+		{
+			name:    "not matched",
+			changed: []string{"services/web/main.go"},
+			want:    map[string][]string{},
+		},
+		{
+			name:    "ignored",
+			changed: []string{"services/api/README.md"},
+			want:    map[string][]string{"ignorePaths": {"services/api/README.md"}},
+		},
+		{
+			name:    "provider definition",
+			changed: []string{"providers/helm/provider.yaml"},
+			want:    map[string][]string{"provider definition": {"providers/helm/provider.yaml"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluations, err := NewChangeDetector(".", []*models.Repository{intent}, nil).Evaluate("api", tt.changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every rule is reported, whether it matched or not
+			var rules []string
+			got := make(map[string][]string)
+			for _, evaluation := range evaluations {
+				rules = append(rules, evaluation.Rule)
+				if len(evaluation.Matched) > 0 {
+					got[evaluation.Rule] = evaluation.Matched
+				}
+			}
+			wantRules := []string{"intent", "ignorePaths", "component paths", "provider definition", "sharedPaths"}
+			if !reflect.DeepEqual(rules, wantRules) {
+				t.Errorf("rules = %v, want %v", rules, wantRules)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matched = %v, want %v", got, tt.want)
+			}
+			if paths := evaluations[2]; paths.Source != "intent paths of api" || !reflect.DeepEqual(paths.Patterns, []string{"services/api/**"}) {
+				t.Errorf("component paths = %+v, want the intent's paths", paths)
+			}
+		})
+	}
+
+	// A changed intent file that leaves the component as it was is explained
+	source := IntentSource{Path: "intent.yaml", Base: intent}
+	evaluations, err := NewChangeDetector(".", []*models.Repository{intent}, nil).
+		WithIntentSources([]IntentSource{source}, "").
+		Evaluate("api", []string{"intent.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := evaluations[0]; got.Rule != "intent" || len(got.Matched) > 0 ||
+		got.Detail != "intent.yaml changed, but not the component's definition or locked provider" {
+		t.Errorf("intent evaluation = %+v, want the unchanged definition explained", got)
+	}
+
+	if _, err := NewChangeDetector(".", []*models.Repository{intent}, nil).Evaluate("web", nil); err == nil ||
+		err.Error() != "component 'web' not found in intent" {
+		t.Errorf("Evaluate() error = %v, want the unknown component reported", err)
+	}
+}
@@ -2,53 +2,226 @@ package thinci
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/sourceplane/sourceplane/internal/templating"
 )
 
 // Executor handles the execution of CI jobs locally
 type Executor struct {
-	verbose bool
-	dryRun  bool
+	verbose         bool
+	dryRun          bool
+	strictTemplates bool
+	gracePeriod     time.Duration
+	state           *RunState
+	stdout          io.Writer
+	stderr          io.Writer
 }
 
 // NewExecutor creates a new executor
 func NewExecutor(verbose, dryRun bool) *Executor {
 	return &Executor{
-		verbose: verbose,
-		dryRun:  dryRun,
+		verbose:     verbose,
+		dryRun:      dryRun,
+		gracePeriod: DefaultGracePeriod,
+		state:       NewRunState(DefaultStateDir),
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
 	}
 }
 
+// SetGracePeriod sets how long a cancelled or timed out command may take to
+// exit after being signalled before its process group is killed
+func (e *Executor) SetGracePeriod(d time.Duration) {
+	e.gracePeriod = d
+}
+
+// SetStrictTemplates makes templates referencing missing keys an error
+// instead of rendering them as empty strings
+func (e *Executor) SetStrictTemplates(strict bool) {
+	e.strictTemplates = strict
+}
+
+// SetStateDir sets the directory job outputs are persisted in
+func (e *Executor) SetStateDir(dir string) {
+	e.state = NewRunState(dir)
+}
+
+// SetOutput redirects the executor's log and command output
+func (e *Executor) SetOutput(stdout, stderr io.Writer) {
+	e.stdout = stdout
+	e.stderr = stderr
+}
+
+// jobRun carries per-job state through a single job execution
+type jobRun struct {
+	ctx            context.Context
+	context        map[string]any
+	retry          *RetryPolicy
+	commandTimeout time.Duration
+	result         *JobResult
+	phase          string
+	outputs        map[string]map[string]string // outputs of earlier jobs, by job ID
+	outputFile     string                       // this job's $SP_OUTPUT
+}
+
 // ExecuteJob runs a single job from a plan
-func (e *Executor) ExecuteJob(job Job) error {
+func (e *Executor) ExecuteJob(ctx context.Context, job Job) error {
+	_, err := e.runJob(ctx, job)
+	return err
+}
+
+// RunJob runs a single job from a plan and records its outcome
+func (e *Executor) RunJob(ctx context.Context, job Job) JobResult {
+	result, err := e.runJob(ctx, job)
+
+	result.Status = statusOf(ctx, err)
+	if err != nil {
+		result.Error = err.Error()
+		if result.Status != JobStatusCancelled {
+			result.AllowedFailure = toBool(job.GetMetadata()["continueOnError"])
+		}
+	}
+	return result
+}
+
+// runJob executes a job, retrying it as a whole when its retry policy has job scope
+func (e *Executor) runJob(ctx context.Context, job Job) (result JobResult, err error) {
 	jobID := job.GetID()
 	action := job.GetAction()
 	component := job.GetComponent()
 	
+	result = JobResult{
+		JobID:     jobID,
+		Component: component,
+		Action:    action,
+		StartTime: time.Now(),
+	}
+	defer func() {
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime)
+	}()
+	
 	e.logSection(fmt.Sprintf("Executing Job: %s", jobID))
 	e.logInfo(fmt.Sprintf("Component: %s", component))
 	e.logInfo(fmt.Sprintf("Action: %s", action))
 	
-	startTime := time.Now()
+	retryValue, _ := jobValue(job, "retryPolicy")
+	policy, err := ParseRetryPolicy(retryValue)
+	if err != nil {
+		return result, fmt.Errorf("invalid retry policy for job %s: %w", jobID, err)
+	}
+	if policy != nil {
+		e.logInfo(fmt.Sprintf("Retry policy: up to %d attempts per %s, %s backoff", policy.MaxAttempts, policy.Scope, policy.Backoff))
+	}
 	
-	// Extract job fields
-	preSteps := e.extractSteps(job, "preSteps")
-	commands := e.extractCommands(job, "commands")
-	postSteps := e.extractSteps(job, "postSteps")
-	inputs := e.extractInputs(job, "inputs")
+	metadata := job.GetMetadata()
 	
-	// Create template context for variable substitution
-	context := e.buildTemplateContext(job, inputs)
+	// Job timeout is expressed in minutes, matching the CI platforms
+	if timeout, ok := toInt(metadata["timeout"]); ok && timeout > 0 {
+		jobTimeout := time.Duration(timeout) * time.Minute
+		e.logInfo(fmt.Sprintf("Timeout: %s", jobTimeout))
+		
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, jobTimeout)
+		defer cancel()
+		defer func() {
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("%w: %v", &TimeoutError{Scope: "job", Timeout: jobTimeout}, err)
+			}
+		}()
+	}
+	
+	run := &jobRun{
+		ctx:     ctx,
+		result:  &result,
+	}
+	
+	// Only inputs from the plan and the provider's declared defaults are
+	// available to templates; required inputs are checked before anything runs
+	inputs := copyValue(job.GetInputs()).(map[string]any)
+	specs, err := job.GetInputSpecs()
+	if err != nil {
+		return result, fmt.Errorf("invalid input declarations for job %s: %w", jobID, err)
+	}
+	if err := applyInputDefaults(specs, inputs, job); err != nil {
+		return result, fmt.Errorf("invalid inputs for job %s: %w", jobID, err)
+	}
+	if err := ValidateInputs(specs, inputs); err != nil {
+		return result, fmt.Errorf("invalid inputs for job %s: %w", jobID, err)
+	}
+	run.context = templateData(job, inputs)
+	
+	if !e.dryRun {
+		// Dependencies have finished by now; outputs of other jobs are read
+		// when a template references them
+		run.outputs, err = e.state.LoadOutputs(job.GetDependsOn())
+		if err != nil {
+			return result, err
+		}
+		run.outputFile, err = e.state.PrepareOutputFile(jobID)
+		if err != nil {
+			return result, err
+		}
+		run.context["outputs"] = run.outputs
+	}
+	
+	if v, exists := metadata["commandTimeout"]; exists {
+		run.commandTimeout, err = parseDuration(v, time.Minute)
+		if err != nil {
+			return result, fmt.Errorf("invalid commandTimeout for job %s: %w", jobID, err)
+		}
+	}
+	
+	if policy == nil || policy.Scope != RetryScopeJob || e.dryRun {
+		run.retry = policy
+		err = e.executeJobOnce(job, run)
+	} else {
+		err = e.withRetry(policy, run, "job", func() error {
+			return e.executeJobOnce(job, run)
+		})
+	}
+	if err != nil {
+		return result, err
+	}
+	
+	if err := e.saveOutputs(job, run); err != nil {
+		return result, err
+	}
+	
+	e.logSuccess(fmt.Sprintf("Job completed successfully in %s", time.Since(result.StartTime).Round(time.Millisecond)))
+	
+	return result, nil
+}
+
+// executeJobOnce runs the job's pre-steps, main commands and post-steps
+func (e *Executor) executeJobOnce(job Job, run *jobRun) error {
+	// Step results describe the latest attempt; earlier ones are kept in Attempts
+	run.result.Steps = nil
+	if run.outputFile != "" {
+		if err := os.WriteFile(run.outputFile, nil, 0644); err != nil {
+			return fmt.Errorf("failed to reset output file: %w", err)
+		}
+	}
+	
+	// Extract job fields
+	preSteps := job.GetPreSteps()
+	commands := job.GetCommands()
+	postSteps := job.GetPostSteps()
 	
 	// Execute pre-steps
 	if len(preSteps) > 0 {
 		e.logSection("Pre-Steps")
-		if err := e.executeSteps(preSteps, context); err != nil {
+		run.phase = StepPhasePre
+		if err := e.executeSteps(preSteps, run); err != nil {
 			return fmt.Errorf("pre-steps failed: %w", err)
 		}
 	}
@@ -56,7 +229,8 @@ func (e *Executor) ExecuteJob(job Job) error {
 	// Execute main commands
 	if len(commands) > 0 {
 		e.logSection("Main Commands")
-		if err := e.executeCommands(commands, context); err != nil {
+		run.phase = StepPhaseMain
+		if err := e.executeCommands(commands, run); err != nil {
 			return fmt.Errorf("commands failed: %w", err)
 		}
 	}
@@ -64,134 +238,147 @@ func (e *Executor) ExecuteJob(job Job) error {
 	// Execute post-steps
 	if len(postSteps) > 0 {
 		e.logSection("Post-Steps")
-		if err := e.executeSteps(postSteps, context); err != nil {
+		run.phase = StepPhasePost
+		if err := e.executeSteps(postSteps, run); err != nil {
 			return fmt.Errorf("post-steps failed: %w", err)
 		}
 	}
 	
-	duration := time.Since(startTime)
-	e.logSuccess(fmt.Sprintf("Job completed successfully in %s", duration.Round(time.Millisecond)))
-	
 	return nil
 }
 
-// buildTemplateContext creates a map for template variable substitution
-func (e *Executor) buildTemplateContext(job Job, inputs map[string]any) map[string]string {
-	context := make(map[string]string)
+// withRetry calls fn until it succeeds, the policy is exhausted, or the
+// failure's exit code is not retryable. Every attempt is logged and recorded.
+func (e *Executor) withRetry(policy *RetryPolicy, run *jobRun, label string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := run.ctx.Err(); err != nil {
+			return context.Cause(run.ctx)
+		}
 	
-	// Add core job fields
-	context["id"] = job.GetID()
-	context["component"] = job.GetComponent()
-	context["provider"] = job.GetProvider()
-	context["action"] = job.GetAction()
+		start := time.Now()
+		err := fn()
 	
-	// Add all inputs as strings for template resolution
-	for key, value := range inputs {
-		context[key] = fmt.Sprintf("%v", value)
-	}
+		record := AttemptRecord{
+			Step:     label,
+			Attempt:  attempt,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			record.Error = err.Error()
+			record.ExitCode = exitCodeOf(err)
+		}
+		run.result.Attempts = append(run.result.Attempts, record)
 	
-	// Add common defaults
-	if _, exists := context["releaseName"]; !exists {
-		context["releaseName"] = job.GetComponent()
-	}
-	if _, exists := context["namespace"]; !exists {
-		context["namespace"] = "default"
-	}
-	if _, exists := context["chartPath"]; !exists {
-		context["chartPath"] = "."
-	}
-	if _, exists := context["valuesPath"]; !exists {
-		context["valuesPath"] = "values.yaml"
-	}
-	if _, exists := context["timeout"]; !exists {
-		context["timeout"] = "10m"
-	}
+		if err == nil {
+			if attempt > 1 {
+				e.logInfo(fmt.Sprintf("%s succeeded on attempt %d/%d", label, attempt, policy.MaxAttempts))
+			}
+			return nil
+		}
 	
-	return context
-}
-
-// extractSteps extracts ActionStep array from job
-func (e *Executor) extractSteps(job Job, fieldName string) []ActionStep {
-	steps := []ActionStep{}
+		if run.ctx.Err() != nil {
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			e.logError(fmt.Sprintf("%s failed after %d attempt(s)", label, attempt))
+			return err
+		}
+		if !policy.ShouldRetry(record.ExitCode) {
+			e.logError(fmt.Sprintf("%s failed with exit code %d, which is not retryable", label, record.ExitCode))
+			return err
+		}
 	
-	if stepsRaw, ok := job[fieldName]; ok {
-		switch v := stepsRaw.(type) {
-		case []ActionStep:
-			steps = v
-		case []interface{}:
-			for _, stepRaw := range v {
-				if stepMap, ok := stepRaw.(map[string]interface{}); ok {
-					step := ActionStep{
-						Name:    getString(stepMap, "name"),
-						Command: getString(stepMap, "command"),
-					}
-					if inputsMap, ok := stepMap["inputs"].(map[string]interface{}); ok {
-						step.Inputs = make(map[string]any)
-						for k, v := range inputsMap {
-							step.Inputs[k] = v
-						}
-					}
-					steps = append(steps, step)
-				}
-			}
+		delay := policy.DelayFor(attempt)
+		e.logInfo(fmt.Sprintf("Attempt %d/%d of %s failed (exit code %d), retrying in %s",
+			attempt, policy.MaxAttempts, label, record.ExitCode, delay.Round(time.Millisecond)))
+		if err := sleepContext(run.ctx, delay); err != nil {
+			return err
 		}
 	}
-	
-	return steps
 }
 
-// extractCommands extracts command array from job
-func (e *Executor) extractCommands(job Job, fieldName string) []string {
-	commands := []string{}
+// saveOutputs reads the outputs a job wrote to $SP_OUTPUT and persists them
+// for downstream jobs
+func (e *Executor) saveOutputs(job Job, run *jobRun) error {
+	if run.outputFile == "" {
+		return nil
+	}
 	
-	if commandsRaw, ok := job[fieldName]; ok {
-		switch v := commandsRaw.(type) {
-		case []string:
-			commands = v
-		case []interface{}:
-			for _, cmd := range v {
-				if cmdStr, ok := cmd.(string); ok {
-					commands = append(commands, cmdStr)
-				}
-			}
-		}
+	outputs, err := ParseOutputFile(run.outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to read outputs: %w", err)
+	}
+	if err := e.state.SaveOutputs(job.GetID(), outputs); err != nil {
+		return err
 	}
 	
-	return commands
+	run.result.Outputs = outputs
+	if len(outputs) > 0 {
+		e.logInfo(fmt.Sprintf("Outputs: %s", strings.Join(sortedKeys(outputs), ", ")))
+	}
+	for _, name := range toStringSlice(job["outputs"]) {
+		if _, ok := outputs[name]; !ok && e.verbose {
+			e.logInfo(fmt.Sprintf("Declared output %s was not set", name))
+		}
+	}
+	return nil
 }
 
-// extractInputs extracts inputs map from job
-func (e *Executor) extractInputs(job Job, fieldName string) map[string]any {
-	inputs := make(map[string]any)
+// outputFunc returns the `output` template function resolving outputs of earlier jobs
+func (e *Executor) outputFunc(run *jobRun) func(jobID, name string) (string, error) {
+	return func(jobID, name string) (string, error) {
+		if e.dryRun {
+			if value, ok := run.outputs[jobID][name]; ok {
+				return value, nil
+			}
+			return fmt.Sprintf("<outputs.%s.%s>", jobID, name), nil
+		}
 	
-	if inputsRaw, ok := job[fieldName]; ok {
-		if inputsMap, ok := inputsRaw.(map[string]interface{}); ok {
-			inputs = inputsMap
+		outputs, ok := run.outputs[jobID]
+		if !ok {
+			loaded, err := e.state.LoadOutputs([]string{jobID})
+			if err != nil {
+				return "", err
+			}
+			if outputs, ok = loaded[jobID]; ok {
+				run.outputs[jobID] = outputs
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("no outputs recorded for job %s in %s; it must run successfully before %s", jobID, e.state.Dir(), run.result.JobID)
 		}
+		value, ok := outputs[name]
+		if !ok {
+			return "", fmt.Errorf("job %s did not set output %s", jobID, name)
+		}
+		return value, nil
 	}
-	
-	return inputs
 }
 
 // executeSteps executes a list of action steps
-func (e *Executor) executeSteps(steps []ActionStep, context map[string]string) error {
+func (e *Executor) executeSteps(steps []ActionStep, run *jobRun) error {
 	for i, step := range steps {
 		e.logStep(i+1, step.Name)
 		
 		// Resolve template variables in command
-		command, err := e.resolveTemplate(step.Command, context)
+		command, err := e.resolveTemplate(fmt.Sprintf("%s[%d].command", stepsField(run.phase), i), step.Command, run)
 		if err != nil {
-			return fmt.Errorf("failed to resolve template in step '%s': %w", step.Name, err)
+			run.recordStepError(step.Name, step.Command, err)
+			return err
 		}
 		
-		e.logCommand(command)
-		
-		if !e.dryRun {
-			if err := e.runCommand(command); err != nil {
-				return fmt.Errorf("step '%s' failed: %w", step.Name, err)
+		timeout := run.commandTimeout
+		if step.Timeout != "" {
+			timeout, err = parseDuration(step.Timeout, time.Minute)
+			if err != nil {
+				err = fmt.Errorf("invalid timeout for step '%s': %w", step.Name, err)
+				run.recordStepError(step.Name, command, err)
+				return err
 			}
-		} else {
-			e.logInfo("[DRY RUN] Command skipped")
+		}
+		
+		if err := e.executeCommand(command, step.Name, timeout, run); err != nil {
+			return fmt.Errorf("step '%s' failed: %w", step.Name, err)
 		}
 	}
 	
@@ -199,135 +386,295 @@ func (e *Executor) executeSteps(steps []ActionStep, context map[string]string) e
 }
 
 // executeCommands executes a list of commands
-func (e *Executor) executeCommands(commands []string, context map[string]string) error {
+func (e *Executor) executeCommands(commands []string, run *jobRun) error {
 	for i, cmdTemplate := range commands {
-		e.logStep(i+1, fmt.Sprintf("Command %d", i+1))
+		label := fmt.Sprintf("Command %d", i+1)
+		e.logStep(i+1, label)
 		
 		// Resolve template variables in command
-		command, err := e.resolveTemplate(cmdTemplate, context)
+		command, err := e.resolveTemplate(fmt.Sprintf("commands[%d]", i), cmdTemplate, run)
 		if err != nil {
-			return fmt.Errorf("failed to resolve template in command: %w", err)
+			run.recordStepError(label, cmdTemplate, err)
+			return err
 		}
 		
-		e.logCommand(command)
-		
-		if !e.dryRun {
-			if err := e.runCommand(command); err != nil {
-				return fmt.Errorf("command failed: %w", err)
-			}
-		} else {
-			e.logInfo("[DRY RUN] Command skipped")
+		if err := e.executeCommand(command, label, run.commandTimeout, run); err != nil {
+			return err
 		}
 	}
 	
 	return nil
 }
 
-// resolveTemplate resolves Go template variables in a string
-func (e *Executor) resolveTemplate(templateStr string, context map[string]string) (string, error) {
-	tmpl, err := template.New("command").Parse(templateStr)
-	if err != nil {
-		return "", err
+// executeCommand runs a resolved command, retrying it when the job has a command-scoped retry policy
+func (e *Executor) executeCommand(command, label string, timeout time.Duration, run *jobRun) (err error) {
+	e.logCommand(command)
+	
+	step := StepResult{
+		Name:      label,
+		Phase:     run.phase,
+		Command:   command,
+		StartTime: time.Now(),
+	}
+	defer func() {
+		step.EndTime = time.Now()
+		step.Duration = step.EndTime.Sub(step.StartTime)
+		if err != nil {
+			step.Error = err.Error()
+			step.ExitCode = exitCodeOf(err)
+		}
+		run.result.Steps = append(run.result.Steps, step)
+	}()
+	
+	if e.dryRun {
+		e.logInfo("[DRY RUN] Command skipped")
+		step.Status = JobStatusSkipped
+		return nil
 	}
 	
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, context); err != nil {
-		return "", err
+	if run.retry == nil || run.retry.Scope != RetryScopeCommand {
+		err = e.runCommand(run, command, timeout, &step)
+	} else {
+		err = e.withRetry(run.retry, run, label, func() error {
+			step.Attempts++
+			return e.runCommand(run, command, timeout, &step)
+		})
 	}
+	step.Status = statusOf(run.ctx, err)
+	return err
+}
+
+// recordStepError records a step that failed before its command could run
+func (run *jobRun) recordStepError(name, command string, err error) {
+	now := time.Now()
+	run.result.Steps = append(run.result.Steps, StepResult{
+		Name:      name,
+		Phase:     run.phase,
+		Command:   command,
+		Status:    JobStatusFailed,
+		ExitCode:  -1,
+		StartTime: now,
+		EndTime:   now,
+		Error:     err.Error(),
+	})
+}
+
+// statusOf maps the error of a job or step to its final status
+func statusOf(ctx context.Context, err error) JobStatus {
+	var timeoutErr *TimeoutError
+	switch {
+	case err == nil:
+		return JobStatusSucceeded
+	case errors.As(err, &timeoutErr), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return JobStatusTimedOut
+	case ctx.Err() != nil:
+		return JobStatusCancelled
+	default:
+		return JobStatusFailed
+	}
+}
+
+// resolveTemplate resolves Go template variables in a job field
+func (e *Executor) resolveTemplate(field, templateStr string, run *jobRun) (string, error) {
+	engine := templating.New(e.strictTemplates).WithFuncs(template.FuncMap{"output": e.outputFunc(run)})
 	
-	return buf.String(), nil
+	resolved, err := engine.Render(field, rewriteOutputRefs(templateStr), run.context)
+	if err != nil {
+		return "", &templating.FieldError{Job: run.result.JobID, Field: field, Err: err}
+	}
+	
+	return resolved, nil
+}
+
+// stepsField returns the job field holding the steps of a phase
+func stepsField(phase string) string {
+	if phase == StepPhasePost {
+		return "postSteps"
+	}
+	return "preSteps"
 }
 
-// runCommand executes a shell command and streams output
-func (e *Executor) runCommand(cmdStr string) error {
+// runCommand executes a shell command and streams output, recording its exit
+// code and the tail of its output in step. The job's output file is exported
+// as $SP_OUTPUT.
+// The command runs in its own process group; when ctx is cancelled or the
+// timeout elapses the group is signalled and, after the grace period, killed.
+func (e *Executor) runCommand(run *jobRun, cmdStr string, timeout time.Duration, step *StepResult) error {
+	ctx := run.ctx
+	cmdCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		cmdCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	
 	// Use shell to execute command (handles pipes, redirects, etc.)
 	cmd := exec.Command("sh", "-c", cmdStr)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = e.gracePeriod
 	
 	// Set environment variables
 	cmd.Env = os.Environ()
+	if run.outputFile != "" {
+		cmd.Env = append(cmd.Env, OutputEnvVar+"="+run.outputFile)
+	}
 	
-	// Set up output handling
+	// Set up output handling: stream in verbose mode, otherwise capture
+	// but don't display unless there's an error
+	var stdout, stderr bytes.Buffer
+	stdoutTail := &tailBuffer{}
+	stderrTail := &tailBuffer{}
 	if e.verbose {
-		cmd.Stdout = &prefixWriter{prefix: "  │ ", writer: os.Stdout}
-		cmd.Stderr = &prefixWriter{prefix: "  │ ", writer: os.Stderr}
-		
-		// Run the command (verbose mode)
-		if err := cmd.Run(); err != nil {
-			e.logError(fmt.Sprintf("Command failed with exit code %d", cmd.ProcessState.ExitCode()))
-			e.logError(fmt.Sprintf("Command was: %s", cmdStr))
-			return fmt.Errorf("command failed: %w", err)
+		cmd.Stdout = io.MultiWriter(&prefixWriter{prefix: "  │ ", writer: e.stdout}, stdoutTail)
+		cmd.Stderr = io.MultiWriter(&prefixWriter{prefix: "  │ ", writer: e.stderr}, stderrTail)
+	} else {
+		cmd.Stdout = io.MultiWriter(&stdout, stdoutTail)
+		cmd.Stderr = io.MultiWriter(&stderr, stderrTail)
+	}
+	defer func() {
+		step.Stdout = stdoutTail.String()
+		step.Stderr = stderrTail.String()
+	}()
+	
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+	defer runningCommands.add(cmd)()
+	
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	
+	var err error
+	select {
+	case err = <-done:
+	case <-cmdCtx.Done():
+		e.stopCommand(ctx, cmd, done)
+		if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = &TimeoutError{Scope: "command", Timeout: timeout}
+		} else {
+			err = fmt.Errorf("command cancelled: %w", context.Cause(ctx))
 		}
+	}
+	
+	if err == nil {
+		step.ExitCode = 0
+		return nil
+	}
+	
+	// Show detailed error information
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.logError(fmt.Sprintf("Command failed with exit code %d", exitErr.ExitCode()))
 	} else {
-		// Capture but don't display unless there's an error
-		var stdout, stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-		
-		// Run the command
-		if err := cmd.Run(); err != nil {
-			// Show detailed error information
-			e.logError(fmt.Sprintf("Command failed with exit code %d", cmd.ProcessState.ExitCode()))
-			e.logError(fmt.Sprintf("Command was: %s", cmdStr))
-			
-			// Show output on error if not verbose
-			if stderr.Len() > 0 {
-				fmt.Fprintf(os.Stderr, "\n  ┌─ Error Output:\n")
-				for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
-					fmt.Fprintf(os.Stderr, "  │ %s\n", line)
-				}
-				fmt.Fprintf(os.Stderr, "  └─\n")
+		e.logError(fmt.Sprintf("Command failed: %v", err))
+	}
+	e.logError(fmt.Sprintf("Command was: %s", cmdStr))
+	
+	// Show output on error if not verbose
+	if !e.verbose {
+		if stderr.Len() > 0 {
+			fmt.Fprintf(e.stderr, "\n  ┌─ Error Output:\n")
+			for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+				fmt.Fprintf(e.stderr, "  │ %s\n", line)
 			}
-			if stdout.Len() > 0 {
-				fmt.Fprintf(os.Stdout, "\n  ┌─ Standard Output:\n")
-				for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
-					fmt.Fprintf(os.Stdout, "  │ %s\n", line)
-				}
-				fmt.Fprintf(os.Stdout, "  └─\n")
+			fmt.Fprintf(e.stderr, "  └─\n")
+		}
+		if stdout.Len() > 0 {
+			fmt.Fprintf(e.stdout, "\n  ┌─ Standard Output:\n")
+			for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+				fmt.Fprintf(e.stdout, "  │ %s\n", line)
 			}
-			
-			return fmt.Errorf("command failed with exit code %d: %w", cmd.ProcessState.ExitCode(), err)
+			fmt.Fprintf(e.stdout, "  └─\n")
 		}
 	}
 	
-	return nil
+	if exitErr != nil {
+		return &CommandError{Command: cmdStr, ExitCode: exitErr.ExitCode(), Err: err}
+	}
+	return err
+}
+
+// stopCommand signals the command's process group and kills it if it has
+// not exited within the grace period
+func (e *Executor) stopCommand(ctx context.Context, cmd *exec.Cmd, done <-chan error) {
+	sig := terminationSignal(ctx)
+	e.logInfo(fmt.Sprintf("Stopping command (sending %s, grace period %s)", sig, e.gracePeriod))
+	signalProcessGroup(cmd, sig)
+	
+	timer := time.NewTimer(e.gracePeriod)
+	defer timer.Stop()
+	
+	select {
+	case <-done:
+	case <-timer.C:
+		e.logError("Command did not exit within the grace period, killing it")
+		signalProcessGroup(cmd, os.Kill)
+		<-done
+	}
+}
+
+// CommandError is returned when a shell command exits unsuccessfully
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Err      error
+}
+
+func (ce *CommandError) Error() string {
+	return fmt.Sprintf("command failed with exit code %d: %v", ce.ExitCode, ce.Err)
+}
+
+func (ce *CommandError) Unwrap() error {
+	return ce.Err
+}
+
+// exitCodeOf extracts the exit code from a command failure, or -1 if unknown
+func exitCodeOf(err error) int {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.ExitCode
+	}
+	return -1
 }
 
 // Logging helpers
 
 func (e *Executor) logSection(message string) {
-	fmt.Println()
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Printf("  %s\n", message)
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Fprintln(e.stdout)
+	fmt.Fprintln(e.stdout, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Fprintf(e.stdout, "  %s\n", message)
+	fmt.Fprintln(e.stdout, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 }
 
 func (e *Executor) logStep(num int, name string) {
-	fmt.Printf("\n  ▸ Step %d: %s\n", num, name)
+	fmt.Fprintf(e.stdout, "\n  ▸ Step %d: %s\n", num, name)
 }
 
 func (e *Executor) logCommand(cmd string) {
 	if e.verbose {
-		fmt.Printf("  ├─ Command: %s\n", cmd)
-		fmt.Println("  ├─ Output:")
+		fmt.Fprintf(e.stdout, "  ├─ Command: %s\n", cmd)
+		fmt.Fprintln(e.stdout, "  ├─ Output:")
 	}
 }
 
 func (e *Executor) logInfo(message string) {
-	fmt.Printf("  ℹ %s\n", message)
+	fmt.Fprintf(e.stdout, "  ℹ %s\n", message)
 }
 
 func (e *Executor) logSuccess(message string) {
-	fmt.Printf("\n  ✓ %s\n", message)
+	fmt.Fprintf(e.stdout, "\n  ✓ %s\n", message)
 }
 
 func (e *Executor) logError(message string) {
-	fmt.Fprintf(os.Stderr, "\n  ✗ %s\n", message)
+	fmt.Fprintf(e.stderr, "\n  ✗ %s\n", message)
 }
 
 // prefixWriter adds a prefix to each line of output
 type prefixWriter struct {
 	prefix string
-	writer *os.File
+	writer io.Writer
 }
 
 func (pw *prefixWriter) Write(p []byte) (n int, err error) {
@@ -341,6 +688,34 @@ func (pw *prefixWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// outputTailLines is the number of trailing output lines kept in step results
+const outputTailLines = 20
+
+// maxTailBytes bounds the memory used to capture a command's output tail
+const maxTailBytes = 64 * 1024
+
+// tailBuffer keeps the last lines written to it
+type tailBuffer struct {
+	buf []byte
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.buf = append(tb.buf, p...)
+	if len(tb.buf) > maxTailBytes {
+		tb.buf = tb.buf[len(tb.buf)-maxTailBytes:]
+	}
+	return len(p), nil
+}
+
+// String returns at most outputTailLines trailing lines
+func (tb *tailBuffer) String() string {
+	lines := strings.Split(strings.TrimRight(string(tb.buf), "\n"), "\n")
+	if len(lines) > outputTailLines {
+		lines = lines[len(lines)-outputTailLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Helper function to get string from map
 func getString(m map[string]interface{}, key string) string {
 	if v, ok := m[key].(string); ok {
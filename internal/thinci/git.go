@@ -0,0 +1,199 @@
+package thinci
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiffOptions controls how changed files are computed from git
+type DiffOptions struct {
+	BaseRef string
+	HeadRef string
+
+	// IncludeUncommitted adds staged, unstaged and untracked files from the
+	// working tree on top of the committed diff
+	IncludeUncommitted bool
+}
+
+// GetChangedFiles returns the files changed between the merge-base of
+// BaseRef/HeadRef and HeadRef (equivalent to `git diff base...head`).
+// Renamed files are reported under both their old and new paths and deleted
+// files are included. Paths are relative to the git repository root, sorted
+// and de-duplicated.
+func GetChangedFiles(repoPath string, opts DiffOptions) ([]string, error) {
+	if opts.HeadRef == "" {
+		opts.HeadRef = "HEAD"
+	}
+	if opts.BaseRef == "" {
+		return nil, fmt.Errorf("base ref is required")
+	}
+
+	root, err := repositoryRoot(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeBase, err := mergeBase(repoPath, opts.BaseRef, opts.HeadRef)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]bool)
+
+	out, err := runGit(repoPath, "diff", "--name-status", "-z", "-M", mergeBase, opts.HeadRef)
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s..%s failed: %w", mergeBase, opts.HeadRef, err)
+	}
+	for _, f := range parseNameStatus(out) {
+		files[f] = true
+	}
+
+	if opts.IncludeUncommitted {
+		// Staged and unstaged changes relative to HEAD
+		out, err := runGit(repoPath, "diff", "--name-status", "-z", "-M", "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("git diff HEAD failed: %w", err)
+		}
+		for _, f := range parseNameStatus(out) {
+			files[f] = true
+		}
+
+		// Untracked files that are not ignored; ls-files only lists files
+		// below the directory it runs in
+		out, err = runGit(root, "ls-files", "--others", "--exclude-standard", "-z", "--full-name")
+		if err != nil {
+			return nil, fmt.Errorf("git ls-files failed: %w", err)
+		}
+		for _, f := range strings.Split(out, "\x00") {
+			if f != "" {
+				files[f] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(files))
+	for f := range files {
+		result = append(result, f)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// mergeBase returns the commit that changes between baseRef and headRef are
+// computed from
+func mergeBase(repoPath, baseRef, headRef string) (string, error) {
+	out, err := runGit(repoPath, "merge-base", baseRef, headRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge-base of %s and %s: %w", baseRef, headRef, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// repositoryRoot returns the top-level directory of the git repository
+func repositoryRoot(repoPath string) (string, error) {
+	out, err := runGit(repoPath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("%s is not a git repository: %w", repoPath, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// RelativeToRepository returns a path relative to the root of the git
+// repository containing repoPath, slash-separated, e.g. the intent directory
+// that changed files have to be matched against
+func RelativeToRepository(repoPath, p string) (string, error) {
+	root, err := repositoryRoot(repoPath)
+	if err != nil {
+		return "", err
+	}
+	return relativeTo(root, p)
+}
+
+// relativeTo returns p relative to root, which has its symlinks resolved
+func relativeTo(root, p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return "", fmt.Errorf("%s is outside the git repository %s", p, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// readFileAtRevision returns the content of a file at a revision; path is
+// relative to the repository root. It returns nil when the file does not
+// exist at that revision.
+func readFileAtRevision(repoPath, revision, path string) ([]byte, error) {
+	out, err := runGit(repoPath, "ls-tree", "--full-tree", "--name-only", revision, "--", path)
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree %s failed: %w", revision, err)
+	}
+	if strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+
+	data, err := runGit(repoPath, "show", revision+":"+path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, revision, err)
+	}
+	return []byte(data), nil
+}
+
+// parseNameStatus parses `git diff --name-status -z` output.
+// Records are NUL separated: "<status>\0<path>\0" for most changes and
+// "<status>\0<old>\0<new>\0" for renames (R) and copies (C).
+func parseNameStatus(out string) []string {
+	var files []string
+
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if status == "" {
+			continue
+		}
+
+		switch status[0] {
+		case 'R', 'C':
+			if i+2 < len(fields) {
+				files = append(files, fields[i+1], fields[i+2])
+			}
+			i += 2
+		default:
+			if i+1 < len(fields) {
+				files = append(files, fields[i+1])
+			}
+			i++
+		}
+	}
+
+	return files
+}
+
+// runGit runs a git command in the given directory and returns its stdout
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
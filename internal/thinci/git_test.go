@@ -0,0 +1,145 @@
+package thinci
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newFixtureRepo creates a git repository with an initial commit on main
+func newFixtureRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	writeFile(t, dir, "svc/charts/db/values.yaml", "replicas: 1\n")
+	writeFile(t, dir, "svc/charts/api/values.yaml", "replicas: 1\n")
+	writeFile(t, dir, "docs/old.md", "old\n")
+	writeFile(t, dir, "README.md", "readme\n")
+	commitAll(t, dir, "initial")
+	return dir
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commitAll(t *testing.T, dir, message string) {
+	t.Helper()
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", message)
+}
+
+func TestGetChangedFilesFromMergeBase(t *testing.T) {
+	dir := newFixtureRepo(t)
+	git(t, dir, "checkout", "-q", "-b", "feature")
+	writeFile(t, dir, "svc/charts/db/values.yaml", "replicas: 2\n")
+	commitAll(t, dir, "feature change")
+
+	// A later change on main is not part of the feature branch's diff
+	git(t, dir, "checkout", "-q", "main")
+	writeFile(t, dir, "svc/charts/api/values.yaml", "replicas: 3\n")
+	commitAll(t, dir, "main change")
+	git(t, dir, "checkout", "-q", "feature")
+
+	files, err := GetChangedFiles(dir, DiffOptions{BaseRef: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"svc/charts/db/values.yaml"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("changed files = %v, want %v", files, want)
+	}
+}
+
+func TestGetChangedFilesRenamesAndDeletions(t *testing.T) {
+	dir := newFixtureRepo(t)
+	git(t, dir, "checkout", "-q", "-b", "feature")
+	git(t, dir, "mv", "docs/old.md", "docs/new.md")
+	git(t, dir, "rm", "-q", "README.md")
+	commitAll(t, dir, "rename and delete")
+
+	files, err := GetChangedFiles(dir, DiffOptions{BaseRef: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"README.md", "docs/new.md", "docs/old.md"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("changed files = %v, want %v", files, want)
+	}
+}
+
+func TestGetChangedFilesUncommitted(t *testing.T) {
+	dir := newFixtureRepo(t)
+	writeFile(t, dir, "svc/charts/db/values.yaml", "replicas: 2\n") // unstaged
+	writeFile(t, dir, "svc/charts/api/values.yaml", "replicas: 2\n")
+	git(t, dir, "add", "svc/charts/api/values.yaml") // staged
+	writeFile(t, dir, "docs/untracked.md", "new\n")  // untracked, outside the working directory
+	writeFile(t, dir, "svc/untracked.yaml", "new\n")
+
+	// Run from a subdirectory, like an intent directory
+	subdir := filepath.Join(dir, "svc")
+
+	files, err := GetChangedFiles(subdir, DiffOptions{BaseRef: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("without IncludeUncommitted changed files = %v, want none", files)
+	}
+
+	files, err = GetChangedFiles(subdir, DiffOptions{BaseRef: "main", IncludeUncommitted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"docs/untracked.md",
+		"svc/charts/api/values.yaml",
+		"svc/charts/db/values.yaml",
+		"svc/untracked.yaml",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("changed files = %v, want %v", files, want)
+	}
+}
+
+func TestRelativeToRepository(t *testing.T) {
+	dir := newFixtureRepo(t)
+
+	for path, want := range map[string]string{
+		dir:                              ".",
+		filepath.Join(dir, "svc"):        "svc",
+		filepath.Join(dir, "svc/charts"): "svc/charts",
+	} {
+		got, err := RelativeToRepository(dir, path)
+		if err != nil {
+			t.Fatalf("RelativeToRepository(%s): %v", path, err)
+		}
+		if got != want {
+			t.Errorf("RelativeToRepository(%s) = %q, want %q", path, got, want)
+		}
+	}
+
+	if _, err := RelativeToRepository(dir, t.TempDir()); err == nil {
+		t.Error("expected an error for a path outside the repository")
+	}
+}
@@ -0,0 +1,177 @@
+package thinci
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sourceplane/sourceplane/internal/models"
+)
+
+// expandImpact adds the components related to the changed ones through the
+// relationship graph: with includeDependents everything that depends on a
+// changed component, directly or transitively, and with includeDependencies
+// everything a changed component depends on. Each added component's reason
+// names the component that pulled it in and its ImpactPath leads back to
+// the changed component.
+func (p *Planner) expandImpact(
+	changes []ComponentChange,
+	intents []*models.Repository,
+	includeDependents, includeDependencies bool,
+) []ComponentChange {
+	if !includeDependents && !includeDependencies {
+		return changes
+	}
+
+	components, dependencies, dependents := p.relationshipGraph(intents)
+
+	result := append([]ComponentChange(nil), changes...)
+	planned := make(map[string]bool)
+	for _, change := range changes {
+		planned[change.ComponentName] = true
+	}
+
+	expand := func(edges map[string][]string, describe func(neighbor string, path []string) string) {
+		// Breadth-first from the changed components, in name order, so the
+		// shortest path to a changed component is recorded
+		queue := make([][]string, 0, len(changes))
+		for _, change := range changes {
+			queue = append(queue, []string{change.ComponentName})
+		}
+		sort.Slice(queue, func(i, j int) bool { return queue[i][0] < queue[j][0] })
+
+		visited := make(map[string]bool)
+		for len(queue) > 0 {
+			path := queue[0]
+			queue = queue[1:]
+			current := path[len(path)-1]
+
+			neighbors := append([]string(nil), edges[current]...)
+			sort.Strings(neighbors)
+			for _, neighbor := range neighbors {
+				if visited[neighbor] || planned[neighbor] {
+					continue
+				}
+				visited[neighbor] = true
+				next := append(append([]string(nil), path...), neighbor)
+				queue = append(queue, next)
+
+				component := components[neighbor]
+				result = append(result, ComponentChange{
+					ComponentName: neighbor,
+					Provider:      extractProvider(component.Type),
+					ComponentType: component.Type,
+					Reason:        describe(current, path),
+					ImpactPath:    next,
+				})
+			}
+		}
+		for name := range visited {
+			planned[name] = true
+		}
+	}
+
+	if includeDependents {
+		expand(dependents, func(dependency string, path []string) string {
+			if len(path) == 1 {
+				return fmt.Sprintf("Depends on changed component %s", dependency)
+			}
+			return fmt.Sprintf("Depends on %s, which depends on changed component %s", dependency, path[0])
+		})
+	}
+	if includeDependencies {
+		expand(dependencies, func(dependent string, path []string) string {
+			if len(path) == 1 {
+				return fmt.Sprintf("Dependency of changed component %s", dependent)
+			}
+			return fmt.Sprintf("Dependency of %s, which changed component %s depends on", dependent, path[0])
+		})
+	}
+
+	return result
+}
+
+// relationshipGraph indexes every component in the intents by name, with
+// the components each one depends on and the components depending on it
+func (p *Planner) relationshipGraph(intents []*models.Repository) (
+	components map[string]*models.Component,
+	dependencies, dependents map[string][]string,
+) {
+	components = make(map[string]*models.Component)
+	for _, intent := range intents {
+		for i := range intent.Components {
+			component := &intent.Components[i]
+			if _, ok := components[component.Name]; !ok {
+				components[component.Name] = component
+			}
+		}
+	}
+
+	dependencies = make(map[string][]string)
+	dependents = make(map[string][]string)
+	for name, component := range components {
+		for _, dep := range p.extractDependencies(component, intents) {
+			if _, ok := components[dep]; ok && dep != name {
+				dependencies[name] = append(dependencies[name], dep)
+				dependents[dep] = append(dependents[dep], name)
+			}
+		}
+	}
+	return components, dependencies, dependents
+}
+
+// Explain describes why a component is or is not part of the plan for a
+// request: the change detection rules evaluated for it, the changed files
+// they matched and the relationships that pulled it in
+func (p *Planner) Explain(req PlanRequest, intents []*models.Repository, componentName string) (*Explanation, error) {
+	components, dependencies, dependents := p.relationshipGraph(intents)
+	component, ok := components[componentName]
+	if !ok {
+		names := make([]string, 0, len(components))
+		for name := range components {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("component '%s' not found in intent (available: %s)", componentName, strings.Join(names, ", "))
+	}
+
+	detector := NewChangeDetector(req.RepositoryPath, intents, p.providerRegistry).
+		WithIntentSources(req.IntentSources, req.Environment).
+		WithBaseDir(req.IntentDir)
+	changes, err := detector.DetectChanges(req.ChangedFiles)
+	if err != nil {
+		return nil, fmt.Errorf("change detection failed: %w", err)
+	}
+	changes = p.expandImpact(changes, intents, req.IncludeDependents, req.IncludeDependencies)
+
+	rules, err := detector.Evaluate(componentName, req.ChangedFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &Explanation{
+		Component:    componentName,
+		Type:         component.Type,
+		Provider:     extractProvider(component.Type),
+		Rules:        rules,
+		Dependencies: sortedCopy(dependencies[componentName]),
+		Dependents:   sortedCopy(dependents[componentName]),
+		ChangedFiles: req.ChangedFiles,
+	}
+	for _, change := range changes {
+		if change.ComponentName == componentName {
+			explanation.Planned = true
+			explanation.Reason = change.Reason
+			explanation.AffectedPaths = change.AffectedPaths
+			explanation.ImpactPath = change.ImpactPath
+			break
+		}
+	}
+	return explanation, nil
+}
+
+func sortedCopy(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,162 @@
+package thinci
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourceplane/sourceplane/internal/models"
+)
+
+// impactIntent relates its components as
+//
+//	web -> api -> db
+//	web -> auth -> db
+//	worker -> queue
+//	admin -> web
+//
+// where a -> b means a depends on b; docs is unrelated
+const impactIntent = `apiVersion: sourceplane.io/v1
+kind: Intent
+metadata:
+  name: shop
+components:
+  - name: db
+    type: helm.database
+  - name: api
+    type: helm.service
+  - name: auth
+    type: helm.service
+    spec:
+      relationships:
+        - target: db
+          type: depends_on
+  - name: web
+    type: helm.service
+  - name: admin
+    type: helm.service
+  - name: worker
+    type: helm.service
+  - name: queue
+    type: helm.service
+  - name: docs
+    type: helm.service
+relationships:
+  - from: api
+    to: db
+    type: depends_on
+  - from: web
+    to: api
+    type: depends_on
+  - from: web
+    to: auth
+    type: uses
+  - from: admin
+    to: web
+    type: depends_on
+  - from: worker
+    to: queue
+    type: depends_on
+`
+
+func TestExpandImpact(t *testing.T) {
+	intents := []*models.Repository{parseDiffIntent(t, impactIntent)}
+
+	type planned struct {
+		reason string
+		path   string
+	}
+	tests := []struct {
+		name         string
+		changed      []string
+		dependents   bool
+		dependencies bool
+		want         map[string]planned // components added to the changed ones
+	}{
+		{name: "no expansion", changed: []string{"db"}},
+		{
+			name:       "transitive dependents",
+			changed:    []string{"db"},
+			dependents: true,
+			want: map[string]planned{
+				"api":   {"Depends on changed component db", "db > api"},
+				"auth":  {"Depends on changed component db", "db > auth"},
+				"web":   {"Depends on api, which depends on changed component db", "db > api > web"},
+				"admin": {"Depends on web, which depends on changed component db", "db > api > web > admin"},
+			},
+		},
+		{
+			name:       "dependents of a leaf",
+			changed:    []string{"admin"},
+			dependents: true,
+		},
+		{
+			name:       "changed components are not added again",
+			changed:    []string{"db", "web"},
+			dependents: true,
+			want: map[string]planned{
+				"api":   {"Depends on changed component db", "db > api"},
+				"auth":  {"Depends on changed component db", "db > auth"},
+				"admin": {"Depends on changed component web", "web > admin"},
+			},
+		},
+		{
+			name:         "transitive dependencies",
+			changed:      []string{"admin"},
+			dependencies: true,
+			want: map[string]planned{
+				"web":  {"Dependency of changed component admin", "admin > web"},
+				"api":  {"Dependency of web, which changed component admin depends on", "admin > web > api"},
+				"auth": {"Dependency of web, which changed component admin depends on", "admin > web > auth"},
+				"db":   {"Dependency of api, which changed component admin depends on", "admin > web > api > db"},
+			},
+		},
+		{
+			name:         "dependents and dependencies",
+			changed:      []string{"api"},
+			dependents:   true,
+			dependencies: true,
+			want: map[string]planned{
+				"web":   {"Depends on changed component api", "api > web"},
+				"admin": {"Depends on web, which depends on changed component api", "api > web > admin"},
+				"db":    {"Dependency of changed component api", "api > db"},
+			},
+		},
+		{
+			name:       "unrelated component",
+			changed:    []string{"docs"},
+			dependents: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []ComponentChange
+			for _, name := range tt.changed {
+				changes = append(changes, ComponentChange{ComponentName: name, Reason: "Files changed"})
+			}
+
+			result := (&Planner{}).expandImpact(changes, intents, tt.dependents, tt.dependencies)
+
+			if !reflect.DeepEqual(result[:len(changes)], changes) {
+				t.Errorf("changed components = %+v, want them first and unchanged", result[:len(changes)])
+			}
+			got := make(map[string]planned)
+			for _, change := range result[len(changes):] {
+				if _, exists := got[change.ComponentName]; exists {
+					t.Errorf("%s added twice", change.ComponentName)
+				}
+				if change.Provider != "helm" || change.ComponentType == "" {
+					t.Errorf("%s has provider %q and type %q, want them from the intent", change.ComponentName, change.Provider, change.ComponentType)
+				}
+				got[change.ComponentName] = planned{change.Reason, strings.Join(change.ImpactPath, " > ")}
+			}
+			if len(got) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("added components = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package thinci
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourceplane/sourceplane/internal/providers"
+	"github.com/sourceplane/sourceplane/internal/templating"
+)
+
+// Input types a provider may declare
+const (
+	InputTypeString  = providers.InputTypeString
+	InputTypeNumber  = providers.InputTypeNumber
+	InputTypeInteger = providers.InputTypeInteger
+	InputTypeBoolean = providers.InputTypeBoolean
+	InputTypeObject  = providers.InputTypeObject
+	InputTypeArray   = providers.InputTypeArray
+)
+
+// InputSpec declares a typed job input; see providers.InputSpec
+type InputSpec = providers.InputSpec
+
+// ParseInputSpecs converts a decoded `inputs` declaration into input specs
+func ParseInputSpecs(raw any) (map[string]InputSpec, error) {
+	specs := make(map[string]InputSpec)
+
+	switch v := raw.(type) {
+	case nil:
+		return specs, nil
+	case map[string]InputSpec:
+		for name, spec := range v {
+			specs[name] = spec
+		}
+		return specs, nil
+	case map[string]any:
+		for name, item := range v {
+			m, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("input %s: expected a mapping with type, default and required", name)
+			}
+
+			spec := InputSpec{
+				Type:        getString(m, "type"),
+				Default:     m["default"],
+				Required:    toBool(m["required"]),
+				From:        getString(m, "from"),
+				Description: getString(m, "description"),
+			}
+			if err := spec.Validate(); err != nil {
+				return nil, fmt.Errorf("input %s: %w", name, err)
+			}
+			specs[name] = spec
+		}
+		return specs, nil
+	default:
+		return nil, fmt.Errorf("inputs must be a mapping of input names to declarations")
+	}
+}
+
+// applyInputDefaults fills in declared defaults for inputs that are not set.
+// String defaults are rendered against the job's core fields.
+func applyInputDefaults(specs map[string]InputSpec, inputs map[string]any, job Job) error {
+	engine := templating.New(false)
+	data := templateData(job, inputs)
+
+	for _, name := range sortedSpecNames(specs) {
+		spec := specs[name]
+		if _, exists := inputs[name]; exists || spec.Default == nil {
+			continue
+		}
+
+		value := spec.Default
+		if s, ok := value.(string); ok {
+			rendered, err := engine.Render("inputs."+name+".default", s, data)
+			if err != nil {
+				return fmt.Errorf("input %s: %w", name, err)
+			}
+			value = rendered
+		}
+		inputs[name] = copyValue(value)
+	}
+	return nil
+}
+
+// ValidateInputs checks that required inputs are set and that inputs match
+// their declared types. All problems are reported together.
+func ValidateInputs(specs map[string]InputSpec, inputs map[string]any) error {
+	var problems []string
+	for _, name := range sortedSpecNames(specs) {
+		spec := specs[name]
+		value, exists := inputs[name]
+		if !exists || value == nil || value == "" {
+			if spec.Required {
+				problems = append(problems, fmt.Sprintf("input %s is required", name))
+			}
+			continue
+		}
+		if err := spec.Check(value); err != nil {
+			problems = append(problems, fmt.Sprintf("input %s %v", name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func sortedSpecNames(specs map[string]InputSpec) []string {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inputLayer is one source of job inputs, lowest precedence first
+type inputLayer struct {
+	name   string
+	values map[string]any
+}
+
+// mergeInputLayers deep-merges input layers in order, so later layers win,
+// and returns the name of the layer that last set each top-level input.
+// Inputs declared with `from` are populated from that path within each layer,
+// letting a component spec's `chart.path` set `chartPath` at the spec's precedence.
+func mergeInputLayers(layers []inputLayer, specs map[string]InputSpec) (map[string]any, map[string]string) {
+	merged := make(map[string]any)
+	sources := make(map[string]string)
+	for _, layer := range layers {
+		if len(layer.values) == 0 {
+			continue
+		}
+		values := copyValue(layer.values).(map[string]any)
+		for _, name := range sortedSpecNames(specs) {
+			from := specs[name].From
+			if from == "" {
+				continue
+			}
+			if _, exists := values[name]; exists {
+				continue
+			}
+			if v, ok := lookupPath(values, from); ok {
+				values[name] = v
+			}
+		}
+		mergeInputs(merged, values)
+		for k := range values {
+			sources[k] = layer.name
+		}
+	}
+	return merged, sources
+}
+
+// mergeInputs deep-merges a copy of src into dst; nested maps are merged,
+// other values replaced
+func mergeInputs(dst, src map[string]any) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			mergeInputs(dstMap, srcMap)
+			continue
+		}
+		dst[k] = copyValue(v)
+	}
+}
+
+// lookupPath returns the value at a dotted path such as "chart.path"
+func lookupPath(m map[string]any, path string) (any, bool) {
+	parts := strings.Split(path, ".")
+	var current any = m
+	for _, part := range parts {
+		node, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = node[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setPath sets the value at a dotted path, creating intermediate maps
+func setPath(m map[string]any, path string, value any) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// SetValues holds `--set` overrides, applied to every job or to one component
+type SetValues struct {
+	Global      map[string]any
+	ByComponent map[string]map[string]any
+}
+
+// ParseSetValues parses `--set` arguments of the form `path=value` or
+// `component:path=value`. Values are parsed as YAML scalars or flow
+// collections, so `replicas=3` sets a number and `tags=[a,b]` a list.
+func ParseSetValues(args []string) (*SetValues, error) {
+	values := &SetValues{
+		Global:      make(map[string]any),
+		ByComponent: make(map[string]map[string]any),
+	}
+
+	for _, arg := range args {
+		path, raw, ok := strings.Cut(arg, "=")
+		if !ok || strings.TrimSpace(path) == "" {
+			return nil, fmt.Errorf("invalid --set %q: expected path=value", arg)
+		}
+
+		target := values.Global
+		if component, rest, scoped := strings.Cut(path, ":"); scoped {
+			if component == "" || rest == "" {
+				return nil, fmt.Errorf("invalid --set %q: expected component:path=value", arg)
+			}
+			if values.ByComponent[component] == nil {
+				values.ByComponent[component] = make(map[string]any)
+			}
+			target = values.ByComponent[component]
+			path = rest
+		}
+
+		for _, part := range strings.Split(path, ".") {
+			if part == "" {
+				return nil, fmt.Errorf("invalid --set %q: empty path segment", arg)
+			}
+		}
+
+		setPath(target, path, parseSetValue(raw))
+	}
+
+	return values, nil
+}
+
+// parseSetValue interprets a --set value as YAML, falling back to the raw string
+func parseSetValue(raw string) any {
+	var value any
+	if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+		return raw
+	}
+	if m, ok := value.(map[string]any); ok {
+		return m
+	}
+	switch value.(type) {
+	case string, bool, int, float64, []any:
+		return value
+	}
+	return raw
+}
@@ -0,0 +1,196 @@
+package thinci
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInputSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     any
+		want    map[string]InputSpec
+		wantErr string
+	}{
+		{name: "none", raw: nil, want: map[string]InputSpec{}},
+		{
+			name: "declarations",
+			raw: map[string]any{
+				"chartPath": map[string]any{"type": "string", "from": "chart.path", "default": ".", "required": true},
+				"replicas":  map[string]any{"type": "integer", "default": 1},
+			},
+			want: map[string]InputSpec{
+				"chartPath": {Type: InputTypeString, From: "chart.path", Default: ".", Required: true},
+				"replicas":  {Type: InputTypeInteger, Default: 1},
+			},
+		},
+		{
+			name:    "unknown type",
+			raw:     map[string]any{"replicas": map[string]any{"type": "int"}},
+			wantErr: `input replicas: unknown type "int"`,
+		},
+		{
+			name:    "default of the wrong type",
+			raw:     map[string]any{"replicas": map[string]any{"type": "integer", "default": "three"}},
+			wantErr: "input replicas: default must be of type integer, got string",
+		},
+		{
+			name:    "declaration is not a mapping",
+			raw:     map[string]any{"replicas": "integer"},
+			wantErr: "input replicas: expected a mapping",
+		},
+		{name: "not a mapping", raw: []any{"replicas"}, wantErr: "inputs must be a mapping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInputSpecs(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseInputSpecs() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseInputSpecs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyInputDefaults(t *testing.T) {
+	specs := map[string]InputSpec{
+		"releaseName": {Type: InputTypeString, Default: "{{.component}}-{{.action}}"},
+		"namespace":   {Type: InputTypeString, Default: "default"},
+		"replicas":    {Type: InputTypeInteger, Default: 1},
+		"values":      {Type: InputTypeObject, Default: map[string]any{"debug": false}},
+		"timeout":     {Type: InputTypeString},
+	}
+	job := Job{"id": "api-deploy", "component": "api", "action": "deploy"}
+
+	tests := []struct {
+		name   string
+		inputs map[string]any
+		want   map[string]any
+	}{
+		{
+			name:   "defaults applied",
+			inputs: map[string]any{},
+			want: map[string]any{
+				"releaseName": "api-deploy",
+				"namespace":   "default",
+				"replicas":    1,
+				"values":      map[string]any{"debug": false},
+			},
+		},
+		{
+			name:   "set inputs are kept",
+			inputs: map[string]any{"namespace": "web", "replicas": 3, "releaseName": ""},
+			want: map[string]any{
+				"releaseName": "",
+				"namespace":   "web",
+				"replicas":    3,
+				"values":      map[string]any{"debug": false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := applyInputDefaults(specs, tt.inputs, job); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.inputs, tt.want) {
+				t.Errorf("inputs = %v, want %v", tt.inputs, tt.want)
+			}
+		})
+	}
+
+	// Defaults are copied into the inputs, not shared with the declaration
+	inputs := map[string]any{}
+	if err := applyInputDefaults(specs, inputs, job); err != nil {
+		t.Fatal(err)
+	}
+	inputs["values"].(map[string]any)["debug"] = true
+	if specs["values"].Default.(map[string]any)["debug"] != false {
+		t.Error("applyInputDefaults() shared the default with the inputs")
+	}
+
+	bad := map[string]InputSpec{"releaseName": {Type: InputTypeString, Default: "{{.component"}}
+	if err := applyInputDefaults(bad, map[string]any{}, job); err == nil || !strings.HasPrefix(err.Error(), "input releaseName: ") {
+		t.Errorf("applyInputDefaults() error = %v, want an error naming the input", err)
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	specs := map[string]InputSpec{
+		"chartPath": {Type: InputTypeString, Required: true},
+		"replicas":  {Type: InputTypeInteger},
+		"ratio":     {Type: InputTypeNumber},
+		"enabled":   {Type: InputTypeBoolean},
+		"values":    {Type: InputTypeObject},
+		"tags":      {Type: InputTypeArray},
+		"extra":     {},
+	}
+
+	tests := []struct {
+		name    string
+		inputs  map[string]any
+		wantErr string
+	}{
+		{name: "valid", inputs: map[string]any{
+			"chartPath": "charts/api",
+			"replicas":  3,
+			"ratio":     0.5,
+			"enabled":   true,
+			"values":    map[string]any{"debug": true},
+			"tags":      []any{"a"},
+			"extra":     42,
+		}},
+		{name: "integral float is an integer", inputs: map[string]any{"chartPath": ".", "replicas": float64(3)}},
+		{name: "string list is an array", inputs: map[string]any{"chartPath": ".", "tags": []string{"a"}}},
+		{name: "optional inputs unset", inputs: map[string]any{"chartPath": "."}},
+
+		// Missing required input
+		{name: "missing required input", inputs: map[string]any{}, wantErr: "input chartPath is required"},
+		{name: "empty required input", inputs: map[string]any{"chartPath": ""}, wantErr: "input chartPath is required"},
+		{name: "nil required input", inputs: map[string]any{"chartPath": nil}, wantErr: "input chartPath is required"},
+
+		// Wrong types
+		{name: "string", inputs: map[string]any{"chartPath": 1}, wantErr: "input chartPath must be of type string, got int"},
+		{name: "integer", inputs: map[string]any{"chartPath": ".", "replicas": "3"}, wantErr: "input replicas must be of type integer, got string"},
+		{name: "fractional integer", inputs: map[string]any{"chartPath": ".", "replicas": 2.5}, wantErr: "input replicas must be of type integer, got float64"},
+		{name: "number", inputs: map[string]any{"chartPath": ".", "ratio": true}, wantErr: "input ratio must be of type number, got bool"},
+		{name: "boolean", inputs: map[string]any{"chartPath": ".", "enabled": "true"}, wantErr: "input enabled must be of type boolean, got string"},
+		{name: "object", inputs: map[string]any{"chartPath": ".", "values": []any{}}, wantErr: "input values must be of type object, got []interface {}"},
+		{name: "array", inputs: map[string]any{"chartPath": ".", "tags": "a,b"}, wantErr: "input tags must be of type array, got string"},
+
+		// Inputs without a declaration come from component specs and are not checked
+		{name: "unknown input", inputs: map[string]any{"chartPath": ".", "image": map[string]any{"tag": "v1"}}},
+
+		{
+			name:    "all problems reported",
+			inputs:  map[string]any{"replicas": "3", "enabled": 1},
+			wantErr: "input chartPath is required; input enabled must be of type boolean, got int; input replicas must be of type integer, got string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInputs(specs, tt.inputs)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateInputs() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ValidateInputs() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
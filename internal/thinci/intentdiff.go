@@ -0,0 +1,223 @@
+package thinci
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sourceplane/sourceplane/internal/models"
+	"github.com/sourceplane/sourceplane/internal/parser"
+	"github.com/sourceplane/sourceplane/internal/providers"
+)
+
+// IntentSource ties an intent to its file and to its state at the base of
+// the diff, so that a change to the file only affects the components whose
+// definition changed
+type IntentSource struct {
+	// Path is the intent file, relative to the git repository root
+	Path string
+
+	// Base is the intent at the merge-base; nil when the file did not exist
+	Base *models.Repository
+
+	// BaseLock and HeadLock are the intent.lock next to the intent file at
+	// the merge-base and in the working tree; nil when there is none
+	BaseLock *providers.LockFile
+	HeadLock *providers.LockFile
+}
+
+// LockPath is the intent.lock next to the intent file, relative to the git
+// repository root
+func (s IntentSource) LockPath() string {
+	return path.Join(path.Dir(s.Path), providers.LockFileName)
+}
+
+// LoadIntentSources reads each intent file and its lock file as they were at
+// the merge-base of BaseRef and HeadRef. The result is parallel to
+// intentFiles.
+func LoadIntentSources(repoPath string, intentFiles []string, opts DiffOptions) ([]IntentSource, error) {
+	if opts.HeadRef == "" {
+		opts.HeadRef = "HEAD"
+	}
+	root, err := repositoryRoot(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	base, err := mergeBase(repoPath, opts.BaseRef, opts.HeadRef)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]IntentSource, 0, len(intentFiles))
+	for _, file := range intentFiles {
+		rel, err := relativeTo(root, file)
+		if err != nil {
+			return nil, err
+		}
+		source := IntentSource{Path: rel}
+
+		data, err := readFileAtRevision(repoPath, base, source.Path)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			source.Base, _, err = parser.ParseRepositoryData(source.Path+"@"+shortRevision(base), data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the base intent: %w", err)
+			}
+		}
+
+		data, err = readFileAtRevision(repoPath, base, source.LockPath())
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			if source.BaseLock, err = providers.ParseLockFile(source.LockPath()+"@"+shortRevision(base), data); err != nil {
+				return nil, err
+			}
+		}
+		if source.HeadLock, err = providers.LoadLockFile(filepath.Dir(file)); err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
+
+// componentDiff lists what changed in a component's definition between the
+// base and head intent, naming each changed field, e.g.
+// "spec.values.replicas" or "providers.helm.version". added is set when the
+// component is not in the base intent.
+func componentDiff(source *IntentSource, head *models.Repository, component models.Component, environment string) (fields []string, added bool) {
+	if source.Base == nil {
+		return nil, true
+	}
+	var base *models.Component
+	for i := range source.Base.Components {
+		if source.Base.Components[i].Name == component.Name {
+			base = &source.Base.Components[i]
+			break
+		}
+	}
+	if base == nil {
+		return nil, true
+	}
+
+	if base.Type != component.Type {
+		fields = append(fields, "type")
+	}
+	diffValues("spec", base.Spec, component.Spec, &fields)
+	diffValues("inputs", base.Inputs, component.Inputs, &fields)
+	if !reflect.DeepEqual(base.Paths, component.Paths) {
+		fields = append(fields, "paths")
+	}
+	if !reflect.DeepEqual(base.IgnorePaths, component.IgnorePaths) {
+		fields = append(fields, "ignorePaths")
+	}
+	if !reflect.DeepEqual(relationshipsFrom(source.Base, component.Name), relationshipsFrom(head, component.Name)) {
+		fields = append(fields, "relationships")
+	}
+
+	// The provider configuration the component is planned with
+	provider := extractProvider(component.Type)
+	baseConfig, headConfig := source.Base.Providers[provider], head.Providers[provider]
+	prefix := "providers." + provider
+	if baseConfig.Source != headConfig.Source {
+		fields = append(fields, prefix+".source")
+	}
+	if baseConfig.Version != headConfig.Version {
+		fields = append(fields, prefix+".version")
+	}
+	kind := strings.TrimPrefix(component.Type, provider+".")
+	diffValues(prefix+".defaults."+kind, kindDefaults(baseConfig.Defaults, component.Type), kindDefaults(headConfig.Defaults, component.Type), &fields)
+
+	// Overlays of the planned environment
+	if environment != "" {
+		baseEnv, headEnv := source.Base.Environments[environment], head.Environments[environment]
+		envPrefix := "environments." + environment
+		diffValues(envPrefix+".components."+component.Name+".spec",
+			baseEnv.Components[component.Name].Spec, headEnv.Components[component.Name].Spec, &fields)
+		diffValues(envPrefix+".providers."+provider+".defaults."+kind,
+			kindDefaults(baseEnv.Providers[provider].Defaults, component.Type),
+			kindDefaults(headEnv.Providers[provider].Defaults, component.Type), &fields)
+	}
+
+	return fields, false
+}
+
+// lockDiff describes how the locked version of a provider changed, or ""
+// when it did not
+func lockDiff(source *IntentSource, provider string) string {
+	var base, head providers.LockedProvider
+	if source.BaseLock != nil {
+		base = source.BaseLock.Providers[provider]
+	}
+	if source.HeadLock != nil {
+		head = source.HeadLock.Providers[provider]
+	}
+
+	switch {
+	case base == head:
+		return ""
+	case base.Version != head.Version && base.Version != "" && head.Version != "":
+		return fmt.Sprintf("Provider %s version changed from %s to %s", provider, base.Version, head.Version)
+	case base.Version != head.Version && head.Version != "":
+		return fmt.Sprintf("Provider %s locked at %s", provider, head.Version)
+	case base.Hash != head.Hash:
+		return fmt.Sprintf("Provider %s content changed", provider)
+	}
+	return ""
+}
+
+// diffValues appends the dotted paths at which two decoded YAML values
+// differ. Maps are compared key by key; anything else as a whole.
+func diffValues(prefix string, base, head any, fields *[]string) {
+	baseMap, baseIsMap := base.(map[string]any)
+	headMap, headIsMap := head.(map[string]any)
+	if (baseIsMap || base == nil) && (headIsMap || head == nil) {
+		keys := make(map[string]bool)
+		for key := range baseMap {
+			keys[key] = true
+		}
+		for key := range headMap {
+			keys[key] = true
+		}
+		names := make([]string, 0, len(keys))
+		for key := range keys {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+		for _, key := range names {
+			diffValues(prefix+"."+key, baseMap[key], headMap[key], fields)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(base, head) {
+		*fields = append(*fields, prefix)
+	}
+}
+
+// relationshipsFrom lists the intent-level relationships of a component
+func relationshipsFrom(intent *models.Repository, component string) []string {
+	relationships := []string{}
+	for _, rel := range intent.Relationships {
+		if rel.From == component {
+			relationships = append(relationships, rel.Type+" "+rel.To)
+		}
+	}
+	sort.Strings(relationships)
+	return relationships
+}
+
+func shortRevision(revision string) string {
+	if len(revision) > 12 {
+		return revision[:12]
+	}
+	return revision
+}
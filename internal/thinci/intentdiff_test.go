@@ -0,0 +1,254 @@
+package thinci
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourceplane/sourceplane/internal/models"
+	"github.com/sourceplane/sourceplane/internal/parser"
+	"github.com/sourceplane/sourceplane/internal/providers"
+)
+
+const diffBaseIntent = `apiVersion: sourceplane.io/v1
+kind: Intent
+metadata:
+  name: shop
+providers:
+  helm:
+    source: sourceplane/helm
+    version: ^1.0
+    defaults:
+      service:
+        namespace: shop
+      job:
+        backoffLimit: 2
+components:
+  - name: api
+    type: helm.service
+    paths: [services/api/**]
+    spec:
+      chart:
+        path: charts/api
+      values:
+        replicas: 2
+        image:
+          tag: v1
+  - name: db
+    type: helm.database
+    spec:
+      chart:
+        path: charts/db
+relationships:
+  - from: api
+    to: db
+    type: depends_on
+environments:
+  staging:
+    components:
+      api:
+        spec:
+          values:
+            replicas: 1
+  prod:
+    providers:
+      helm:
+        defaults:
+          service:
+            namespace: shop-prod
+    components:
+      api:
+        spec:
+          values:
+            replicas: 5
+`
+
+func parseDiffIntent(t *testing.T, data string) *models.Repository {
+	t.Helper()
+	intent, _, err := parser.ParseRepositoryData("intent.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return intent
+}
+
+func TestComponentDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		component   string
+		environment string
+		replace     []string // pairs of old and new text applied to the base intent
+		want        []string
+		wantAdded   bool
+	}{
+		{name: "unchanged", component: "api"},
+		{
+			name:      "spec value",
+			component: "api",
+			replace:   []string{"tag: v1", "tag: v2"},
+			want:      []string{"spec.values.image.tag"},
+		},
+		{
+			name:      "spec value added and removed",
+			component: "api",
+			replace:   []string{"        replicas: 2\n", "", "tag: v1", "tag: v1\n          pullPolicy: Always"},
+			want:      []string{"spec.values.image.pullPolicy", "spec.values.replicas"},
+		},
+		{
+			name:      "type",
+			component: "db",
+			replace:   []string{"type: helm.database", "type: helm.statefulset"},
+			want:      []string{"type"},
+		},
+		{
+			name:      "paths",
+			component: "api",
+			replace:   []string{"paths: [services/api/**]", "paths: [services/api/**, libs/**]"},
+			want:      []string{"paths"},
+		},
+		{
+			name:      "relationships",
+			component: "api",
+			replace:   []string{"type: depends_on", "type: uses"},
+			want:      []string{"relationships"},
+		},
+		{
+			name:      "provider version",
+			component: "db",
+			replace:   []string{"version: ^1.0", "version: ^2.0"},
+			want:      []string{"providers.helm.version"},
+		},
+		{
+			name:      "defaults of the component's kind",
+			component: "api",
+			replace:   []string{"namespace: shop\n", "namespace: store\n"},
+			want:      []string{"providers.helm.defaults.service.namespace"},
+		},
+		{
+			name:      "defaults of another kind",
+			component: "api",
+			replace:   []string{"backoffLimit: 2", "backoffLimit: 3"},
+		},
+
+		// Environment overlays only count for the planned environment
+		{
+			name:        "overlay of the planned environment",
+			component:   "api",
+			environment: "prod",
+			replace:     []string{"replicas: 5", "replicas: 6"},
+			want:        []string{"environments.prod.components.api.spec.values.replicas"},
+		},
+		{
+			name:        "overlay of another environment",
+			component:   "api",
+			environment: "staging",
+			replace:     []string{"replicas: 5", "replicas: 6"},
+		},
+		{
+			name:      "overlay without a planned environment",
+			component: "api",
+			replace:   []string{"replicas: 5", "replicas: 6"},
+		},
+		{
+			name:        "overlay added",
+			component:   "db",
+			environment: "prod",
+			replace:     []string{"            replicas: 5\n", "            replicas: 5\n      db:\n        spec:\n          storage: 100Gi\n"},
+			want:        []string{"environments.prod.components.db.spec.storage"},
+		},
+		{
+			name:        "provider defaults overlay",
+			component:   "api",
+			environment: "prod",
+			replace:     []string{"namespace: shop-prod", "namespace: shop-live"},
+			want:        []string{"environments.prod.providers.helm.defaults.service.namespace"},
+		},
+		{
+			name:        "provider defaults overlay of another kind",
+			component:   "db",
+			environment: "prod",
+			replace:     []string{"namespace: shop-prod", "namespace: shop-live"},
+		},
+
+		{
+			name:      "component added",
+			component: "api",
+			replace:   []string{"  - name: api\n", "  - name: web\n"},
+			wantAdded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head := diffBaseIntent
+			for i := 0; i < len(tt.replace); i += 2 {
+				if !strings.Contains(head, tt.replace[i]) {
+					t.Fatalf("base intent does not contain %q", tt.replace[i])
+				}
+				head = strings.Replace(head, tt.replace[i], tt.replace[i+1], 1)
+			}
+			source := &IntentSource{Path: "intent.yaml", Base: parseDiffIntent(t, diffBaseIntent)}
+			if tt.wantAdded {
+				// The component under test is the one missing from the base
+				source.Base = parseDiffIntent(t, head)
+				head = diffBaseIntent
+			}
+			headIntent := parseDiffIntent(t, head)
+
+			var component models.Component
+			for _, c := range headIntent.Components {
+				if c.Name == tt.component {
+					component = c
+				}
+			}
+
+			fields, added := componentDiff(source, headIntent, component, tt.environment)
+			if added != tt.wantAdded {
+				t.Errorf("added = %v, want %v", added, tt.wantAdded)
+			}
+			if len(fields) == 0 {
+				fields = nil
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("componentDiff() = %q, want %q", fields, tt.want)
+			}
+		})
+	}
+
+	// Without a base intent the file is new, and so is every component
+	headIntent := parseDiffIntent(t, diffBaseIntent)
+	if fields, added := componentDiff(&IntentSource{Path: "intent.yaml"}, headIntent, headIntent.Components[0], ""); !added || fields != nil {
+		t.Errorf("componentDiff() without a base = %q, %v, want the component added", fields, added)
+	}
+}
+
+func TestLockDiff(t *testing.T) {
+	lock := func(version, hash string) *providers.LockFile {
+		lock := providers.NewLockFile()
+		lock.Providers["helm"] = providers.LockedProvider{Source: "sourceplane/helm", Constraint: "^1.0", Version: version, Hash: hash}
+		return lock
+	}
+
+	tests := []struct {
+		name string
+		base *providers.LockFile
+		head *providers.LockFile
+		want string
+	}{
+		{name: "no lock files"},
+		{name: "unchanged", base: lock("1.0.0", "sha256:a"), head: lock("1.0.0", "sha256:a")},
+		{name: "version changed", base: lock("1.0.0", "sha256:a"), head: lock("1.1.0", "sha256:b"), want: "Provider helm version changed from 1.0.0 to 1.1.0"},
+		{name: "newly locked", head: lock("1.0.0", "sha256:a"), want: "Provider helm locked at 1.0.0"},
+		{name: "locked in a new lock file entry", base: providers.NewLockFile(), head: lock("1.0.0", "sha256:a"), want: "Provider helm locked at 1.0.0"},
+		{name: "content changed", base: lock("1.0.0", "sha256:a"), head: lock("1.0.0", "sha256:b"), want: "Provider helm content changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &IntentSource{Path: "intent.yaml", BaseLock: tt.base, HeadLock: tt.head}
+			if got := lockDiff(source, "helm"); got != tt.want {
+				t.Errorf("lockDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
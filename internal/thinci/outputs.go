@@ -0,0 +1,221 @@
+package thinci
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultStateDir is where run state such as job outputs is kept between
+// `thinci run` invocations, relative to the working directory
+const DefaultStateDir = ".sourceplane/run"
+
+// OutputEnvVar names the file a job's commands append outputs to, e.g.
+//
+//	echo "url=postgres://db.internal:5432" >> "$SP_OUTPUT"
+//
+// Multi-line values use a delimiter, as in GitHub Actions:
+//
+//	echo "manifests<<EOF" >> "$SP_OUTPUT"
+//	helm template . >> "$SP_OUTPUT"
+//	echo "EOF" >> "$SP_OUTPUT"
+const OutputEnvVar = "SP_OUTPUT"
+
+// RunState persists job outputs in a directory:
+//
+//	<dir>/jobs/<job-id>/output.env   file exposed to commands as $SP_OUTPUT
+//	<dir>/outputs/<job-id>.json      outputs of the last successful run of a job
+type RunState struct {
+	dir string
+}
+
+// NewRunState creates a run state rooted at dir
+func NewRunState(dir string) *RunState {
+	return &RunState{dir: dir}
+}
+
+// Dir returns the state directory
+func (s *RunState) Dir() string {
+	return s.dir
+}
+
+// PrepareOutputFile creates an empty output file for a job and forgets the
+// outputs of its previous run, returning the file's absolute path
+func (s *RunState) PrepareOutputFile(jobID string) (string, error) {
+	jobDir := filepath.Join(s.dir, "jobs", stateFileName(jobID))
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create run state directory: %w", err)
+	}
+
+	path, err := filepath.Abs(filepath.Join(jobDir, "output.env"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return "", fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	if err := os.Remove(s.outputsPath(jobID)); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to clear previous outputs of %s: %w", jobID, err)
+	}
+
+	return path, nil
+}
+
+// SaveOutputs records the outputs of a job
+func (s *RunState) SaveOutputs(jobID string, outputs map[string]string) error {
+	path := s.outputsPath(jobID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create run state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(outputs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write outputs of %s: %w", jobID, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadOutputs returns the recorded outputs of the given jobs, keyed by job
+// ID. Jobs without recorded outputs, because they have not run successfully
+// or are running right now, are left out.
+func (s *RunState) LoadOutputs(jobIDs []string) (map[string]map[string]string, error) {
+	all := make(map[string]map[string]string)
+	for _, jobID := range jobIDs {
+		data, err := os.ReadFile(s.outputsPath(jobID))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outputs of %s: %w", jobID, err)
+		}
+		outputs := make(map[string]string)
+		if err := json.Unmarshal(data, &outputs); err != nil {
+			return nil, fmt.Errorf("failed to parse outputs of %s: %w", jobID, err)
+		}
+		all[jobID] = outputs
+	}
+	return all, nil
+}
+
+func (s *RunState) outputsPath(jobID string) string {
+	return filepath.Join(s.dir, "outputs", stateFileName(jobID)+".json")
+}
+
+// stateFileName escapes a job ID for use as a file name
+func stateFileName(jobID string) string {
+	return url.PathEscape(jobID)
+}
+
+// ParseOutputFile reads `key=value` and `key<<DELIMITER` entries written to $SP_OUTPUT.
+// Later entries for the same key win.
+func ParseOutputFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	outputs := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if key, delimiter, ok := strings.Cut(line, "<<"); ok && !strings.Contains(key, "=") {
+			key = strings.TrimSpace(key)
+			start := lineNum
+			var value []string
+			closed := false
+			for scanner.Scan() {
+				lineNum++
+				next := strings.TrimRight(scanner.Text(), "\r")
+				if next == delimiter {
+					closed = true
+					break
+				}
+				value = append(value, next)
+			}
+			if !closed {
+				return nil, fmt.Errorf("%s:%d: output %q is missing its closing delimiter %q", path, start, key, delimiter)
+			}
+			if err := validateOutputName(key); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, start, err)
+			}
+			outputs[key] = strings.Join(value, "\n")
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key=value or key<<DELIMITER, got %q", path, lineNum, line)
+		}
+		key = strings.TrimSpace(key)
+		if err := validateOutputName(key); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		outputs[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+var outputNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func validateOutputName(name string) error {
+	if !outputNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid output name %q", name)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a string map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	templateActionPattern = regexp.MustCompile(`\{\{.*?\}\}`)
+	outputRefPattern      = regexp.MustCompile(`\.outputs\.([A-Za-z0-9_-]+)\.([A-Za-z0-9_.-]*[A-Za-z0-9_])`)
+)
+
+// rewriteOutputRefs turns {{.outputs.<job>.<name>}} references into calls of
+// the `output` template function, since job IDs usually contain dashes which
+// Go templates do not accept in field names
+func rewriteOutputRefs(templateStr string) string {
+	if !strings.Contains(templateStr, ".outputs.") {
+		return templateStr
+	}
+	return templateActionPattern.ReplaceAllStringFunc(templateStr, func(action string) string {
+		return outputRefPattern.ReplaceAllString(action, `(output "$1" "$2")`)
+	})
+}
@@ -0,0 +1,104 @@
+package thinci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunStateOutputs(t *testing.T) {
+	state := NewRunState(t.TempDir())
+
+	if err := state.SaveOutputs("db-apply", map[string]string{"url": "postgres://db:5432"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.SaveOutputs("cache/apply", map[string]string{"host": "redis"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := state.LoadOutputs([]string{"db-apply", "cache/apply", "never-ran"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"db-apply":    {"url": "postgres://db:5432"},
+		"cache/apply": {"host": "redis"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadOutputs() = %v, want %v", got, want)
+	}
+
+	// Preparing a job's next run forgets its previous outputs
+	if _, err := state.PrepareOutputFile("db-apply"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = state.LoadOutputs([]string{"db-apply"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("LoadOutputs() after PrepareOutputFile = %v, want nothing", got)
+	}
+}
+
+func TestRunStateLoadOutputsInvalid(t *testing.T) {
+	state := NewRunState(t.TempDir())
+	path := filepath.Join(state.Dir(), "outputs", "broken.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.LoadOutputs([]string{"broken"}); err == nil {
+		t.Error("LoadOutputs() of a corrupt file succeeded, want an error")
+	}
+}
+
+// TestRunOutputsParallel runs independent jobs concurrently against one run
+// state. Each job clears and writes its own outputs while the others are
+// starting, and a dependent reads what they recorded.
+func TestRunOutputsParallel(t *testing.T) {
+	const independent = 8
+
+	plan := &Plan{}
+	var deps []any
+	for i := 0; i < independent; i++ {
+		id := fmt.Sprintf("j%d", i)
+		plan.Jobs = append(plan.Jobs, Job{
+			"id":       id,
+			"commands": []any{fmt.Sprintf(`echo "value=%s" >> "$SP_OUTPUT"`, id)},
+		})
+		deps = append(deps, id)
+	}
+	plan.Jobs = append(plan.Jobs, Job{
+		"id":        "collect",
+		"dependsOn": deps,
+		"commands":  []any{`echo "first={{.outputs.j0.value}} last={{.outputs.j7.value}}" > "$SP_OUTPUT"`},
+	})
+
+	stateDir := t.TempDir()
+	for round := 0; round < 10; round++ {
+		runner := NewPlanRunner(plan, PlanRunOptions{Parallelism: independent, StateDir: stateDir})
+		runner.stdout, runner.stderr = io.Discard, io.Discard
+
+		results, err := runner.Run(context.Background())
+		if err != nil {
+			for _, result := range results {
+				if result.Status != JobStatusSucceeded {
+					t.Errorf("round %d: %s %s: %s%s", round, result.JobID, result.Status, result.Error, result.Reason)
+				}
+			}
+			t.FailNow()
+		}
+
+		collect := results[len(results)-1]
+		if want := map[string]string{"first": "j0 last=j7"}; !reflect.DeepEqual(collect.Outputs, want) {
+			t.Fatalf("round %d: collect outputs = %v, want %v", round, collect.Outputs, want)
+		}
+	}
+}
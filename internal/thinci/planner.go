@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/sourceplane/sourceplane/internal/models"
+	"github.com/sourceplane/sourceplane/internal/providers"
+	"github.com/sourceplane/sourceplane/internal/templating"
+	"github.com/sourceplane/sourceplane/internal/validator"
 )
 
 // Planner generates CI execution plans
@@ -23,12 +26,22 @@ func NewPlanner(registry *ProviderRegistry) *Planner {
 
 // GeneratePlan creates a complete CI execution plan from a request
 func (p *Planner) GeneratePlan(req PlanRequest, intents []*models.Repository) (*Plan, error) {
-	// Step 1: Detect changes
-	detector := NewChangeDetector(req.RepositoryPath, intents)
+	// Step 1: Check the environment overlays and specs, and detect changes
+	if err := validateEnvironment(req.Environment, intents); err != nil {
+		return nil, err
+	}
+	if err := p.validateSpecs(intents, req.Environment); err != nil {
+		return nil, err
+	}
+
+	detector := NewChangeDetector(req.RepositoryPath, intents, p.providerRegistry).
+		WithIntentSources(req.IntentSources, req.Environment).
+		WithBaseDir(req.IntentDir)
 	changes, err := detector.DetectChanges(req.ChangedFiles)
 	if err != nil {
 		return nil, fmt.Errorf("change detection failed: %w", err)
 	}
+	changes = p.expandImpact(changes, intents, req.IncludeDependents, req.IncludeDependencies)
 
 	// If changedOnly flag is set and no changes detected, return empty plan
 	if req.ChangedOnly && len(changes) == 0 {
@@ -48,7 +61,14 @@ func (p *Planner) GeneratePlan(req PlanRequest, intents []*models.Repository) (*
 	}
 
 	// Step 4: Generate jobs from sorted nodes
-	jobs := p.generateJobs(sortedNodes, req)
+	setValues, err := ParseSetValues(req.SetValues)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := p.generateJobs(sortedNodes, req, setValues)
+	if err != nil {
+		return nil, fmt.Errorf("job generation failed: %w", err)
+	}
 
 	// Step 5: Construct final plan
 	plan := &Plan{
@@ -84,7 +104,7 @@ func (p *Planner) expandComponents(
 		}
 
 		// Find component in intent to get relationships
-		component := p.findComponent(change.ComponentName, intents)
+		component, intent := p.findComponentIntent(change.ComponentName, intents)
 		if component == nil {
 			return nil, fmt.Errorf("component '%s' not found in intent", change.ComponentName)
 		}
@@ -97,9 +117,28 @@ func (p *Planner) expandComponents(
 
 		node := DependencyNode{
 			ComponentName: change.ComponentName,
+			ComponentType: component.Type,
 			Provider:      change.Provider,
+			Spec:          componentSpec(component),
 			Actions:       actions,
 			Dependencies:  dependencies,
+			Reason:        change.Reason,
+			AffectedPaths: change.AffectedPaths,
+			ImpactPath:    change.ImpactPath,
+		}
+		if config, ok := intent.Providers[change.Provider]; ok {
+			node.Defaults = kindDefaults(config.Defaults, component.Type)
+		}
+
+		// Overlay the planned environment, if the intent declares it
+		if env, ok := intent.Environments[req.Environment]; ok && req.Environment != "" {
+			if overlay, ok := env.Components[component.Name]; ok {
+				node.EnvironmentSpec = copyValue(overlay.Spec).(map[string]any)
+				delete(node.EnvironmentSpec, "relationships")
+			}
+			if config, ok := env.Providers[change.Provider]; ok {
+				node.EnvironmentDefaults = kindDefaults(config.Defaults, component.Type)
+			}
 		}
 
 		nodes = append(nodes, node)
@@ -157,12 +196,20 @@ func (p *Planner) hasAction(actions []ProviderAction, name string) bool {
 // extractDependencies gets component dependencies from relationships
 func (p *Planner) extractDependencies(component *models.Component, intents []*models.Repository) []string {
 	dependencies := []string{}
+	seen := make(map[string]bool)
+	add := func(name string) {
+		// A relationship may be declared both in the intent and on the component
+		if !seen[name] {
+			seen[name] = true
+			dependencies = append(dependencies, name)
+		}
+	}
 
 	for _, intent := range intents {
 		for _, rel := range intent.Relationships {
 			// Check if this component depends on another
 			if rel.From == component.Name && (rel.Type == "depends_on" || rel.Type == "uses") {
-				dependencies = append(dependencies, rel.To)
+				add(rel.To)
 			}
 		}
 	}
@@ -172,7 +219,7 @@ func (p *Planner) extractDependencies(component *models.Component, intents []*mo
 		for _, relInterface := range compRels {
 			if rel, ok := relInterface.(map[string]interface{}); ok {
 				if target, ok := rel["target"].(string); ok {
-					dependencies = append(dependencies, target)
+					add(target)
 				}
 			}
 		}
@@ -206,11 +253,12 @@ func (p *Planner) buildDependencyGraph(nodes []DependencyNode, intents []*models
 		}
 	}
 
-	// Kahn's algorithm for topological sort
+	// Kahn's algorithm for topological sort, seeded in node order so that
+	// the plan is the same on every run
 	queue := []string{}
-	for name, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, name)
+	for _, node := range nodes {
+		if inDegree[node.ComponentName] == 0 {
+			queue = append(queue, node.ComponentName)
 		}
 	}
 
@@ -240,7 +288,7 @@ func (p *Planner) buildDependencyGraph(nodes []DependencyNode, intents []*models
 }
 
 // generateJobs creates CI jobs from sorted dependency nodes
-func (p *Planner) generateJobs(nodes []DependencyNode, req PlanRequest) []Job {
+func (p *Planner) generateJobs(nodes []DependencyNode, req PlanRequest, setValues *SetValues) ([]Job, error) {
 	jobs := []Job{}
 
 	// Track which jobs depend on which other jobs
@@ -282,24 +330,33 @@ func (p *Planner) generateJobs(nodes []DependencyNode, req PlanRequest) []Job {
 			providerAction := p.findProviderAction(providerMeta, action)
 
 			// Build job inputs
-			inputs := p.buildJobInputs(node, providerMeta, req)
-			if providerAction != nil && providerAction.Inputs != nil {
-				for k, v := range providerAction.Inputs {
-					if _, exists := inputs[k]; !exists {
-						inputs[k] = v
-					}
+			inputs, sources := p.buildJobInputs(node, providerMeta, providerAction, req, setValues)
+
+			// Build job from provider template or use default structure
+			job, err := p.buildJobFromTemplate(jobID, node, action, deps, inputs, providerAction, providerMeta.ThinCI.Inputs, req)
+			if err != nil {
+				return nil, err
+			}
+			for name := range job.GetInputs() {
+				if _, ok := sources[name]; !ok {
+					sources[name] = "default"
 				}
 			}
+			job["inputSources"] = sources
 
-			// Build job from provider template or use default structure
-			job := p.buildJobFromTemplate(jobID, node, action, deps, inputs, providerAction, req)
+			// Record why the component is in the plan
+			job["reason"] = node.Reason
+			job["affectedPaths"] = append([]string{}, node.AffectedPaths...)
+			if len(node.ImpactPath) > 0 {
+				job["impactPath"] = node.ImpactPath
+			}
 
 			jobs = append(jobs, job)
 			jobDependencies[jobID] = deps
 		}
 	}
 
-	return jobs
+	return jobs, nil
 }
 
 // buildJobFromTemplate constructs a job using provider's job template
@@ -310,8 +367,9 @@ func (p *Planner) buildJobFromTemplate(
 	deps []string,
 	inputs map[string]any,
 	providerAction *ProviderAction,
+	inputSpecs map[string]InputSpec,
 	req PlanRequest,
-) Job {
+) (Job, error) {
 	job := make(Job)
 
 	// Start with core required fields
@@ -321,31 +379,19 @@ func (p *Planner) buildJobFromTemplate(
 	job["action"] = action
 	job["dependsOn"] = deps
 
-	// If provider has a job template, merge it
+	// If provider has a job template, merge a copy of it so that jobs sharing
+	// the template never see each other's values
 	if providerAction != nil && providerAction.JobTemplate != nil {
 		for k, v := range providerAction.JobTemplate {
 			// Don't override core fields
 			if k != "id" && k != "component" && k != "provider" && k != "action" && k != "dependsOn" {
-				job[k] = v
+				job[k] = copyValue(v)
 			}
 		}
 	}
 
-	// Add standard fields if not defined in template
-	if _, exists := job["inputs"]; !exists {
-		job["inputs"] = inputs
-	} else {
-		// Merge inputs with template inputs
-		if templateInputs, ok := job["inputs"].(map[string]any); ok {
-			for k, v := range inputs {
-				if _, exists := templateInputs[k]; !exists {
-					templateInputs[k] = v
-				}
-			}
-		} else {
-			job["inputs"] = inputs
-		}
-	}
+	// Template inputs are already merged into inputs by buildJobInputs
+	job["inputs"] = inputs
 
 	// Add commands from provider action
 	if _, exists := job["commands"]; !exists {
@@ -384,39 +430,166 @@ func (p *Planner) buildJobFromTemplate(
 		}
 	}
 
+	// Fill in and check the inputs the provider declares, recording the
+	// declarations so the executor can check them again before running
+	if len(inputSpecs) > 0 {
+		jobInputs := job.GetInputs()
+		if err := applyInputDefaults(inputSpecs, jobInputs, job); err != nil {
+			return nil, fmt.Errorf("job %s: %w", jobID, err)
+		}
+		if err := ValidateInputs(inputSpecs, jobInputs); err != nil {
+			return nil, fmt.Errorf("job %s: %w", jobID, err)
+		}
+		job["inputs"] = jobInputs
+		job["inputSpecs"] = inputSpecs
+	}
+
 	// Resolve templates in the job with actual values
-	p.resolveTemplates(job, inputs)
+	if err := p.resolveTemplates(job, job.GetInputs(), req.StrictTemplates); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// buildJobInputs merges the inputs for a job from all of its sources.
+// Later layers take precedence:
+//
+//  1. provider thinCI defaults, then the action's inputs and jobTemplate inputs
+//  2. intent providers.<name>.defaults for the component's kind
+//  3. environments.<env>.providers.<name>.defaults for the component's kind
+//  4. the component spec
+//  5. environments.<env>.components.<name>.spec
+//  6. the environment name and provider overrides
+//  7. --set values from the command line
+//
+// It also returns which layer set each top-level input.
+func (p *Planner) buildJobInputs(
+	node DependencyNode,
+	provider *ProviderMetadata,
+	providerAction *ProviderAction,
+	req PlanRequest,
+	setValues *SetValues,
+) (map[string]any, map[string]string) {
+	// An action's own inputs override the provider-wide defaults, and its
+	// job template has the last word among the provider's inputs
+	actionInputs := make(map[string]any)
+	if providerAction != nil {
+		mergeInputs(actionInputs, providerAction.Inputs)
+		if templateInputs, ok := providerAction.JobTemplate["inputs"].(map[string]any); ok {
+			mergeInputs(actionInputs, templateInputs)
+		}
+	}
+
+	environment := make(map[string]any)
+	if req.Environment != "" {
+		environment["environment"] = req.Environment
+	}
+	if overrides, ok := req.ProviderOverrides[node.Provider]; ok {
+		mergeInputs(environment, overrides)
+	}
 
-	return job
+	set := make(map[string]any)
+	if setValues != nil {
+		mergeInputs(set, setValues.Global)
+		mergeInputs(set, setValues.ByComponent[node.ComponentName])
+	}
+
+	layers := []inputLayer{
+		{name: "component", values: map[string]any{"component": node.ComponentName}},
+		{name: "provider", values: provider.ThinCI.Defaults},
+		{name: "provider", values: actionInputs},
+		{name: "intent", values: node.Defaults},
+		{name: "environment", values: node.EnvironmentDefaults},
+		{name: "spec", values: node.Spec},
+		{name: "environment", values: node.EnvironmentSpec},
+		{name: "environment", values: environment},
+		{name: "set", values: set},
+	}
+
+	return mergeInputLayers(layers, provider.ThinCI.Inputs)
+}
+
+// componentSpec returns the component's spec as job inputs, including the
+// deprecated `inputs` field and excluding relationships
+func componentSpec(component *models.Component) map[string]any {
+	spec := make(map[string]any)
+	mergeInputs(spec, component.Inputs)
+	mergeInputs(spec, component.Spec)
+	delete(spec, "relationships")
+	return spec
 }
 
-// buildJobInputs constructs the inputs map for a job
-func (p *Planner) buildJobInputs(node DependencyNode, provider *ProviderMetadata, req PlanRequest) map[string]any {
-	inputs := make(map[string]any)
+// kindDefaults returns a provider's defaults for a component type,
+// e.g. providers.helm.defaults.service for helm.service
+func kindDefaults(defaults map[string]any, componentType string) map[string]any {
+	kind := componentType
+	if _, after, found := strings.Cut(componentType, "."); found {
+		kind = after
+	}
 
-	// Add component name
-	inputs["component"] = node.ComponentName
+	kindDefaults, _ := defaults[kind].(map[string]any)
+	return kindDefaults
+}
 
-	// Add provider-specific defaults
-	if provider.ThinCI.Defaults != nil {
-		for k, v := range provider.ThinCI.Defaults {
-			inputs[k] = v
+// validateEnvironment checks the intents' environment overlays and that the
+// planned environment is declared. Intents without an environments section
+// accept any environment name.
+func validateEnvironment(environment string, intents []*models.Repository) error {
+	declared := []string{}
+	for _, intent := range intents {
+		if problems := validator.ValidateEnvironments(intent); len(problems) > 0 {
+			return fmt.Errorf("invalid environments in intent '%s': %s", intent.Metadata.Name, strings.Join(validator.Messages(problems), "; "))
+		}
+		if problems := validator.ValidatePathPatterns(intent); len(problems) > 0 {
+			return fmt.Errorf("invalid path patterns in intent '%s': %s", intent.Metadata.Name, strings.Join(validator.Messages(problems), "; "))
+		}
+		for name := range intent.Environments {
+			declared = append(declared, name)
 		}
 	}
 
-	// Add CLI overrides
-	if req.Environment != "" {
-		inputs["environment"] = req.Environment
+	if environment == "" || len(declared) == 0 {
+		return nil
+	}
+	for _, name := range declared {
+		if name == environment {
+			return nil
+		}
 	}
 
-	// Add provider overrides from request
-	if overrides, ok := req.ProviderOverrides[node.Provider]; ok {
-		for k, v := range overrides {
-			inputs[k] = v
+	sort.Strings(declared)
+	return fmt.Errorf("environment '%s' is not declared in intent (available: %s)", environment, strings.Join(declared, ", "))
+}
+
+// validateSpecs checks every component spec, with the planned environment's
+// overlay applied, against the schema of its provider. Components whose
+// provider is not registered are left to the later planning steps.
+func (p *Planner) validateSpecs(intents []*models.Repository, environment string) error {
+	problems := []string{}
+	for _, intent := range intents {
+		overlays := intent.Environments[environment].Components
+		for i, component := range intent.Components {
+			providerMeta, err := p.providerRegistry.GetProvider(providers.GetProviderNameFromType(component.Type))
+			if err != nil {
+				continue
+			}
+
+			spec := validator.ComponentSpec(component)
+			if overlay, ok := overlays[component.Name]; ok && environment != "" {
+				merged := copyValue(spec).(map[string]any)
+				mergeInputs(merged, overlay.Spec)
+				spec = merged
+			}
+
+			problems = append(problems, validator.Messages(validator.ValidateComponentSpec(i, component.Type, spec, providerMeta))...)
 		}
 	}
 
-	return inputs
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid component spec:\n  • %s", strings.Join(problems, "\n  • "))
+	}
+	return nil
 }
 
 // createJobMetadata creates platform-specific job metadata
@@ -442,88 +615,83 @@ func (p *Planner) createJobMetadata(target string, node DependencyNode, action s
 	return metadata
 }
 
-// resolveTemplates resolves template variables in job fields with actual values
-func (p *Planner) resolveTemplates(job Job, inputs map[string]any) {
-	// Build template context from job fields and inputs
-	context := make(map[string]string)
-	
-	// Add component name and basic fields
-	if component, ok := job["component"].(string); ok {
-		context["component"] = component
-		context["releaseName"] = component // Default release name is component name
-	}
-	
-	// Add all inputs as template variables
-	for k, v := range inputs {
-		if str, ok := v.(string); ok {
-			context[k] = str
-		} else if num, ok := v.(int); ok {
-			context[k] = fmt.Sprintf("%d", num)
-		} else if b, ok := v.(bool); ok {
-			context[k] = fmt.Sprintf("%t", b)
-		}
-	}
-	
-	// Add environment if available
-	if env, ok := inputs["environment"].(string); ok {
-		context["environment"] = env
-	}
-	
-	// Recursively resolve templates in the job
-	// Need to reassign resolved values back to the job map
-	for k, v := range job {
-		job[k] = p.resolveValue(v, context)
+// resolveTemplates resolves template variables in job fields with actual values.
+// Templates that reference outputs of other jobs are left in place for the
+// executor to resolve. Missing keys render as empty strings, as they would
+// when the job runs, unless strict is set. Fields are resolved in sorted order,
+// so the same error is reported on every run.
+func (p *Planner) resolveTemplates(job Job, inputs map[string]any, strict bool) error {
+	data := templateData(job, inputs)
+
+	engine := templating.New(strict)
+	render := func(path, s string) (string, error) {
+		if !templating.IsTemplate(s) || referencesOutputs(s) {
+			return s, nil
+		}
+		return engine.Render(path, s, data)
+	}
+
+	fields := make([]string, 0, len(job))
+	for k := range job {
+		fields = append(fields, k)
 	}
-}
+	sort.Strings(fields)
 
-// resolveValue recursively resolves templates in any value type
-func (p *Planner) resolveValue(val any, context map[string]string) any {
-	switch v := val.(type) {
-	case string:
-		return p.resolveString(v, context)
-	case []string:
-		resolved := make([]string, len(v))
-		for i, str := range v {
-			resolved[i] = p.resolveString(str, context)
-		}
-		return resolved
-	case []interface{}:
-		for i, item := range v {
-			v[i] = p.resolveValue(item, context)
-		}
-		return v
-	case map[string]any:
-		for k, item := range v {
-			v[k] = p.resolveValue(item, context)
-		}
-		return v
-	default:
-		return v
+	for _, k := range fields {
+		var resolved any
+		var err error
+		if steps, ok := job[k].([]ActionStep); ok {
+			resolved, err = resolveSteps(k, steps, render)
+		} else {
+			resolved, err = templating.Walk(k, job[k], render)
+		}
+		if err != nil {
+			return withJob(err, job.GetID())
+		}
+		job[k] = resolved
 	}
+	return nil
 }
 
-// resolveString replaces template variables like {{.varName}} with actual values
-func (p *Planner) resolveString(str string, context map[string]string) string {
-	// Simple template resolution: {{.varName}}
-	result := str
-	for key, value := range context {
-		placeholder := fmt.Sprintf("{{.%s}}", key)
-		result = strings.ReplaceAll(result, placeholder, value)
+// resolveSteps renders the names and commands of typed provider steps
+func resolveSteps(field string, steps []ActionStep, render func(path, s string) (string, error)) ([]ActionStep, error) {
+	resolved := make([]ActionStep, len(steps))
+	for i, step := range steps {
+		path := fmt.Sprintf("%s[%d]", field, i)
+		name, err := templating.Walk(path+".name", step.Name, render)
+		if err != nil {
+			return nil, err
+		}
+		command, err := templating.Walk(path+".command", step.Command, render)
+		if err != nil {
+			return nil, err
+		}
+		inputs, err := templating.Walk(path+".inputs", step.Inputs, render)
+		if err != nil {
+			return nil, err
+		}
+		step.Name = name.(string)
+		step.Command = command.(string)
+		if m, ok := inputs.(map[string]any); ok {
+			step.Inputs = m
+		}
+		resolved[i] = step
 	}
-	return result
+	return resolved, nil
 }
 
 // Helper functions
 
-func (p *Planner) findComponent(name string, intents []*models.Repository) *models.Component {
+// findComponentIntent finds a component and the intent that declares it
+func (p *Planner) findComponentIntent(name string, intents []*models.Repository) (*models.Component, *models.Repository) {
 	for _, intent := range intents {
-		for _, comp := range intent.Components {
-			if comp.Name == name {
-				return &comp
+		for i := range intent.Components {
+			if intent.Components[i].Name == name {
+				return &intent.Components[i], intent
 			}
 		}
 	}
-	return nil
+	return nil, nil
 }
 
 func (p *Planner) findNode(name string, nodes []DependencyNode) *DependencyNode {
@@ -560,19 +728,12 @@ func (p *Planner) createEmptyPlan(req PlanRequest) *Plan {
 	}
 }
 
-// ProviderMetadata extends the provider model with thin-ci specific configuration
-type ProviderMetadata struct {
-	Name    string
-	Version string
-	ThinCI  ThinCIConfig
-}
-
-// ThinCIConfig holds thin-ci specific provider configuration
-type ThinCIConfig struct {
-	Actions  []ProviderAction `yaml:"actions"`
-	Defaults map[string]any   `yaml:"defaults,omitempty"`
-	Ordering []string         `yaml:"ordering,omitempty"` // Default action ordering
-}
+// ProviderMetadata is the provider model shared with the rest of the CLI,
+// including the thinCI section thin-ci plans from
+type (
+	ProviderMetadata = providers.Provider
+	ThinCIConfig     = providers.ThinCIConfig
+)
 
 // ProviderRegistry manages loaded providers
 type ProviderRegistry struct {
@@ -0,0 +1,149 @@
+package thinci
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestBuildJobInputsPrecedence sets the input `value` in every layer up to
+// the one named in each case, which must win
+func TestBuildJobInputsPrecedence(t *testing.T) {
+	type sources struct {
+		provider *ProviderMetadata
+		action   *ProviderAction
+		node     *DependencyNode
+		req      *PlanRequest
+		set      *SetValues
+	}
+	layers := []struct {
+		name   string
+		source string
+		apply  func(s sources)
+	}{
+		{"provider thinCI defaults", "provider", func(s sources) {
+			s.provider.ThinCI.Defaults = map[string]any{"value": "provider thinCI defaults"}
+		}},
+		{"action inputs", "provider", func(s sources) {
+			s.action.Inputs = map[string]any{"value": "action inputs"}
+		}},
+		{"job template inputs", "provider", func(s sources) {
+			s.action.JobTemplate = map[string]any{"inputs": map[string]any{"value": "job template inputs"}}
+		}},
+		{"intent defaults", "intent", func(s sources) {
+			s.node.Defaults = map[string]any{"value": "intent defaults"}
+		}},
+		{"environment defaults", "environment", func(s sources) {
+			s.node.EnvironmentDefaults = map[string]any{"value": "environment defaults"}
+		}},
+		{"spec", "spec", func(s sources) {
+			s.node.Spec = map[string]any{"value": "spec"}
+		}},
+		{"environment spec", "environment", func(s sources) {
+			s.node.EnvironmentSpec = map[string]any{"value": "environment spec"}
+		}},
+		{"provider overrides", "environment", func(s sources) {
+			s.req.ProviderOverrides = map[string]map[string]any{"helm": {"value": "provider overrides"}}
+		}},
+		{"set", "set", func(s sources) {
+			s.set.Global["value"] = "set"
+		}},
+		{"set for the component", "set", func(s sources) {
+			s.set.ByComponent["api"] = map[string]any{"value": "set for the component"}
+		}},
+	}
+
+	for top := range layers {
+		t.Run(layers[top].name, func(t *testing.T) {
+			s := sources{
+				provider: &ProviderMetadata{},
+				action:   &ProviderAction{Name: "deploy"},
+				node:     &DependencyNode{ComponentName: "api", Provider: "helm"},
+				req:      &PlanRequest{},
+				set:      &SetValues{Global: map[string]any{}, ByComponent: map[string]map[string]any{}},
+			}
+			for _, layer := range layers[:top+1] {
+				layer.apply(s)
+			}
+
+			inputs, inputSources := (&Planner{}).buildJobInputs(*s.node, s.provider, s.action, *s.req, s.set)
+			if inputs["value"] != layers[top].name {
+				t.Errorf("value = %v, want %q", inputs["value"], layers[top].name)
+			}
+			if inputSources["value"] != layers[top].source {
+				t.Errorf("source = %q, want %q", inputSources["value"], layers[top].source)
+			}
+			if inputs["component"] != "api" || inputSources["component"] != "component" {
+				t.Errorf("component = %v from %q, want api from component", inputs["component"], inputSources["component"])
+			}
+		})
+	}
+}
+
+func TestBuildJobInputsMergesNestedValues(t *testing.T) {
+	provider := &ProviderMetadata{}
+	provider.ThinCI.Defaults = map[string]any{"values": map[string]any{"replicas": 1, "image": map[string]any{"tag": "latest"}}}
+	node := DependencyNode{
+		ComponentName:   "api",
+		Provider:        "helm",
+		Spec:            map[string]any{"values": map[string]any{"image": map[string]any{"repository": "api"}}},
+		EnvironmentSpec: map[string]any{"values": map[string]any{"replicas": 3}},
+	}
+	set := &SetValues{
+		Global:      map[string]any{"values": map[string]any{"image": map[string]any{"tag": "v2"}}},
+		ByComponent: map[string]map[string]any{},
+	}
+
+	inputs, _ := (&Planner{}).buildJobInputs(node, provider, nil, PlanRequest{Environment: "prod"}, set)
+	want := map[string]any{
+		"component":   "api",
+		"environment": "prod",
+		"values": map[string]any{
+			"replicas": 3,
+			"image":    map[string]any{"repository": "api", "tag": "v2"},
+		},
+	}
+	if !reflect.DeepEqual(inputs, want) {
+		t.Errorf("inputs = %v, want %v", inputs, want)
+	}
+	// Layers are copied, not modified
+	if provider.ThinCI.Defaults["values"].(map[string]any)["replicas"] != 1 {
+		t.Errorf("provider defaults were modified: %v", provider.ThinCI.Defaults)
+	}
+}
+
+func TestResolveTemplates(t *testing.T) {
+	newJob := func() Job {
+		return Job{
+			"id":        "api-deploy",
+			"component": "api",
+			"commands": []any{
+				"helm upgrade {{.releaseName}} {{.chartPath}} --namespace {{.namespace}}",
+				"curl {{.outputs.db-deploy.url}}/{{.component}}",
+			},
+			"metadata": map[string]any{"environment": map[string]any{"name": "{{.environment}}"}},
+		}
+	}
+	inputs := map[string]any{"releaseName": "api", "namespace": "web"}
+
+	job := newJob()
+	if err := (&Planner{}).resolveTemplates(job, inputs, false); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		// Keys that resolve are rendered even when another one is missing
+		"helm upgrade api  --namespace web",
+		// Outputs are resolved by the executor
+		"curl {{.outputs.db-deploy.url}}/{{.component}}",
+	}
+	if !reflect.DeepEqual(job["commands"], want) {
+		t.Errorf("commands = %q, want %q", job["commands"], want)
+	}
+	if name := job.GetMetadata()["environment"].(map[string]any)["name"]; name != "" {
+		t.Errorf("environment name = %q, want it empty", name)
+	}
+
+	err := (&Planner{}).resolveTemplates(newJob(), inputs, true)
+	if err == nil || err.Error() != `job api-deploy: field commands[0]: <.chartPath> (line 1, col 32): map has no entry for key "chartPath"` {
+		t.Errorf("strict resolveTemplates() error = %v, want a missing chartPath error", err)
+	}
+}
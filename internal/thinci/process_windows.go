@@ -0,0 +1,20 @@
+//go:build windows
+
+package thinci
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup terminates the command; Windows has no process group
+// signals, so any signal other than os.Kill is delivered as a kill as well
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
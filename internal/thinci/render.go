@@ -0,0 +1,278 @@
+package thinci
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RenderPlan renders a plan into the native pipeline format of a CI platform
+func RenderPlan(plan *Plan, target string) ([]byte, error) {
+	switch target {
+	case "github":
+		return RenderGitHubWorkflow(plan)
+	case "gitlab":
+		return RenderGitLabPipeline(plan)
+	default:
+		return nil, fmt.Errorf("unsupported render target: %s", target)
+	}
+}
+
+// yamlMap is a YAML mapping that preserves insertion order when marshaled
+type yamlMap []yamlEntry
+
+type yamlEntry struct {
+	Key   string
+	Value any
+}
+
+// Set appends a key/value pair
+func (m *yamlMap) Set(key string, value any) {
+	*m = append(*m, yamlEntry{Key: key, Value: value})
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (m yamlMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, entry := range m {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.Key}
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(entry.Value); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", entry.Key, err)
+		}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node, nil
+}
+
+// encodeYAML marshals a value with two-space indentation
+func encodeYAML(header string, value any) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString(header)
+
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// renderHeader is prepended to every generated pipeline file
+func renderHeader(plan *Plan) string {
+	header := "# Code generated by sourceplane thin-ci. DO NOT EDIT.\n"
+	header += fmt.Sprintf("# mode: %s, base: %s, head: %s\n", plan.Mode, plan.Metadata.BaseRef, plan.Metadata.HeadRef)
+	return header
+}
+
+var invalidJobIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// sanitizeJobID converts a plan job ID into an identifier accepted by CI platforms
+func sanitizeJobID(id string) string {
+	id = invalidJobIDChars.ReplaceAllString(id, "-")
+	if id == "" || !(id[0] == '_' || (id[0] >= 'A' && id[0] <= 'Z') || (id[0] >= 'a' && id[0] <= 'z')) {
+		id = "job-" + id
+	}
+	return id
+}
+
+// jobValue looks up a key on the job first and then in its metadata
+func jobValue(job Job, key string) (any, bool) {
+	if v, ok := job[key]; ok {
+		return v, true
+	}
+	v, ok := job.GetMetadata()[key]
+	return v, ok
+}
+
+// isResolved reports whether a rendered string is non-empty and free of template placeholders
+func isResolved(s string) bool {
+	return s != "" && !strings.Contains(s, "{{")
+}
+
+// toInt converts numeric values decoded from JSON or YAML to int
+func toInt(val any) (int, bool) {
+	switch v := val.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toBool converts boolean values decoded from JSON or YAML to bool
+func toBool(val any) bool {
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// toStringMap converts map values to map[string]string, formatting non-strings
+func toStringMap(val any) map[string]string {
+	result := make(map[string]string)
+	switch v := val.(type) {
+	case map[string]string:
+		for k, s := range v {
+			result[k] = s
+		}
+	case map[string]any:
+		for k, item := range v {
+			result[k] = fmt.Sprintf("%v", item)
+		}
+	}
+	return result
+}
+
+// jobArtifact is a normalized artifact declaration
+type jobArtifact struct {
+	Name string
+	Path string
+}
+
+// jobArtifacts returns the artifacts declared on a job
+func jobArtifacts(job Job) []jobArtifact {
+	raw, _ := jobValue(job, "artifacts")
+	items, ok := raw.([]interface{})
+	if !ok {
+		if typed, ok := raw.([]map[string]any); ok {
+			for _, m := range typed {
+				items = append(items, m)
+			}
+		}
+	}
+
+	artifacts := []jobArtifact{}
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		artifact := jobArtifact{Name: getString(m, "name"), Path: getString(m, "path")}
+		if artifact.Path == "" {
+			continue
+		}
+		if artifact.Name == "" {
+			artifact.Name = artifact.Path
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts
+}
+
+// jobCache is a normalized cache declaration
+type jobCache struct {
+	Key   string
+	Paths []string
+}
+
+// jobCacheConfig returns the cache declared on a job, or nil if caching is
+// disabled. Paths and a key that still contain template placeholders are
+// dropped; the key then falls back to the provider and component.
+func jobCacheConfig(job Job) *jobCache {
+	raw, _ := jobValue(job, "cache")
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if enabled, exists := m["enabled"]; exists && !toBool(enabled) {
+		return nil
+	}
+
+	cache := &jobCache{Key: getString(m, "key")}
+	for _, path := range toStringSlice(m["paths"]) {
+		if isResolved(path) {
+			cache.Paths = append(cache.Paths, path)
+		}
+	}
+	if len(cache.Paths) == 0 {
+		return nil
+	}
+	if !isResolved(cache.Key) {
+		cache.Key = fmt.Sprintf("%s-%s", job.GetProvider(), job.GetComponent())
+	}
+	return cache
+}
+
+// jobEnvironment returns the deployment environment name and URL for a job.
+// Jobs that declare an environment or require approval fall back to the
+// plan's target environment when their own name is unresolved. Approval is
+// enforced through the environment, so a job requiring approval without one
+// is an error.
+func jobEnvironment(job Job, plan *Plan) (string, string, error) {
+	var name, url string
+
+	declared, hasEnvironment := job.GetMetadata()["environment"]
+	switch v := declared.(type) {
+	case string:
+		name = v
+	case map[string]any:
+		name = getString(v, "name")
+		url = getString(v, "url")
+	}
+
+	requiresApproval, _ := jobValue(job, "requiresApproval")
+	if !isResolved(name) {
+		name = ""
+		if hasEnvironment || toBool(requiresApproval) {
+			name = plan.Metadata.Environment
+		}
+	}
+	if !isResolved(url) {
+		url = ""
+	}
+	if name == "" && toBool(requiresApproval) {
+		return "", "", fmt.Errorf("job %s requires approval but has no environment to gate it: declare metadata.environment or plan with --env", job.GetID())
+	}
+	return name, url, nil
+}
+
+// renderSteps returns the job's pre-steps, commands and post-steps in execution order
+func renderSteps(job Job) []ActionStep {
+	steps := []ActionStep{}
+	steps = append(steps, job.GetPreSteps()...)
+	for i, command := range job.GetCommands() {
+		steps = append(steps, ActionStep{Name: fmt.Sprintf("%s %d", job.GetAction(), i+1), Command: command})
+	}
+	steps = append(steps, job.GetPostSteps()...)
+	return steps
+}
+
+// planJobIDs returns the set of job IDs present in a plan
+func planJobIDs(plan *Plan) map[string]bool {
+	ids := make(map[string]bool, len(plan.Jobs))
+	for _, job := range plan.Jobs {
+		ids[job.GetID()] = true
+	}
+	return ids
+}
+
+// jobNeeds returns the sanitized IDs of a job's dependencies that exist in the plan
+func jobNeeds(job Job, ids map[string]bool) []string {
+	needs := []string{}
+	for _, dep := range job.GetDependsOn() {
+		if ids[dep] {
+			needs = append(needs, sanitizeJobID(dep))
+		}
+	}
+	sort.Strings(needs)
+	return needs
+}
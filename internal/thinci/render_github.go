@@ -0,0 +1,200 @@
+package thinci
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GitHub Actions used by rendered workflows
+const (
+	githubCheckoutAction = "actions/checkout@v4"
+	githubCacheAction    = "actions/cache@v4"
+	githubUploadAction   = "actions/upload-artifact@v4"
+)
+
+// RenderGitHubWorkflow renders a plan as a GitHub Actions workflow file
+func RenderGitHubWorkflow(plan *Plan) ([]byte, error) {
+	workflow := yamlMap{}
+	workflow.Set("name", fmt.Sprintf("Sourceplane %s", plan.Mode))
+	workflow.Set("on", githubTriggers(plan))
+
+	ids := planJobIDs(plan)
+	jobs := yamlMap{}
+	for _, job := range plan.Jobs {
+		if job.GetID() == "" {
+			return nil, fmt.Errorf("plan contains a job without an id")
+		}
+		rendered, err := renderGitHubJob(job, plan, ids)
+		if err != nil {
+			return nil, err
+		}
+		jobs.Set(sanitizeJobID(job.GetID()), rendered)
+	}
+	workflow.Set("jobs", jobs)
+
+	return encodeYAML(renderHeader(plan), workflow)
+}
+
+// githubTriggers derives workflow triggers from the plan mode
+func githubTriggers(plan *Plan) yamlMap {
+	branch := plan.Metadata.BaseRef
+	if branch == "" {
+		branch = "main"
+	}
+
+	triggers := yamlMap{}
+	switch plan.Mode {
+	case "apply":
+		triggers.Set("push", map[string]any{"branches": []string{branch}})
+	case "plan":
+		triggers.Set("pull_request", map[string]any{"branches": []string{branch}})
+	}
+	triggers.Set("workflow_dispatch", map[string]any{})
+	return triggers
+}
+
+// renderGitHubJob converts a single plan job into a workflow job
+func renderGitHubJob(job Job, plan *Plan, ids map[string]bool) (yamlMap, error) {
+	metadata := job.GetMetadata()
+	out := yamlMap{}
+
+	out.Set("name", fmt.Sprintf("%s %s", job.GetComponent(), job.GetAction()))
+
+	runsOn := getString(metadata, "runsOn")
+	if runsOn == "" {
+		runsOn = "ubuntu-latest"
+	}
+	out.Set("runs-on", runsOn)
+
+	if needs := jobNeeds(job, ids); len(needs) > 0 {
+		out.Set("needs", needs)
+	}
+
+	name, url, err := jobEnvironment(job, plan)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		if url != "" {
+			out.Set("environment", map[string]string{"name": name, "url": url})
+		} else {
+			out.Set("environment", name)
+		}
+	}
+
+	if permissions := githubPermissions(metadata["permissions"]); len(permissions) > 0 {
+		out.Set("permissions", permissions)
+	}
+
+	if timeout, ok := toInt(metadata["timeout"]); ok && timeout > 0 {
+		out.Set("timeout-minutes", timeout)
+	}
+
+	if toBool(metadata["continueOnError"]) {
+		out.Set("continue-on-error", true)
+	}
+
+	if env := toStringMap(metadata["env"]); len(env) > 0 {
+		out.Set("env", env)
+	}
+
+	out.Set("steps", renderGitHubSteps(job))
+	return out, nil
+}
+
+// renderGitHubSteps builds checkout, cache, run and artifact upload steps
+func renderGitHubSteps(job Job) []yamlMap {
+	steps := []yamlMap{}
+
+	checkout := yamlMap{}
+	checkout.Set("uses", githubCheckoutAction)
+	steps = append(steps, checkout)
+
+	if cache := jobCacheConfig(job); cache != nil {
+		step := yamlMap{}
+		step.Set("name", "Cache")
+		step.Set("uses", githubCacheAction)
+		step.Set("with", yamlMap{
+			{Key: "path", Value: strings.Join(cache.Paths, "\n")},
+			{Key: "key", Value: cache.Key},
+		})
+		steps = append(steps, step)
+	}
+
+	for _, s := range renderSteps(job) {
+		step := yamlMap{}
+		if s.Name != "" {
+			step.Set("name", s.Name)
+		}
+		step.Set("run", s.Command)
+		steps = append(steps, step)
+	}
+
+	for _, artifact := range jobArtifacts(job) {
+		step := yamlMap{}
+		step.Set("name", fmt.Sprintf("Upload %s", artifact.Name))
+		step.Set("if", "always()")
+		step.Set("uses", githubUploadAction)
+		step.Set("with", yamlMap{
+			{Key: "name", Value: fmt.Sprintf("%s-%s", job.GetID(), artifact.Name)},
+			{Key: "path", Value: artifact.Path},
+			{Key: "if-no-files-found", Value: "ignore"},
+		})
+		steps = append(steps, step)
+	}
+
+	return steps
+}
+
+// githubPermissions normalizes the permission formats found in plans:
+// plain scope names ("id-token"), "scope: level" strings and single-key maps
+func githubPermissions(val any) map[string]string {
+	permissions := make(map[string]string)
+
+	add := func(scope, level string) {
+		scope = strings.TrimSpace(scope)
+		level = strings.TrimSpace(level)
+		if scope == "" {
+			return
+		}
+		if level == "" {
+			level = "read"
+			if scope == "id-token" {
+				level = "write"
+			}
+		}
+		permissions[scope] = level
+	}
+
+	var items []any
+	switch v := val.(type) {
+	case []string:
+		for _, s := range v {
+			items = append(items, s)
+		}
+	case []interface{}:
+		items = v
+	case map[string]any:
+		items = append(items, v)
+	}
+
+	for _, item := range items {
+		switch p := item.(type) {
+		case string:
+			scope, level, _ := strings.Cut(p, ":")
+			add(scope, level)
+		case map[string]any:
+			scopes := make([]string, 0, len(p))
+			for scope := range p {
+				scopes = append(scopes, scope)
+			}
+			sort.Strings(scopes)
+			for _, scope := range scopes {
+				add(scope, fmt.Sprintf("%v", p[scope]))
+			}
+		}
+	}
+
+	return permissions
+}
@@ -0,0 +1,236 @@
+package thinci
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultActionOrder is the canonical ordering of provider actions used to derive stages
+var defaultActionOrder = []string{"validate", "plan", "apply", "destroy"}
+
+// RenderGitLabPipeline renders a plan as a .gitlab-ci.yml pipeline
+func RenderGitLabPipeline(plan *Plan) ([]byte, error) {
+	for _, job := range plan.Jobs {
+		if job.GetID() == "" {
+			return nil, fmt.Errorf("plan contains a job without an id")
+		}
+	}
+
+	stageOf, stages, err := gitlabStages(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := yamlMap{}
+	pipeline.Set("stages", stages)
+
+	ids := planJobIDs(plan)
+	for _, job := range plan.Jobs {
+		rendered, err := renderGitLabJob(job, plan, stageOf[job.GetID()], ids)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Set(sanitizeJobID(job.GetID()), rendered)
+	}
+
+	return encodeYAML(renderHeader(plan), pipeline)
+}
+
+// gitlabStage identifies a stage by dependency round and action order
+type gitlabStage struct {
+	round  int
+	order  int
+	action string
+}
+
+func (s gitlabStage) name() string {
+	if s.round == 0 {
+		return s.action
+	}
+	return fmt.Sprintf("%s-%d", s.action, s.round+1)
+}
+
+// gitlabStages assigns every job a stage derived from its action order.
+// GitLab only allows `needs:` to reference jobs in the same or an earlier
+// stage, so a job whose dependency runs a later action (e.g. a validate job
+// waiting on another component's apply) is moved to a subsequent round of
+// stages such as "validate-2".
+func gitlabStages(plan *Plan) (map[string]string, []string, error) {
+	order := make(map[string]int)
+	for i, action := range defaultActionOrder {
+		order[action] = i
+	}
+	for _, job := range plan.Jobs {
+		if _, ok := order[job.GetAction()]; !ok {
+			order[job.GetAction()] = len(order)
+		}
+	}
+
+	jobs := make(map[string]Job, len(plan.Jobs))
+	for _, job := range plan.Jobs {
+		jobs[job.GetID()] = job
+	}
+
+	assigned := make(map[string]gitlabStage)
+	visiting := make(map[string]bool)
+
+	var assign func(id string) (gitlabStage, error)
+	assign = func(id string) (gitlabStage, error) {
+		if stage, ok := assigned[id]; ok {
+			return stage, nil
+		}
+		if visiting[id] {
+			return gitlabStage{}, fmt.Errorf("circular job dependency involving %s", id)
+		}
+		visiting[id] = true
+		defer delete(visiting, id)
+
+		job := jobs[id]
+		stage := gitlabStage{order: order[job.GetAction()], action: job.GetAction()}
+		for _, dep := range job.GetDependsOn() {
+			if _, ok := jobs[dep]; !ok {
+				continue
+			}
+			depStage, err := assign(dep)
+			if err != nil {
+				return gitlabStage{}, err
+			}
+			round := depStage.round
+			if depStage.order > stage.order {
+				round++
+			}
+			if round > stage.round {
+				stage.round = round
+			}
+		}
+
+		assigned[id] = stage
+		return stage, nil
+	}
+
+	stageOf := make(map[string]string, len(plan.Jobs))
+	unique := make(map[string]gitlabStage)
+	for _, job := range plan.Jobs {
+		stage, err := assign(job.GetID())
+		if err != nil {
+			return nil, nil, err
+		}
+		stageOf[job.GetID()] = stage.name()
+		unique[stage.name()] = stage
+	}
+
+	sorted := make([]gitlabStage, 0, len(unique))
+	for _, stage := range unique {
+		sorted = append(sorted, stage)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].round != sorted[j].round {
+			return sorted[i].round < sorted[j].round
+		}
+		return sorted[i].order < sorted[j].order
+	})
+
+	stages := make([]string, 0, len(sorted))
+	for _, stage := range sorted {
+		stages = append(stages, stage.name())
+	}
+
+	return stageOf, stages, nil
+}
+
+// renderGitLabJob converts a single plan job into a pipeline job
+func renderGitLabJob(job Job, plan *Plan, stage string, ids map[string]bool) (yamlMap, error) {
+	metadata := job.GetMetadata()
+	out := yamlMap{}
+
+	out.Set("stage", stage)
+	out.Set("needs", jobNeeds(job, ids))
+
+	if tags := toStringSlice(metadata["tags"]); len(tags) > 0 {
+		out.Set("tags", tags)
+	}
+
+	name, url, err := jobEnvironment(job, plan)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		environment := yamlMap{}
+		environment.Set("name", name)
+		if url != "" {
+			environment.Set("url", url)
+		}
+		out.Set("environment", environment)
+	}
+
+	// A manual job does not block later stages unless it may not fail, so
+	// an approval gate always sets allow_failure: false
+	requiresApproval, _ := jobValue(job, "requiresApproval")
+	manual := toBool(requiresApproval)
+	if manual {
+		out.Set("when", "manual")
+		out.Set("allow_failure", false)
+	}
+
+	if timeout, ok := toInt(metadata["timeout"]); ok && timeout > 0 {
+		out.Set("timeout", gitlabTimeout(timeout))
+	}
+
+	if toBool(metadata["continueOnError"]) && !manual {
+		out.Set("allow_failure", true)
+	}
+
+	if env := toStringMap(metadata["env"]); len(env) > 0 {
+		out.Set("variables", env)
+	}
+
+	if cache := jobCacheConfig(job); cache != nil {
+		out.Set("cache", yamlMap{
+			{Key: "key", Value: cache.Key},
+			{Key: "paths", Value: cache.Paths},
+		})
+	}
+
+	if preSteps := job.GetPreSteps(); len(preSteps) > 0 {
+		beforeScript := make([]string, 0, len(preSteps))
+		for _, step := range preSteps {
+			beforeScript = append(beforeScript, step.Command)
+		}
+		out.Set("before_script", beforeScript)
+	}
+
+	script := []string{}
+	script = append(script, job.GetCommands()...)
+	for _, step := range job.GetPostSteps() {
+		script = append(script, step.Command)
+	}
+	if len(script) == 0 {
+		script = append(script, fmt.Sprintf("echo \"No commands for %s\"", job.GetID()))
+	}
+	out.Set("script", script)
+
+	if artifacts := jobArtifacts(job); len(artifacts) > 0 {
+		paths := make([]string, 0, len(artifacts))
+		for _, artifact := range artifacts {
+			paths = append(paths, artifact.Path)
+		}
+		out.Set("artifacts", yamlMap{
+			{Key: "name", Value: job.GetID()},
+			{Key: "when", Value: "always"},
+			{Key: "paths", Value: paths},
+		})
+	}
+
+	return out, nil
+}
+
+// gitlabTimeout converts a timeout in minutes to GitLab's duration syntax
+func gitlabTimeout(minutes int) string {
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+	if minutes > 60 {
+		return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
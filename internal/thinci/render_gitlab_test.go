@@ -0,0 +1,129 @@
+package thinci
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourceplane/sourceplane/internal/models"
+	"github.com/sourceplane/sourceplane/internal/parser"
+	"github.com/sourceplane/sourceplane/internal/providers"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestRenderGitLabPipelineGolden renders each plan in testdata/render and
+// compares it with the .gitlab-ci.yml golden file next to it. Run with
+// -update to rewrite the golden files.
+func TestRenderGitLabPipelineGolden(t *testing.T) {
+	plans, err := filepath.Glob(filepath.Join("testdata", "render", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) == 0 {
+		t.Fatal("no plans in testdata/render")
+	}
+
+	for _, planFile := range plans {
+		name := strings.TrimSuffix(filepath.Base(planFile), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(planFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var plan Plan
+			if err := json.Unmarshal(data, &plan); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := RenderGitLabPipeline(&plan)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "render", name+".gitlab-ci.yml")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("rendered pipeline differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// exampleIntents are the intents shipped with the repository, planned
+// against the in-tree helm provider by TestRenderGitLabPipelineExamples
+var exampleIntents = map[string]string{
+	"examples-minimal":   "../../examples/minimal/intent.yaml",
+	"helm-minimal":       "../../providers/helm/examples/minimal/intent.yaml",
+	"helm-microservices": "../../providers/helm/examples/microservices/intent.yaml",
+}
+
+// TestRenderGitLabPipelineExamples generates a production apply plan from each example
+// intent and compares its pipeline with the golden file in
+// testdata/render/examples. Run with -update to rewrite the golden files.
+func TestRenderGitLabPipelineExamples(t *testing.T) {
+	helm, err := providers.LoadProviderFile(filepath.Join("..", "..", "providers", "helm", "provider.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewProviderRegistry()
+	registry.RegisterProvider(helm)
+
+	for name, intentPath := range exampleIntents {
+		t.Run(name, func(t *testing.T) {
+			intent, err := parser.LoadRepository(intentPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := NewPlanner(registry).GeneratePlan(PlanRequest{
+				BaseRef:        "main",
+				HeadRef:        "HEAD",
+				RepositoryPath: filepath.Dir(intentPath),
+				ChangedFiles:   []string{"intent.yaml"},
+				IntentFiles:    []string{intentPath},
+				Target:         "gitlab",
+				Mode:           "apply",
+				Environment:    "production",
+			}, []*models.Repository{intent})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Jobs) == 0 {
+				t.Fatal("plan has no jobs")
+			}
+
+			got, err := RenderGitLabPipeline(plan)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "render", "examples", name+".gitlab-ci.yml")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("rendered pipeline differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package thinci
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJobCacheConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache map[string]any
+		want  *jobCache
+	}{
+		{
+			name:  "resolved key",
+			cache: map[string]any{"key": "helm-api-abc123", "paths": []any{".helm/cache"}},
+			want:  &jobCache{Key: "helm-api-abc123", Paths: []string{".helm/cache"}},
+		},
+		{
+			name:  "unresolved key falls back to provider and component",
+			cache: map[string]any{"key": "helm-{{.releaseName}}-{{.checksum}}", "paths": []any{".helm/cache"}},
+			want:  &jobCache{Key: "helm-api", Paths: []string{".helm/cache"}},
+		},
+		{
+			name:  "unresolved paths are dropped",
+			cache: map[string]any{"paths": []any{"{{.cacheDir}}", ".helm/cache"}},
+			want:  &jobCache{Key: "helm-api", Paths: []string{".helm/cache"}},
+		},
+		{
+			name:  "no resolved paths",
+			cache: map[string]any{"key": "k", "paths": []any{"{{.cacheDir}}"}},
+		},
+		{
+			name:  "disabled",
+			cache: map[string]any{"enabled": false, "key": "k", "paths": []any{".helm/cache"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := Job{"id": "api-apply", "component": "api", "provider": "helm", "cache": tt.cache}
+			if got := jobCacheConfig(job); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jobCacheConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobEnvironment(t *testing.T) {
+	tests := []struct {
+		name        string
+		job         Job
+		environment string
+		wantName    string
+		wantURL     string
+		wantErr     bool
+	}{
+		{
+			name:     "declared environment",
+			job:      Job{"metadata": map[string]any{"environment": map[string]any{"name": "prod", "url": "https://example.com"}}},
+			wantName: "prod",
+			wantURL:  "https://example.com",
+		},
+		{
+			name:        "unresolved name falls back to the plan environment",
+			job:         Job{"metadata": map[string]any{"environment": map[string]any{"name": "{{.environment}}", "url": "https://{{.cluster}}"}}},
+			environment: "staging",
+			wantName:    "staging",
+		},
+		{
+			name:        "approval uses the plan environment",
+			job:         Job{"requiresApproval": true},
+			environment: "prod",
+			wantName:    "prod",
+		},
+		{
+			name:    "approval without an environment",
+			job:     Job{"requiresApproval": true, "metadata": map[string]any{"environment": map[string]any{"name": "{{.environment}}"}}},
+			wantErr: true,
+		},
+		{
+			name: "no environment",
+			job:  Job{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &Plan{Metadata: PlanMetadata{Environment: tt.environment}}
+			name, url, err := jobEnvironment(tt.job, plan)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("jobEnvironment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if name != tt.wantName || url != tt.wantURL {
+				t.Errorf("jobEnvironment() = %q, %q, want %q, %q", name, url, tt.wantName, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestRenderPlanRequiresApprovalEnvironment(t *testing.T) {
+	plan := &Plan{
+		Mode: "apply",
+		Jobs: []Job{{"id": "api-apply", "component": "api", "provider": "helm", "action": "apply", "requiresApproval": true}},
+	}
+
+	for _, target := range []string{"github", "gitlab"} {
+		_, err := RenderPlan(plan, target)
+		if err == nil || !strings.Contains(err.Error(), "requires approval") {
+			t.Errorf("RenderPlan(%s) error = %v, want a missing environment error", target, err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package thinci
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report formats supported by WriteReport
+const (
+	ReportFormatJSON  = "json"
+	ReportFormatJUnit = "junit"
+)
+
+// RunReport is the machine-readable record of a `thinci run`
+type RunReport struct {
+	Target      string         `json:"target"`
+	Mode        string         `json:"mode"`
+	Environment string         `json:"environment,omitempty"`
+	Repository  string         `json:"repository,omitempty"`
+	BaseRef     string         `json:"baseRef,omitempty"`
+	HeadRef     string         `json:"headRef,omitempty"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Summary     map[string]int `json:"summary"`
+	Jobs        []JobResult    `json:"jobs"`
+}
+
+// NewRunReport builds a report for the results of running jobs from plan
+func NewRunReport(plan *Plan, results []JobResult) *RunReport {
+	summary := map[string]int{"total": len(results)}
+	for _, status := range []JobStatus{JobStatusSucceeded, JobStatusFailed, JobStatusTimedOut, JobStatusCancelled, JobStatusSkipped} {
+		summary[string(status)] = 0
+	}
+	for _, result := range results {
+		summary[string(result.Status)]++
+	}
+
+	if results == nil {
+		results = []JobResult{}
+	}
+
+	return &RunReport{
+		Target:      plan.Target,
+		Mode:        plan.Mode,
+		Environment: plan.Metadata.Environment,
+		Repository:  plan.Metadata.Repository,
+		BaseRef:     plan.Metadata.BaseRef,
+		HeadRef:     plan.Metadata.HeadRef,
+		GeneratedAt: time.Now().UTC(),
+		Summary:     summary,
+		Jobs:        results,
+	}
+}
+
+// WriteReport writes the results of a run in the given format
+func WriteReport(w io.Writer, format string, plan *Plan, results []JobResult) error {
+	report := NewRunReport(plan, results)
+	switch format {
+	case ReportFormatJSON:
+		return report.WriteJSON(w)
+	case ReportFormatJUnit:
+		return report.WriteJUnit(w)
+	default:
+		return fmt.Errorf("unsupported report format: %s (expected %s or %s)", format, ReportFormatJSON, ReportFormatJUnit)
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *RunReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
+
+// JUnit XML model. Each job becomes a test suite and each step a test case,
+// which is the shape most CI test-report widgets expect.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML.
+// Failed and timed out steps are failures, cancelled ones are errors.
+func (r *RunReport) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: "thinci"}
+
+	var total time.Duration
+	for _, job := range r.Jobs {
+		suite := junitJobSuite(job)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		total += job.Duration
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitJobSuite converts a job result into a test suite
+func junitJobSuite(job JobResult) junitTestSuite {
+	classname := job.JobID
+	if job.Component != "" && job.Action != "" {
+		classname = job.Component + "." + job.Action
+	}
+
+	suite := junitTestSuite{
+		Name: job.JobID,
+		Time: junitSeconds(job.Duration),
+		Properties: []junitProperty{
+			{Name: "component", Value: job.Component},
+			{Name: "action", Value: job.Action},
+			{Name: "status", Value: string(job.Status)},
+		},
+	}
+	if !job.StartTime.IsZero() {
+		suite.Timestamp = job.StartTime.UTC().Format(time.RFC3339)
+	}
+
+	for _, step := range job.Steps {
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("%s: %s", step.Phase, step.Name),
+			Classname: classname,
+			Time:      junitSeconds(step.Duration),
+			SystemOut: step.Stdout,
+			SystemErr: step.Stderr,
+		}
+		setJUnitOutcome(&tc, step.Status, step.Error, fmt.Sprintf("$ %s\n%s", step.Command, step.Stderr))
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	// Jobs that never ran a step, or failed outside one, still need a test case
+	stepFailed := false
+	for _, step := range job.Steps {
+		if step.Status != JobStatusSucceeded && step.Status != JobStatusSkipped {
+			stepFailed = true
+		}
+	}
+	if len(job.Steps) == 0 || (job.Status != JobStatusSucceeded && !stepFailed) {
+		tc := junitTestCase{Name: job.JobID, Classname: classname, Time: junitSeconds(job.Duration)}
+		message := job.Error
+		if message == "" {
+			message = job.Reason
+		}
+		setJUnitOutcome(&tc, job.Status, message, message)
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	for _, tc := range suite.Cases {
+		suite.Tests++
+		switch {
+		case tc.Failure != nil:
+			suite.Failures++
+		case tc.Error != nil:
+			suite.Errors++
+		case tc.Skipped != nil:
+			suite.Skipped++
+		}
+	}
+
+	return suite
+}
+
+// setJUnitOutcome marks a test case according to a job or step status
+func setJUnitOutcome(tc *junitTestCase, status JobStatus, message, body string) {
+	switch status {
+	case JobStatusFailed, JobStatusTimedOut:
+		tc.Failure = &junitMessage{Message: message, Type: string(status), Body: strings.TrimSpace(body)}
+	case JobStatusCancelled:
+		tc.Error = &junitMessage{Message: message, Type: string(status), Body: strings.TrimSpace(body)}
+	case JobStatusSkipped:
+		tc.Skipped = &junitMessage{Message: message}
+	}
+}
+
+// junitSeconds formats a duration as fractional seconds
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,279 @@
+package thinci
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportFixture returns a plan and the results of running it, covering every
+// job status, retried commands, allowed failures and jobs that never ran
+func reportFixture() (*Plan, []JobResult) {
+	plan := &Plan{
+		Target: "gitlab",
+		Mode:   "apply",
+		Metadata: PlanMetadata{
+			Repository:  "sourceplane/example",
+			BaseRef:     "main",
+			HeadRef:     "feature",
+			Environment: "staging",
+		},
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) time.Time { return start.Add(offset) }
+	step := func(name, phase, command string, status JobStatus, from, to time.Duration) StepResult {
+		return StepResult{
+			Name:      name,
+			Phase:     phase,
+			Command:   command,
+			Status:    status,
+			StartTime: at(from),
+			EndTime:   at(to),
+			Duration:  to - from,
+		}
+	}
+
+	lint := step("lint", StepPhaseMain, "helm lint charts/api", JobStatusSucceeded, 0, 1500*time.Millisecond)
+	lint.Stdout = "1 chart(s) linted, 0 chart(s) failed"
+
+	migrate := step("migrate", StepPhaseMain, "./migrate.sh", JobStatusFailed, time.Second, 4*time.Second)
+	migrate.ExitCode = 2
+	migrate.Attempts = 2
+	migrate.Error = "exit status 2"
+	migrate.Stderr = "migration 0042 failed: relation exists"
+
+	deploy := step("deploy", StepPhaseMain, "helm upgrade web charts/web", JobStatusTimedOut, 2*time.Second, 62*time.Second)
+	deploy.ExitCode = -1
+	deploy.Error = "command timed out after 1m0s"
+
+	scan := step("scan", StepPhaseMain, "trivy image web", JobStatusFailed, 0, 500*time.Millisecond)
+	scan.ExitCode = 1
+	scan.Error = "exit status 1"
+
+	results := []JobResult{
+		{
+			JobID:     "api-lint",
+			Component: "api",
+			Action:    "lint",
+			Status:    JobStatusSucceeded,
+			StartTime: at(0),
+			EndTime:   at(2 * time.Second),
+			Duration:  2 * time.Second,
+			Steps: []StepResult{
+				step("setup", StepPhasePre, "helm dependency build charts/api", JobStatusSucceeded, 0, 0),
+				lint,
+			},
+			Outputs: map[string]string{"chart": "charts/api"},
+		},
+		{
+			JobID:     "db-migrate",
+			Component: "db",
+			Action:    "migrate",
+			Status:    JobStatusFailed,
+			StartTime: at(time.Second),
+			EndTime:   at(4 * time.Second),
+			Duration:  3 * time.Second,
+			Error:     "commands failed: exit status 2",
+			Attempts: []AttemptRecord{
+				{Step: "migrate", Attempt: 1, ExitCode: 2, Error: "exit status 2", Duration: time.Second},
+				{Step: "migrate", Attempt: 2, ExitCode: 2, Error: "exit status 2", Duration: 2 * time.Second},
+			},
+			Steps: []StepResult{migrate},
+		},
+		{
+			JobID:     "api-deploy",
+			Component: "api",
+			Action:    "deploy",
+			Status:    JobStatusSkipped,
+			Reason:    "dependency db-migrate failed",
+		},
+		{
+			JobID:     "web-deploy",
+			Component: "web",
+			Action:    "deploy",
+			Status:    JobStatusTimedOut,
+			StartTime: at(2 * time.Second),
+			EndTime:   at(62 * time.Second),
+			Duration:  time.Minute,
+			Error:     "commands failed: command timed out after 1m0s",
+			Steps:     []StepResult{deploy},
+		},
+		{
+			JobID:          "web-scan",
+			Component:      "web",
+			Action:         "scan",
+			Status:         JobStatusFailed,
+			StartTime:      at(0),
+			EndTime:        at(500 * time.Millisecond),
+			Duration:       500 * time.Millisecond,
+			Error:          "commands failed: exit status 1",
+			AllowedFailure: true,
+			Steps:          []StepResult{scan},
+		},
+		{
+			JobID:     "cache-warm",
+			Component: "cache",
+			Action:    "warm",
+			Status:    JobStatusCancelled,
+			StartTime: at(3 * time.Second),
+			EndTime:   at(3 * time.Second),
+			Error:     "cancelled: received interrupt signal",
+		},
+	}
+	return plan, results
+}
+
+// TestRunReportGolden writes the fixture in each report format and compares
+// it with the golden files in testdata/report. Run with -update to rewrite them.
+func TestRunReportGolden(t *testing.T) {
+	plan, results := reportFixture()
+	report := NewRunReport(plan, results)
+	report.GeneratedAt = time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC)
+
+	formats := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+	}{
+		{"run.json", func(b *bytes.Buffer) error { return report.WriteJSON(b) }},
+		{"run.junit.xml", func(b *bytes.Buffer) error { return report.WriteJUnit(b) }},
+	}
+
+	for _, format := range formats {
+		t.Run(format.name, func(t *testing.T) {
+			var got bytes.Buffer
+			if err := format.write(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "report", format.name)
+			if *updateGolden {
+				if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("report differs from %s:\n%s", golden, got.String())
+			}
+		})
+	}
+}
+
+func TestRunReportJSON(t *testing.T) {
+	plan, results := reportFixture()
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, ReportFormatJSON, plan, results); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Environment string           `json:"environment"`
+		Summary     map[string]int   `json:"summary"`
+		Jobs        []map[string]any `json:"jobs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	wantSummary := map[string]int{"total": 6, "succeeded": 1, "failed": 2, "timed-out": 1, "cancelled": 1, "skipped": 1}
+	for status, want := range wantSummary {
+		if report.Summary[status] != want {
+			t.Errorf("summary[%s] = %d, want %d", status, report.Summary[status], want)
+		}
+	}
+	if report.Environment != "staging" {
+		t.Errorf("environment = %q, want staging", report.Environment)
+	}
+
+	jobs := make(map[string]map[string]any)
+	for _, job := range report.Jobs {
+		jobs[job["jobId"].(string)] = job
+	}
+	// Durations are in seconds
+	if got := jobs["db-migrate"]["duration"]; got != 3.0 {
+		t.Errorf("db-migrate duration = %v, want 3", got)
+	}
+	if got := jobs["db-migrate"]["attempts"].([]any)[1].(map[string]any)["duration"]; got != 2.0 {
+		t.Errorf("db-migrate attempt duration = %v, want 2", got)
+	}
+	// Jobs that never started have no times
+	if _, ok := jobs["api-deploy"]["startTime"]; ok {
+		t.Errorf("skipped job has a start time: %v", jobs["api-deploy"])
+	}
+	if jobs["web-scan"]["allowedFailure"] != true {
+		t.Errorf("web-scan is not marked as an allowed failure: %v", jobs["web-scan"])
+	}
+
+	// A run without results still has a jobs list
+	buf.Reset()
+	if err := WriteReport(&buf, ReportFormatJSON, plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"jobs": []`) {
+		t.Errorf("empty report has no jobs list:\n%s", buf.String())
+	}
+}
+
+func TestRunReportJUnit(t *testing.T) {
+	plan, results := reportFixture()
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, ReportFormatJUnit, plan, results); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("report is not valid XML: %v\n%s", err, buf.String())
+	}
+
+	if suites.Tests != 7 || suites.Failures != 3 || suites.Errors != 1 || suites.Skipped != 1 {
+		t.Errorf("totals = %d tests, %d failures, %d errors, %d skipped, want 7, 3, 1, 1",
+			suites.Tests, suites.Failures, suites.Errors, suites.Skipped)
+	}
+	if suites.Time != "65.500" {
+		t.Errorf("total time = %s, want 65.500", suites.Time)
+	}
+
+	// Each job is a suite, each step a test case named after its phase
+	cases := make(map[string][]junitTestCase)
+	for _, suite := range suites.Suites {
+		cases[suite.Name] = suite.Cases
+	}
+	if len(cases) != len(results) {
+		t.Fatalf("suites = %d, want one per job", len(cases))
+	}
+	if got := cases["api-lint"]; len(got) != 2 || got[0].Name != "pre: setup" || got[1].Name != "main: lint" || got[1].Classname != "api.lint" {
+		t.Errorf("api-lint cases = %+v, want pre: setup and main: lint", got)
+	}
+	if got := cases["db-migrate"][0].Failure; got == nil || got.Message != "exit status 2" || got.Body != "$ ./migrate.sh\nmigration 0042 failed: relation exists" {
+		t.Errorf("db-migrate failure = %+v, want the command and its stderr", got)
+	}
+	if got := cases["web-deploy"][0].Failure; got == nil || got.Type != "timed-out" {
+		t.Errorf("web-deploy failure = %+v, want a timed-out failure", got)
+	}
+	// Jobs without steps get a single case for the job itself
+	if got := cases["api-deploy"]; len(got) != 1 || got[0].Skipped == nil || got[0].Skipped.Message != "dependency db-migrate failed" {
+		t.Errorf("api-deploy cases = %+v, want one skipped case with the reason", got)
+	}
+	if got := cases["cache-warm"]; len(got) != 1 || got[0].Error == nil {
+		t.Errorf("cache-warm cases = %+v, want one error case", got)
+	}
+}
+
+func TestWriteReportUnsupportedFormat(t *testing.T) {
+	plan, results := reportFixture()
+	err := WriteReport(&bytes.Buffer{}, "html", plan, results)
+	if err == nil || !strings.Contains(err.Error(), "unsupported report format: html") {
+		t.Errorf("WriteReport() error = %v, want an unsupported format error", err)
+	}
+}
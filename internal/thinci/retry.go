@@ -0,0 +1,197 @@
+package thinci
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Retry backoff strategies
+const (
+	BackoffFixed       = "fixed"
+	BackoffLinear      = "linear"
+	BackoffExponential = "exponential"
+)
+
+// Retry scopes
+const (
+	RetryScopeCommand = "command" // retry each failing command on its own
+	RetryScopeJob     = "job"     // re-run the whole job from its first pre-step
+)
+
+// Default retry timings when a policy omits them
+const (
+	defaultRetryDelay    = 2 * time.Second
+	defaultRetryMaxDelay = time.Minute
+)
+
+// RetryPolicy describes how failed commands of a job are retried.
+// It is read from a job's `retryPolicy` field, e.g.
+//
+//	retryPolicy:
+//	  maxAttempts: 3
+//	  backoff: exponential
+//	  delay: 5s
+//	  maxDelay: 1m
+//	  jitter: 0.2
+//	  retryOn: [1, 137]
+//	  scope: command
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     string
+	Delay       time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64 // fraction of the delay to randomize, 0 disables jitter
+	RetryOn     []int   // exit codes to retry; empty retries any failure
+	Scope       string
+}
+
+// ParseRetryPolicy converts a decoded retryPolicy value into a RetryPolicy.
+// It returns nil when the value is absent or allows only a single attempt.
+func ParseRetryPolicy(val any) (*RetryPolicy, error) {
+	raw, ok := val.(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil, nil
+	}
+
+	policy := &RetryPolicy{
+		MaxAttempts: 1,
+		Backoff:     BackoffFixed,
+		Delay:       defaultRetryDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+		Scope:       RetryScopeCommand,
+	}
+
+	if v, exists := raw["maxAttempts"]; exists {
+		n, ok := toInt(v)
+		if !ok || n < 1 {
+			return nil, fmt.Errorf("retryPolicy.maxAttempts must be a positive integer, got %v", v)
+		}
+		policy.MaxAttempts = n
+	}
+
+	if v := getString(raw, "backoff"); v != "" {
+		switch v {
+		case BackoffFixed, BackoffLinear, BackoffExponential:
+			policy.Backoff = v
+		default:
+			return nil, fmt.Errorf("retryPolicy.backoff must be one of fixed, linear, exponential, got %q", v)
+		}
+	}
+
+	if v, exists := raw["delay"]; exists {
+		d, err := parseDuration(v, time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("retryPolicy.delay: %w", err)
+		}
+		policy.Delay = d
+	}
+
+	if v, exists := raw["maxDelay"]; exists {
+		d, err := parseDuration(v, time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("retryPolicy.maxDelay: %w", err)
+		}
+		policy.MaxDelay = d
+	}
+
+	switch v := raw["jitter"].(type) {
+	case nil:
+	case bool:
+		if v {
+			policy.Jitter = 0.5
+		}
+	case float64:
+		policy.Jitter = v
+	case int:
+		policy.Jitter = float64(v)
+	default:
+		return nil, fmt.Errorf("retryPolicy.jitter must be a boolean or a fraction, got %v", v)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return nil, fmt.Errorf("retryPolicy.jitter must be between 0 and 1, got %v", policy.Jitter)
+	}
+
+	if v, exists := raw["retryOn"]; exists {
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("retryPolicy.retryOn must be a list of exit codes")
+		}
+		for _, item := range items {
+			code, ok := toInt(item)
+			if !ok {
+				return nil, fmt.Errorf("retryPolicy.retryOn: invalid exit code %v", item)
+			}
+			policy.RetryOn = append(policy.RetryOn, code)
+		}
+	}
+
+	if v := getString(raw, "scope"); v != "" {
+		if v != RetryScopeCommand && v != RetryScopeJob {
+			return nil, fmt.Errorf("retryPolicy.scope must be %q or %q, got %q", RetryScopeCommand, RetryScopeJob, v)
+		}
+		policy.Scope = v
+	}
+
+	if policy.MaxAttempts < 2 {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// ShouldRetry reports whether a failure with the given exit code may be retried
+func (p *RetryPolicy) ShouldRetry(exitCode int) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+	for _, code := range p.RetryOn {
+		if code == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// DelayFor returns how long to wait after the given failed attempt (1-based)
+func (p *RetryPolicy) DelayFor(attempt int) time.Duration {
+	delay := p.Delay
+	switch p.Backoff {
+	case BackoffLinear:
+		delay = p.Delay * time.Duration(attempt)
+	case BackoffExponential:
+		delay = time.Duration(float64(p.Delay) * math.Pow(2, float64(attempt-1)))
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && delay > 0 {
+		spread := float64(delay) * p.Jitter
+		delay = time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+	}
+
+	return delay
+}
+
+// parseDuration accepts Go duration strings ("30s", "2m") or plain numbers in the given unit
+func parseDuration(val any, unit time.Duration) (time.Duration, error) {
+	switch v := val.(type) {
+	case string:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(n * float64(unit)), nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		return d, nil
+	case int:
+		return time.Duration(v) * unit, nil
+	case float64:
+		return time.Duration(v * float64(unit)), nil
+	}
+	return 0, fmt.Errorf("invalid duration %v", val)
+}
@@ -0,0 +1,276 @@
+package thinci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     any
+		want    *RetryPolicy
+		wantErr string
+	}{
+		{name: "absent", raw: nil},
+		{name: "single attempt", raw: map[string]any{"maxAttempts": 1}},
+		{
+			name: "defaults",
+			raw:  map[string]any{"maxAttempts": 3},
+			want: &RetryPolicy{MaxAttempts: 3, Backoff: BackoffFixed, Delay: 2 * time.Second, MaxDelay: time.Minute, Scope: RetryScopeCommand},
+		},
+		{
+			name: "all fields",
+			raw: map[string]any{
+				"maxAttempts": 4,
+				"backoff":     "exponential",
+				"delay":       "500ms",
+				"maxDelay":    30,
+				"jitter":      0.2,
+				"retryOn":     []any{1, 137},
+				"scope":       "job",
+			},
+			want: &RetryPolicy{
+				MaxAttempts: 4,
+				Backoff:     BackoffExponential,
+				Delay:       500 * time.Millisecond,
+				MaxDelay:    30 * time.Second,
+				Jitter:      0.2,
+				RetryOn:     []int{1, 137},
+				Scope:       RetryScopeJob,
+			},
+		},
+		{
+			name: "jitter enabled",
+			raw:  map[string]any{"maxAttempts": 2, "jitter": true},
+			want: &RetryPolicy{MaxAttempts: 2, Backoff: BackoffFixed, Delay: 2 * time.Second, MaxDelay: time.Minute, Jitter: 0.5, Scope: RetryScopeCommand},
+		},
+		{name: "zero attempts", raw: map[string]any{"maxAttempts": 0}, wantErr: "maxAttempts must be a positive integer"},
+		{name: "unknown backoff", raw: map[string]any{"maxAttempts": 2, "backoff": "random"}, wantErr: "backoff must be one of"},
+		{name: "invalid delay", raw: map[string]any{"maxAttempts": 2, "delay": "soon"}, wantErr: `retryPolicy.delay: invalid duration "soon"`},
+		{name: "jitter out of range", raw: map[string]any{"maxAttempts": 2, "jitter": 2}, wantErr: "jitter must be between 0 and 1"},
+		{name: "retryOn not a list", raw: map[string]any{"maxAttempts": 2, "retryOn": 1}, wantErr: "retryOn must be a list"},
+		{name: "invalid exit code", raw: map[string]any{"maxAttempts": 2, "retryOn": []any{"oom"}}, wantErr: "invalid exit code oom"},
+		{name: "unknown scope", raw: map[string]any{"maxAttempts": 2, "scope": "step"}, wantErr: "scope must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRetryPolicy(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseRetryPolicy() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRetryPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	tests := []struct {
+		retryOn  []int
+		exitCode int
+		want     bool
+	}{
+		{retryOn: nil, exitCode: 1, want: true},
+		{retryOn: nil, exitCode: 137, want: true},
+		{retryOn: []int{1, 137}, exitCode: 137, want: true},
+		{retryOn: []int{1, 137}, exitCode: 1, want: true},
+		{retryOn: []int{1, 137}, exitCode: 2, want: false},
+		{retryOn: []int{137}, exitCode: -1, want: false},
+	}
+
+	for _, tt := range tests {
+		policy := &RetryPolicy{MaxAttempts: 2, RetryOn: tt.retryOn}
+		if got := policy.ShouldRetry(tt.exitCode); got != tt.want {
+			t.Errorf("ShouldRetry(%d) with retryOn %v = %v, want %v", tt.exitCode, tt.retryOn, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayFor(t *testing.T) {
+	tests := []struct {
+		backoff  string
+		maxDelay time.Duration
+		want     []time.Duration // delays after attempts 1, 2, 3 and 4
+	}{
+		{backoff: BackoffFixed, want: []time.Duration{time.Second, time.Second, time.Second, time.Second}},
+		{backoff: BackoffLinear, want: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}},
+		{backoff: BackoffExponential, want: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{backoff: BackoffExponential, maxDelay: 3 * time.Second, want: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}},
+		{backoff: BackoffLinear, maxDelay: 2500 * time.Millisecond, want: []time.Duration{time.Second, 2 * time.Second, 2500 * time.Millisecond, 2500 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		policy := &RetryPolicy{MaxAttempts: 5, Backoff: tt.backoff, Delay: time.Second, MaxDelay: tt.maxDelay}
+		for i, want := range tt.want {
+			if got := policy.DelayFor(i + 1); got != want {
+				t.Errorf("%s backoff, maxDelay %s: DelayFor(%d) = %s, want %s", tt.backoff, tt.maxDelay, i+1, got, want)
+			}
+		}
+	}
+
+	policy := &RetryPolicy{MaxAttempts: 5, Backoff: BackoffExponential, Delay: time.Second, Jitter: 0.25}
+	for i := 0; i < 100; i++ {
+		got := policy.DelayFor(2)
+		if got < 1500*time.Millisecond || got > 2500*time.Millisecond {
+			t.Fatalf("DelayFor(2) with jitter 0.25 = %s, want within 25%% of 2s", got)
+		}
+	}
+}
+
+// TestExecutorRetry runs a command that fails with exit code 3 until its
+// third try, counting tries in a file
+func TestExecutorRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       map[string]any
+		wantStatus   JobStatus
+		wantTries    int
+		wantAttempts []int // exit codes of the recorded tries of the command or job
+	}{
+		{
+			name:         "succeeds on the last attempt",
+			policy:       map[string]any{"maxAttempts": 3, "delay": 0},
+			wantStatus:   JobStatusSucceeded,
+			wantTries:    3,
+			wantAttempts: []int{3, 3, 0},
+		},
+		{
+			name:         "attempts exhausted",
+			policy:       map[string]any{"maxAttempts": 2, "delay": 0},
+			wantStatus:   JobStatusFailed,
+			wantTries:    2,
+			wantAttempts: []int{3, 3},
+		},
+		{
+			name:         "retryOn matches",
+			policy:       map[string]any{"maxAttempts": 3, "delay": 0, "retryOn": []any{1, 3}},
+			wantStatus:   JobStatusSucceeded,
+			wantTries:    3,
+			wantAttempts: []int{3, 3, 0},
+		},
+		{
+			name:         "retryOn does not match",
+			policy:       map[string]any{"maxAttempts": 3, "delay": 0, "retryOn": []any{137}},
+			wantStatus:   JobStatusFailed,
+			wantTries:    1,
+			wantAttempts: []int{3},
+		},
+		{
+			name:         "job scope reruns pre-steps",
+			policy:       map[string]any{"maxAttempts": 3, "delay": 0, "scope": "job"},
+			wantStatus:   JobStatusSucceeded,
+			wantTries:    3,
+			wantAttempts: []int{3, 3, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tries := filepath.Join(dir, "tries")
+			preSteps := filepath.Join(dir, "pre-steps")
+
+			executor := NewExecutor(false, false)
+			executor.SetOutput(io.Discard, io.Discard)
+			executor.SetStateDir(filepath.Join(dir, "state"))
+			result := executor.RunJob(context.Background(), Job{
+				"id":          "flaky",
+				"retryPolicy": tt.policy,
+				"preSteps":    []any{map[string]any{"name": "setup", "command": fmt.Sprintf("echo . >> %s", preSteps)}},
+				"commands": []any{
+					fmt.Sprintf(`echo . >> %s; [ "$(wc -l < %s)" -ge 3 ] || exit 3`, tries, tries),
+				},
+			})
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %s (%s), want %s", result.Status, result.Error, tt.wantStatus)
+			}
+			if got := countLines(t, tries); got != tt.wantTries {
+				t.Errorf("command ran %d times, want %d", got, tt.wantTries)
+			}
+
+			var exitCodes []int
+			for _, attempt := range result.Attempts {
+				if attempt.Step == "setup" {
+					continue
+				}
+				exitCodes = append(exitCodes, attempt.ExitCode)
+			}
+			if !reflect.DeepEqual(exitCodes, tt.wantAttempts) {
+				t.Errorf("attempt exit codes = %v, want %v", exitCodes, tt.wantAttempts)
+			}
+
+			wantPreSteps := 1
+			if tt.policy["scope"] == RetryScopeJob {
+				wantPreSteps = tt.wantTries
+			}
+			if got := countLines(t, preSteps); got != wantPreSteps {
+				t.Errorf("pre-steps ran %d times, want %d", got, wantPreSteps)
+			}
+		})
+	}
+}
+
+func TestExecutorRetryBackoff(t *testing.T) {
+	dir := t.TempDir()
+	executor := NewExecutor(false, false)
+	executor.SetOutput(io.Discard, io.Discard)
+	executor.SetStateDir(filepath.Join(dir, "state"))
+
+	start := time.Now()
+	result := executor.RunJob(context.Background(), Job{
+		"id":          "failing",
+		"retryPolicy": map[string]any{"maxAttempts": 3, "backoff": "linear", "delay": "100ms"},
+		"commands":    []any{"exit 1"},
+	})
+	elapsed := time.Since(start)
+
+	// 100ms after the first attempt and 200ms after the second
+	if elapsed < 300*time.Millisecond {
+		t.Errorf("three attempts took %s, want at least 300ms of backoff", elapsed)
+	}
+	if len(result.Attempts) != 3 || result.Status != JobStatusFailed {
+		t.Errorf("result = %s after %d attempts, want failed after 3", result.Status, len(result.Attempts))
+	}
+
+	// Cancelling the run interrupts the backoff
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	executor.RunJob(ctx, Job{
+		"id":          "failing",
+		"retryPolicy": map[string]any{"maxAttempts": 3, "delay": "1m"},
+		"commands":    []any{"exit 1"},
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled run took %s, want the backoff to stop", elapsed)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
@@ -0,0 +1,384 @@
+package thinci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// JobStatus is the final state of a job in a plan run
+type JobStatus string
+
+const (
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusSkipped   JobStatus = "skipped"
+	JobStatusTimedOut  JobStatus = "timed-out"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// JobResult records the outcome of running a single job
+type JobResult struct {
+	JobID     string        `json:"jobId"`
+	Component string        `json:"component"`
+	Action    string        `json:"action"`
+	Status    JobStatus     `json:"status"`
+	StartTime time.Time     `json:"startTime,omitempty"`
+	EndTime   time.Time     `json:"endTime,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+
+	// AllowedFailure is set when the job failed but its metadata sets continueOnError
+	AllowedFailure bool `json:"allowedFailure,omitempty"`
+
+	// Reason explains why a job was skipped
+	Reason string `json:"reason,omitempty"`
+
+	// Attempts records each try of commands governed by a retry policy
+	Attempts []AttemptRecord `json:"attempts,omitempty"`
+
+	// Steps records every command run by the job, in execution order
+	Steps []StepResult `json:"steps,omitempty"`
+
+	// Outputs holds the values the job wrote to $SP_OUTPUT
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// Step phases, matching the sections of a job
+const (
+	StepPhasePre  = "pre"
+	StepPhaseMain = "main"
+	StepPhasePost = "post"
+)
+
+// StepResult records the outcome of a single pre-step, command or post-step
+type StepResult struct {
+	Name      string        `json:"name"`
+	Phase     string        `json:"phase"`
+	Command   string        `json:"command"` // with templates resolved
+	Status    JobStatus     `json:"status"`
+	ExitCode  int           `json:"exitCode"`
+	StartTime time.Time     `json:"startTime"`
+	EndTime   time.Time     `json:"endTime"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+
+	// Attempts is the number of tries made under a command-scoped retry policy
+	Attempts int `json:"attempts,omitempty"`
+
+	// Stdout and Stderr hold the last lines of the command's output
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// AttemptRecord is a single try of a retried command or job
+type AttemptRecord struct {
+	Step     string        `json:"step"`
+	Attempt  int           `json:"attempt"`
+	ExitCode int           `json:"exitCode"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// MarshalJSON encodes the duration in seconds and omits the times of jobs that never started
+func (r JobResult) MarshalJSON() ([]byte, error) {
+	type alias JobResult
+	out := struct {
+		alias
+		StartTime *time.Time `json:"startTime,omitempty"`
+		EndTime   *time.Time `json:"endTime,omitempty"`
+		Duration  float64    `json:"duration"`
+	}{alias: alias(r), Duration: r.Duration.Seconds()}
+	if !r.StartTime.IsZero() {
+		out.StartTime = &r.StartTime
+		out.EndTime = &r.EndTime
+	}
+	return json.Marshal(out)
+}
+
+// MarshalJSON encodes the duration in seconds
+func (s StepResult) MarshalJSON() ([]byte, error) {
+	type alias StepResult
+	return json.Marshal(struct {
+		alias
+		Duration float64 `json:"duration"`
+	}{alias(s), s.Duration.Seconds()})
+}
+
+// MarshalJSON encodes the duration in seconds
+func (a AttemptRecord) MarshalJSON() ([]byte, error) {
+	type alias AttemptRecord
+	return json.Marshal(struct {
+		alias
+		Duration float64 `json:"duration"`
+	}{alias(a), a.Duration.Seconds()})
+}
+
+// PlanRunOptions controls how a whole plan is executed
+type PlanRunOptions struct {
+	Verbose bool
+	DryRun  bool
+
+	// Parallelism is the maximum number of jobs running at once
+	Parallelism int
+
+	// KeepGoing continues running jobs on branches unrelated to a failure
+	// instead of stopping the whole run
+	KeepGoing bool
+
+	// GracePeriod is how long interrupted commands may take to exit before being killed
+	GracePeriod time.Duration
+
+	// StrictTemplates makes templates referencing missing keys an error
+	StrictTemplates bool
+
+	// StateDir is where job outputs are persisted (default DefaultStateDir)
+	StateDir string
+}
+
+// PlanRunner executes all jobs of a plan respecting their dependencies
+type PlanRunner struct {
+	plan    *Plan
+	options PlanRunOptions
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// NewPlanRunner creates a new plan runner
+func NewPlanRunner(plan *Plan, options PlanRunOptions) *PlanRunner {
+	if options.Parallelism < 1 {
+		options.Parallelism = 1
+	}
+	return &PlanRunner{
+		plan:    plan,
+		options: options,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}
+}
+
+// jobCompletion is sent by workers when a job finishes
+type jobCompletion struct {
+	id     string
+	result JobResult
+}
+
+// Run schedules jobs by dependsOn, running independent jobs concurrently.
+// Cancelling ctx stops running jobs and skips the ones not yet started.
+// Results are returned in plan order.
+func (r *PlanRunner) Run(ctx context.Context) ([]JobResult, error) {
+	jobs := make(map[string]Job, len(r.plan.Jobs))
+	order := make([]string, 0, len(r.plan.Jobs))
+	for _, job := range r.plan.Jobs {
+		id := job.GetID()
+		if id == "" {
+			return nil, fmt.Errorf("plan contains a job without an id")
+		}
+		if _, exists := jobs[id]; exists {
+			return nil, fmt.Errorf("duplicate job id in plan: %s", id)
+		}
+		jobs[id] = job
+		order = append(order, id)
+	}
+
+	// Build the dependency graph, ignoring dependencies outside the plan
+	pending := make(map[string]int, len(jobs))
+	dependents := make(map[string][]string, len(jobs))
+	for _, id := range order {
+		for _, dep := range jobs[id].GetDependsOn() {
+			if _, ok := jobs[dep]; !ok {
+				fmt.Fprintf(r.stderr, "Warning: job %s depends on %s which is not in the plan\n", id, dep)
+				continue
+			}
+			pending[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	results := make(map[string]JobResult, len(jobs))
+	ready := []string{}
+	for _, id := range order {
+		if pending[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	// Serialize output from concurrent jobs line by line
+	stdout := &lockedWriter{writer: r.stdout}
+	stderr := &lockedWriter{writer: r.stderr}
+
+	completions := make(chan jobCompletion)
+	running := 0
+	stopping := false
+
+	var skip func(id, reason string)
+	skip = func(id, reason string) {
+		if _, done := results[id]; done {
+			return
+		}
+		job := jobs[id]
+		results[id] = JobResult{
+			JobID:     id,
+			Component: job.GetComponent(),
+			Action:    job.GetAction(),
+			Status:    JobStatusSkipped,
+			Reason:    reason,
+		}
+		for _, dependent := range dependents[id] {
+			skip(dependent, fmt.Sprintf("dependency %s did not succeed", id))
+		}
+	}
+
+	for len(results) < len(jobs) {
+		// Start as many ready jobs as the parallelism limit allows
+		if ctx.Err() != nil {
+			stopping = true
+		}
+		for !stopping && running < r.options.Parallelism && len(ready) > 0 {
+			id := ready[0]
+			ready = ready[1:]
+			if _, done := results[id]; done {
+				continue
+			}
+
+			running++
+			go func(id string, job Job) {
+				completions <- jobCompletion{id: id, result: r.runJob(ctx, job, stdout, stderr)}
+			}(id, jobs[id])
+		}
+
+		if running == 0 {
+			// Nothing left that can run: the run was stopped or the graph has a cycle
+			for _, id := range order {
+				if _, done := results[id]; done {
+					continue
+				}
+				if ctx.Err() != nil {
+					skip(id, "run cancelled")
+				} else if stopping {
+					skip(id, "run stopped after an earlier failure")
+				} else {
+					skip(id, "unresolvable dependency cycle")
+				}
+			}
+			break
+		}
+
+		completion := <-completions
+		running--
+		results[completion.id] = completion.result
+
+		if completion.result.Status != JobStatusSucceeded && !completion.result.AllowedFailure {
+			if r.options.KeepGoing {
+				for _, dependent := range dependents[completion.id] {
+					skip(dependent, fmt.Sprintf("dependency %s failed", completion.id))
+				}
+			} else {
+				stopping = true
+			}
+			continue
+		}
+
+		for _, dependent := range dependents[completion.id] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	ordered := make([]JobResult, 0, len(order))
+	failed := 0
+	for _, id := range order {
+		result := results[id]
+		if result.Status != JobStatusSucceeded && result.Status != JobStatusSkipped && !result.AllowedFailure {
+			failed++
+		}
+		ordered = append(ordered, result)
+	}
+
+	if failed > 0 {
+		return ordered, fmt.Errorf("%d job(s) failed", failed)
+	}
+	return ordered, nil
+}
+
+// runJob executes a single job with output prefixed by its ID
+func (r *PlanRunner) runJob(ctx context.Context, job Job, stdout, stderr io.Writer) JobResult {
+	id := job.GetID()
+	prefix := fmt.Sprintf("[%s] ", id)
+
+	executor := NewExecutor(r.options.Verbose, r.options.DryRun)
+	executor.SetOutput(
+		&prefixWriter{prefix: prefix, writer: stdout},
+		&prefixWriter{prefix: prefix, writer: stderr},
+	)
+
+	if r.options.GracePeriod > 0 {
+		executor.SetGracePeriod(r.options.GracePeriod)
+	}
+	executor.SetStrictTemplates(r.options.StrictTemplates)
+	if r.options.StateDir != "" {
+		executor.SetStateDir(r.options.StateDir)
+	}
+
+	return executor.RunJob(ctx, job)
+}
+
+// PrintRunSummary writes a table of job statuses and durations
+func PrintRunSummary(w io.Writer, results []JobResult) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run Summary")
+	fmt.Fprintln(w, "───────────")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tSTATUS\tDURATION\tDETAILS")
+
+	counts := make(map[JobStatus]int)
+	for _, result := range results {
+		counts[result.Status]++
+
+		status := string(result.Status)
+		if result.AllowedFailure {
+			status += " (allowed)"
+		}
+
+		duration := "-"
+		if result.Status != JobStatusSkipped {
+			duration = result.Duration.Round(time.Millisecond).String()
+		}
+
+		details := result.Reason
+		if result.Error != "" {
+			details = result.Error
+		}
+		if len(result.Attempts) > 1 {
+			details = strings.TrimSpace(fmt.Sprintf("%s (%d attempts)", details, len(result.Attempts)))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.JobID, status, duration, details)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d succeeded, %d failed, %d timed out, %d cancelled, %d skipped\n",
+		counts[JobStatusSucceeded], counts[JobStatusFailed], counts[JobStatusTimedOut],
+		counts[JobStatusCancelled], counts[JobStatusSkipped])
+}
+
+// lockedWriter serializes writes from concurrent jobs
+type lockedWriter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.writer.Write(p)
+}
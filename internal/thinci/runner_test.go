@@ -0,0 +1,251 @@
+package thinci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// eventLog collects the start and end of jobs run by a plan, in the order
+// they happened
+type eventLog struct {
+	path string
+}
+
+func newEventLog(t *testing.T) *eventLog {
+	return &eventLog{path: filepath.Join(t.TempDir(), "events")}
+}
+
+// job returns a job that logs its start, sleeps, logs its end and exits with
+// the given code
+func (l *eventLog) job(id string, exitCode int, dependsOn ...string) Job {
+	deps := make([]any, 0, len(dependsOn))
+	for _, dep := range dependsOn {
+		deps = append(deps, dep)
+	}
+	return Job{
+		"id":        id,
+		"dependsOn": deps,
+		"commands": []any{
+			fmt.Sprintf(`echo "start %s" >> %s; sleep 0.1; echo "end %s" >> %s; exit %d`, id, l.path, id, l.path, exitCode),
+		},
+	}
+}
+
+func (l *eventLog) events(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func runPlan(t *testing.T, plan *Plan, options PlanRunOptions) ([]JobResult, error) {
+	t.Helper()
+	options.StateDir = t.TempDir()
+	runner := NewPlanRunner(plan, options)
+	runner.stdout, runner.stderr = io.Discard, io.Discard
+	return runner.Run(context.Background())
+}
+
+func TestPlanRunnerOrder(t *testing.T) {
+	log := newEventLog(t)
+	// A diamond, listed with dependents first, next to an unrelated job
+	plan := &Plan{Jobs: []Job{
+		log.job("deploy", 0, "build-api", "build-web"),
+		log.job("build-api", 0, "lint"),
+		log.job("build-web", 0, "lint"),
+		log.job("lint", 0),
+		log.job("docs", 0),
+	}}
+
+	results, err := runPlan(t, plan, PlanRunOptions{Parallelism: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range results {
+		if id := plan.Jobs[i].GetID(); result.JobID != id || result.Status != JobStatusSucceeded {
+			t.Errorf("result %d = %s %s, want %s succeeded", i, result.JobID, result.Status, id)
+		}
+	}
+
+	position := make(map[string]int)
+	for i, event := range log.events(t) {
+		position[event] = i
+	}
+	if len(position) != 2*len(plan.Jobs) {
+		t.Fatalf("events = %v, want a start and an end for every job", log.events(t))
+	}
+	for _, job := range plan.Jobs {
+		for _, dep := range job.GetDependsOn() {
+			if position["end "+dep] > position["start "+job.GetID()] {
+				t.Errorf("%s started before its dependency %s finished: %v", job.GetID(), dep, log.events(t))
+			}
+		}
+	}
+	// Independent jobs run concurrently
+	if position["start build-web"] > position["end build-api"] && position["start build-api"] > position["end build-web"] {
+		t.Errorf("build-api and build-web did not overlap: %v", log.events(t))
+	}
+}
+
+func TestPlanRunnerParallelism(t *testing.T) {
+	for _, parallelism := range []int{1, 2, 3} {
+		t.Run(fmt.Sprintf("parallelism %d", parallelism), func(t *testing.T) {
+			log := newEventLog(t)
+			plan := &Plan{}
+			for i := 0; i < 6; i++ {
+				plan.Jobs = append(plan.Jobs, log.job(fmt.Sprintf("job%d", i), 0))
+			}
+
+			if _, err := runPlan(t, plan, PlanRunOptions{Parallelism: parallelism}); err != nil {
+				t.Fatal(err)
+			}
+
+			running, peak := 0, 0
+			for _, event := range log.events(t) {
+				if strings.HasPrefix(event, "start ") {
+					running++
+				} else {
+					running--
+				}
+				peak = max(peak, running)
+			}
+			if peak != parallelism {
+				t.Errorf("at most %d jobs ran at once, want %d: %v", peak, parallelism, log.events(t))
+			}
+		})
+	}
+}
+
+func TestPlanRunnerFailure(t *testing.T) {
+	newPlan := func(log *eventLog) *Plan {
+		return &Plan{Jobs: []Job{
+			log.job("migrate", 1),
+			log.job("deploy", 0, "migrate"),
+			log.job("smoke-test", 0, "deploy"),
+			log.job("build", 0),
+			log.job("publish", 0, "build"),
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		keepGoing bool
+		want      map[string]string // job ID to status and reason
+	}{
+		{
+			name: "fail fast",
+			want: map[string]string{
+				"migrate":    "failed",
+				"deploy":     "skipped: run stopped after an earlier failure",
+				"smoke-test": "skipped: dependency deploy did not succeed",
+				"build":      "skipped: run stopped after an earlier failure",
+				"publish":    "skipped: dependency build did not succeed",
+			},
+		},
+		{
+			name:      "keep going",
+			keepGoing: true,
+			want: map[string]string{
+				"migrate":    "failed",
+				"deploy":     "skipped: dependency migrate failed",
+				"smoke-test": "skipped: dependency deploy did not succeed",
+				"build":      "succeeded",
+				"publish":    "succeeded",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := newEventLog(t)
+			results, err := runPlan(t, newPlan(log), PlanRunOptions{Parallelism: 1, KeepGoing: tt.keepGoing})
+			if err == nil || err.Error() != "1 job(s) failed" {
+				t.Errorf("Run() error = %v, want 1 job(s) failed", err)
+			}
+
+			for _, result := range results {
+				got := string(result.Status)
+				if result.Reason != "" {
+					got += ": " + result.Reason
+				}
+				if got != tt.want[result.JobID] {
+					t.Errorf("%s = %q, want %q", result.JobID, got, tt.want[result.JobID])
+				}
+			}
+			for _, event := range log.events(t) {
+				id := strings.Fields(event)[1]
+				if strings.HasPrefix(tt.want[id], "skipped") {
+					t.Errorf("skipped job %s ran", id)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanRunnerAllowedFailure(t *testing.T) {
+	log := newEventLog(t)
+	lint := log.job("lint", 1)
+	lint["metadata"] = map[string]any{"continueOnError": true}
+	plan := &Plan{Jobs: []Job{lint, log.job("build", 0, "lint")}}
+
+	results, err := runPlan(t, plan, PlanRunOptions{})
+	if err != nil {
+		t.Fatalf("Run() error = %v, want an allowed failure to pass the run", err)
+	}
+	if !results[0].AllowedFailure || results[0].Status != JobStatusFailed {
+		t.Errorf("lint = %s, allowed %v, want an allowed failure", results[0].Status, results[0].AllowedFailure)
+	}
+	if results[1].Status != JobStatusSucceeded {
+		t.Errorf("build = %s, want its dependents to run after an allowed failure", results[1].Status)
+	}
+}
+
+func TestPlanRunnerUnrunnable(t *testing.T) {
+	log := newEventLog(t)
+	plan := &Plan{Jobs: []Job{
+		log.job("a", 0, "b"),
+		log.job("b", 0, "a"),
+		log.job("c", 0, "outside-the-plan"),
+	}}
+
+	results, err := runPlan(t, plan, PlanRunOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first job of the cycle is skipped, and its dependents with it
+	if results[0].Status != JobStatusSkipped || results[0].Reason != "unresolvable dependency cycle" {
+		t.Errorf("a = %s (%s), want skipped for a cycle", results[0].Status, results[0].Reason)
+	}
+	if results[1].Status != JobStatusSkipped || results[1].Reason != "dependency a did not succeed" {
+		t.Errorf("b = %s (%s), want skipped after a", results[1].Status, results[1].Reason)
+	}
+	// Dependencies outside the plan are ignored
+	if results[2].Status != JobStatusSucceeded {
+		t.Errorf("c = %s, want succeeded", results[2].Status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner := NewPlanRunner(&Plan{Jobs: []Job{log.job("d", 0)}}, PlanRunOptions{StateDir: t.TempDir()})
+	runner.stdout, runner.stderr = io.Discard, io.Discard
+	results, _ = runner.Run(ctx)
+	if results[0].Status != JobStatusSkipped || results[0].Reason != "run cancelled" {
+		t.Errorf("d = %s (%s), want skipped because the run was cancelled", results[0].Status, results[0].Reason)
+	}
+
+	duplicate := &Plan{Jobs: []Job{log.job("a", 0), log.job("a", 0)}}
+	if _, err := runPlan(t, duplicate, PlanRunOptions{}); err == nil || err.Error() != "duplicate job id in plan: a" {
+		t.Errorf("Run() error = %v, want a duplicate job id error", err)
+	}
+}
@@ -0,0 +1,134 @@
+package thinci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultGracePeriod is how long a signalled command may take to exit before it is killed
+const DefaultGracePeriod = 10 * time.Second
+
+// InterruptError is the cancellation cause recorded when the process
+// receives SIGINT or SIGTERM
+type InterruptError struct {
+	Signal os.Signal
+}
+
+func (ie *InterruptError) Error() string {
+	return fmt.Sprintf("received %s signal", ie.Signal)
+}
+
+// TimeoutError is returned when a command or job exceeds its time limit
+type TimeoutError struct {
+	Scope   string // "command" or "job"
+	Timeout time.Duration
+}
+
+func (te *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", te.Scope, te.Timeout)
+}
+
+// NotifyInterrupt returns a context that is cancelled when SIGINT or SIGTERM
+// is received. The context's cause is an *InterruptError naming the signal,
+// which running commands forward to their process group. A second signal
+// kills the process groups of all running commands and exits without
+// waiting for the grace period.
+func NotifyInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			cancel(&InterruptError{Signal: sig})
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case sig := <-signals:
+			runningCommands.kill()
+			os.Exit(signalExitCode(sig))
+		case <-stopped:
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(stopped)
+		})
+		cancel(context.Canceled)
+	}
+}
+
+// signalExitCode is the exit status of a process ended by sig, as shells report it
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// runningCommands tracks the commands started by executors in this process,
+// so that a second interrupt can kill them before exiting
+var runningCommands = &commandSet{cmds: make(map[*exec.Cmd]struct{})}
+
+type commandSet struct {
+	mu   sync.Mutex
+	cmds map[*exec.Cmd]struct{}
+}
+
+// add tracks a started command until the returned function is called
+func (cs *commandSet) add(cmd *exec.Cmd) func() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.cmds[cmd] = struct{}{}
+	return func() {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		delete(cs.cmds, cmd)
+	}
+}
+
+// kill sends SIGKILL to the process group of every tracked command
+func (cs *commandSet) kill() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for cmd := range cs.cmds {
+		signalProcessGroup(cmd, os.Kill)
+	}
+}
+
+// terminationSignal picks the signal to forward to a command when ctx ends
+func terminationSignal(ctx context.Context) os.Signal {
+	var interrupt *InterruptError
+	if errors.As(context.Cause(ctx), &interrupt) {
+		return interrupt.Signal
+	}
+	return syscall.SIGTERM
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
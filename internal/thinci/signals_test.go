@@ -0,0 +1,82 @@
+//go:build !windows
+
+package thinci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestNotifyInterrupt runs in a child process, which signals itself while a
+// job runs a command that ignores SIGINT: the first signal cancels the
+// context, the second one kills the command's process group and exits
+func TestNotifyInterrupt(t *testing.T) {
+	if dir := os.Getenv("THINCI_TEST_INTERRUPT_DIR"); dir != "" {
+		interruptChild(dir)
+		return
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestNotifyInterrupt$")
+	cmd.Env = append(os.Environ(), "THINCI_TEST_INTERRUPT_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+
+	if !strings.Contains(string(out), "cancelled: received interrupt signal") {
+		t.Fatalf("first signal did not cancel the context:\n%s", out)
+	}
+	if strings.Contains(string(out), "still running") {
+		t.Fatalf("second signal did not end the process:\n%s", out)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 130 {
+		t.Fatalf("child error = %v, want exit status 130", err)
+	}
+
+	// The command would have finished a second after it started
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(dir, "finished")); !os.IsNotExist(err) {
+		t.Error("command kept running after the second signal")
+	}
+}
+
+func interruptChild(dir string) {
+	ctx, stop := NotifyInterrupt(context.Background())
+	defer stop()
+
+	started := filepath.Join(dir, "started")
+	go func() {
+		for {
+			if _, err := os.Stat(started); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		<-ctx.Done()
+		fmt.Printf("cancelled: %v\n", context.Cause(ctx))
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+	}()
+
+	executor := NewExecutor(false, false)
+	executor.SetOutput(io.Discard, io.Discard)
+	executor.SetStateDir(filepath.Join(dir, "state"))
+	executor.SetGracePeriod(time.Minute)
+	executor.ExecuteJob(ctx, Job{
+		"id": "hung",
+		"commands": []any{
+			fmt.Sprintf("trap '' INT TERM; touch %s; sleep 1; touch %s", started, filepath.Join(dir, "finished")),
+		},
+	})
+
+	fmt.Println("still running")
+	os.Exit(1)
+}
@@ -0,0 +1,51 @@
+package thinci
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sourceplane/sourceplane/internal/templating"
+)
+
+// templateData builds the data a job's templates are rendered with: the
+// job's core fields, plus its full input tree both at the top level
+// ({{.namespace}}, {{.values.image.tag}}) and under {{.inputs}}
+func templateData(job Job, inputs map[string]any) map[string]any {
+	data := map[string]any{
+		"id":        job.GetID(),
+		"component": job.GetComponent(),
+		"provider":  job.GetProvider(),
+		"action":    job.GetAction(),
+	}
+	for k, v := range inputs {
+		data[k] = v
+	}
+	if inputs == nil {
+		inputs = map[string]any{}
+	}
+	data["inputs"] = inputs
+	return data
+}
+
+// referencesOutputs reports whether a template uses outputs of other jobs,
+// which are only known at run time
+func referencesOutputs(s string) bool {
+	return strings.Contains(s, ".outputs.")
+}
+
+// withJob names the job in a template error
+func withJob(err error, jobID string) error {
+	var fieldErr *templating.FieldError
+	if errors.As(err, &fieldErr) && fieldErr.Job == "" {
+		fieldErr.Job = jobID
+	}
+	return err
+}
+
+// copyValue deep-copies maps and slices decoded from JSON or YAML
+func copyValue(val any) any {
+	copied, _ := templating.Walk("", val, func(_, s string) (string, error) {
+		return s, nil
+	})
+	return copied
+}
@@ -1,5 +1,11 @@
 package thinci
 
+import (
+	"fmt"
+
+	"github.com/sourceplane/sourceplane/internal/providers"
+)
+
 // Plan represents a complete CI execution plan
 type Plan struct {
 	Target   string       `json:"target"` // e.g., "github", "gitlab"
@@ -77,26 +83,96 @@ func (j Job) GetDependsOn() []string {
 	return []string{}
 }
 
-// ProviderAction describes what a provider can do in CI
-type ProviderAction struct {
-	Name        string         `json:"name" yaml:"name"` // plan, apply, destroy, validate
-	Description string         `json:"description" yaml:"description"`
-	Order       int            `json:"order" yaml:"order"` // Execution order relative to other actions
-	JobTemplate map[string]any `json:"jobTemplate,omitempty" yaml:"jobTemplate,omitempty"` // Provider-defined job structure template
-	Commands    []string       `json:"commands,omitempty" yaml:"commands,omitempty"`
-	PreSteps    []ActionStep   `json:"preSteps,omitempty" yaml:"preSteps,omitempty"`
-	PostSteps   []ActionStep   `json:"postSteps,omitempty" yaml:"postSteps,omitempty"`
-	Inputs      map[string]any `json:"inputs,omitempty" yaml:"inputs,omitempty"`
-	Outputs     []string       `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+// GetCommands returns the job's main commands
+func (j Job) GetCommands() []string {
+	return toStringSlice(j["commands"])
+}
+
+// GetPreSteps returns the steps to run before the main commands
+func (j Job) GetPreSteps() []ActionStep {
+	return toActionSteps(j["preSteps"])
+}
+
+// GetPostSteps returns the steps to run after the main commands
+func (j Job) GetPostSteps() []ActionStep {
+	return toActionSteps(j["postSteps"])
+}
+
+// GetInputs returns the job's resolved inputs
+func (j Job) GetInputs() map[string]any {
+	if inputs, ok := j["inputs"].(map[string]any); ok {
+		return inputs
+	}
+	return map[string]any{}
+}
+
+// GetInputSpecs returns the input declarations the provider recorded on the job
+func (j Job) GetInputSpecs() (map[string]InputSpec, error) {
+	return ParseInputSpecs(j["inputSpecs"])
 }
 
-// ActionStep represents a single step within an action
-type ActionStep struct {
-	Name    string         `json:"name" yaml:"name"`
-	Command string         `json:"command" yaml:"command"`
-	Inputs  map[string]any `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+// GetMetadata returns the job's platform metadata
+func (j Job) GetMetadata() map[string]any {
+	if metadata, ok := j["metadata"].(map[string]any); ok {
+		return metadata
+	}
+	return map[string]any{}
+}
+
+// toStringSlice converts []string or []interface{} values to []string
+func toStringSlice(val any) []string {
+	switch v := val.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return []string{}
 }
 
+// toActionSteps converts in-memory or decoded (JSON/YAML) steps to ActionSteps
+func toActionSteps(val any) []ActionStep {
+	steps := []ActionStep{}
+
+	switch v := val.(type) {
+	case []ActionStep:
+		steps = v
+	case []interface{}:
+		for _, stepRaw := range v {
+			if stepMap, ok := stepRaw.(map[string]interface{}); ok {
+				step := ActionStep{
+					Name:    getString(stepMap, "name"),
+					Command: getString(stepMap, "command"),
+				}
+				if timeout, ok := stepMap["timeout"]; ok {
+					step.Timeout = fmt.Sprintf("%v", timeout)
+				}
+				if inputsMap, ok := stepMap["inputs"].(map[string]interface{}); ok {
+					step.Inputs = make(map[string]any)
+					for k, v := range inputsMap {
+						step.Inputs[k] = v
+					}
+				}
+				steps = append(steps, step)
+			}
+		}
+	}
+
+	return steps
+}
+
+// ProviderAction and ActionStep are part of the provider model
+type (
+	ProviderAction = providers.ProviderAction
+	ActionStep     = providers.ActionStep
+)
+
 // ComponentChange tracks which component is affected by file changes
 type ComponentChange struct {
 	ComponentName string
@@ -104,14 +180,58 @@ type ComponentChange struct {
 	ComponentType string
 	Reason        string   // Why this component is affected
 	AffectedPaths []string // Which paths triggered the change
+
+	// ImpactPath is set for components planned because of their
+	// relationships: the changed component first, then each related
+	// component up to this one
+	ImpactPath []string
+}
+
+// RuleEvaluation records how a change detection rule was applied to a
+// component: the patterns it checked, where they came from, and the changed
+// files they matched. For ignorePaths the matched files are the ignored
+// ones.
+type RuleEvaluation struct {
+	Rule     string   `json:"rule"`
+	Source   string   `json:"source"`
+	Patterns []string `json:"patterns,omitempty"`
+	Matched  []string `json:"matched,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// Explanation describes why a component is or is not part of a plan
+type Explanation struct {
+	Component     string           `json:"component"`
+	Type          string           `json:"type"`
+	Provider      string           `json:"provider"`
+	Planned       bool             `json:"planned"`
+	Reason        string           `json:"reason,omitempty"`
+	AffectedPaths []string         `json:"affectedPaths,omitempty"`
+	ImpactPath    []string         `json:"impactPath,omitempty"`
+	Rules         []RuleEvaluation `json:"rules"`
+	Dependencies  []string         `json:"dependencies,omitempty"`
+	Dependents    []string         `json:"dependents,omitempty"`
+	ChangedFiles  []string         `json:"changedFiles"`
 }
 
 // DependencyNode represents a node in the dependency graph
 type DependencyNode struct {
 	ComponentName string
+	ComponentType string
 	Provider      string
+	Spec          map[string]any // Component spec, including deprecated inputs
+	Defaults      map[string]any // Intent-level provider defaults for the component's kind
 	Actions       []string // Which actions this component needs
 	Dependencies  []string // Component names this depends on
+
+	// Why the component is planned, from its ComponentChange
+	Reason        string
+	AffectedPaths []string
+	ImpactPath    []string
+
+	// Overlays from the intent's environments section for the planned environment
+	EnvironmentSpec     map[string]any
+	EnvironmentDefaults map[string]any
 }
 
 // PlanRequest contains all inputs needed to generate a plan
@@ -125,12 +245,34 @@ type PlanRequest struct {
 	RepositoryPath string
 	IntentFiles    []string // Paths to intent.yaml files
 
+	// IntentDir is the directory of the intent files relative to the git
+	// repository root. ChangedFiles are relative to the root, the paths in
+	// the intent to IntentDir.
+	IntentDir string
+
+	// IntentSources are the intent files at the base of the diff, parallel
+	// to the intents being planned. Without them a change to any intent file
+	// affects every component.
+	IntentSources []IntentSource
+
 	// CLI flags
 	Target      string // github, gitlab, etc.
 	Mode        string // plan, apply
 	ChangedOnly bool
 	Environment string
 
+	// Plan the components that depend on changed components, or that
+	// changed components depend on, through the relationship graph
+	IncludeDependents   bool
+	IncludeDependencies bool
+
+	// SetValues are `--set` arguments: path=value or component:path=value
+	SetValues []string
+
+	// StrictTemplates makes templates referencing unknown keys an error
+	// instead of leaving them for the executor to resolve
+	StrictTemplates bool
+
 	// Optional overrides
 	ProviderOverrides map[string]map[string]any
 }
@@ -2,13 +2,16 @@ package validator
 
 import (
 	"fmt"
+	"sort"
 
+	"github.com/sourceplane/sourceplane/internal/glob"
 	"github.com/sourceplane/sourceplane/internal/models"
-	"github.com/sourceplane/sourceplane/internal/provider"
+	"github.com/sourceplane/sourceplane/internal/providers"
 )
 
-// ValidateRepository validates a repository definition against available providers
-func ValidateRepository(repo *models.Repository) error {
+// ValidateRepository validates a repository definition against its providers,
+// resolved the same way thin-ci resolves them
+func ValidateRepository(repo *models.Repository, resolver *providers.Resolver) error {
 	errors := []string{}
 
 	// Basic validation
@@ -27,6 +30,8 @@ func ValidateRepository(repo *models.Repository) error {
 	// Validate components
 	if len(repo.Components) == 0 {
 		// Not an error, just no components
+		errors = append(errors, Messages(ValidateEnvironments(repo))...)
+		errors = append(errors, Messages(ValidatePathPatterns(repo))...)
 		if len(errors) > 0 {
 			return fmt.Errorf("validation failed:\n  • %s", joinErrors(errors))
 		}
@@ -50,14 +55,14 @@ func ValidateRepository(repo *models.Repository) error {
 		}
 
 		// Validate provider for this component
-		providerName := provider.GetProviderNameFromType(comp.Type)
+		providerName := providers.GetProviderNameFromType(comp.Type)
 		if providerName == "" {
 			errors = append(errors, fmt.Sprintf("component '%s': invalid type format '%s' (expected: provider.kind)", comp.Name, comp.Type))
 			continue
 		}
 
 		// Load and validate against provider definition
-		providerMeta, err := provider.LoadProvider(providerName)
+		providerMeta, err := resolver.ForComponent(repo, comp.Type)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("component '%s': %v", comp.Name, err))
 			continue
@@ -66,12 +71,19 @@ func ValidateRepository(repo *models.Repository) error {
 		// Validate component type against provider's supported types
 		if err := providerMeta.ValidateComponentType(comp.Type); err != nil {
 			errors = append(errors, fmt.Sprintf("component '%s': %v", comp.Name, err))
+			continue
 		}
+
+		// Validate the spec against the provider's schema for the kind
+		errors = append(errors, Messages(ValidateComponentSpec(i, comp.Type, ComponentSpec(comp), providerMeta))...)
 	}
 
+	errors = append(errors, Messages(ValidateEnvironments(repo))...)
+	errors = append(errors, Messages(ValidatePathPatterns(repo))...)
+
 	if len(errors) > 0 {
 		// Get available providers for helpful error message
-		availableProviders, _ := provider.ListAvailableProviders()
+		availableProviders, _ := resolver.LocalProviders()
 		errorMsg := "validation failed:\n"
 		for _, err := range errors {
 			errorMsg += fmt.Sprintf("  • %s\n", err)
@@ -88,6 +100,130 @@ func ValidateRepository(repo *models.Repository) error {
 	return nil
 }
 
+// Problem is a validation error located at a path in the intent, such as
+// "components[2].spec.chart" or "environments.prod.components.api"
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// Messages formats problems as "path: message" strings
+func Messages(problems []Problem) []string {
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = problem.String()
+	}
+	return messages
+}
+
+// ValidateComponentSpec validates a component's spec against the schema its
+// provider declares for the component's kind. Problems are reported with the
+// component's index and the path within the spec, e.g.
+// "components[2].spec.chart: must have either repo+name or path".
+func ValidateComponentSpec(index int, componentType string, spec map[string]interface{}, provider *providers.Provider) []Problem {
+	kindSchema, err := provider.KindSchema(componentType)
+	if err != nil {
+		return []Problem{{Path: fmt.Sprintf("components[%d].type", index), Message: err.Error()}}
+	}
+	if kindSchema == nil {
+		return nil
+	}
+
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+
+	problems := []Problem{}
+	for _, err := range kindSchema.Validate(spec) {
+		path := fmt.Sprintf("components[%d].spec", index)
+		if err.Path != "" {
+			path += "." + err.Path
+		}
+		problems = append(problems, Problem{Path: path, Message: err.Message})
+	}
+	return problems
+}
+
+// ComponentSpec returns the spec of a component, falling back to the
+// deprecated inputs field. Component-level relationships are not part of
+// the provider's schema and are left out.
+func ComponentSpec(comp models.Component) map[string]interface{} {
+	source := comp.Spec
+	if len(source) == 0 {
+		source = comp.Inputs
+	}
+	spec := make(map[string]interface{}, len(source))
+	for k, v := range source {
+		if k != "relationships" {
+			spec[k] = v
+		}
+	}
+	return spec
+}
+
+// ValidateEnvironments checks that environment overlays only reference
+// components declared in the repository
+func ValidateEnvironments(repo *models.Repository) []Problem {
+	problems := []Problem{}
+
+	componentNames := make(map[string]bool)
+	for _, comp := range repo.Components {
+		componentNames[comp.Name] = true
+	}
+
+	envNames := make([]string, 0, len(repo.Environments))
+	for name := range repo.Environments {
+		envNames = append(envNames, name)
+	}
+	sort.Strings(envNames)
+
+	for _, envName := range envNames {
+		overlays := repo.Environments[envName].Components
+		names := make([]string, 0, len(overlays))
+		for name := range overlays {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !componentNames[name] {
+				problems = append(problems, Problem{
+					Path:    fmt.Sprintf("environments.%s.components.%s", envName, name),
+					Message: fmt.Sprintf("overlay references unknown component '%s'", name),
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+// ValidatePathPatterns checks the paths and ignorePaths glob patterns of
+// each component
+func ValidatePathPatterns(repo *models.Repository) []Problem {
+	problems := []Problem{}
+
+	for i, comp := range repo.Components {
+		for field, patterns := range map[string][]string{"paths": comp.Paths, "ignorePaths": comp.IgnorePaths} {
+			for j, pattern := range patterns {
+				if err := glob.Validate(pattern); err != nil {
+					problems = append(problems, Problem{
+						Path:    fmt.Sprintf("components[%d].%s[%d]", i, field, j),
+						Message: err.Error(),
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+	return problems
+}
+
 func joinErrors(errors []string) string {
 	if len(errors) == 0 {
 		return ""